@@ -11,6 +11,7 @@ import select
 import os
 import base64
 import hashlib
+import re
 
 PORT = 38888  # 高位端口，避免冲突
 CHUNK_SIZE = 1024 * 1024  # 1MB 分块大小
@@ -89,6 +90,123 @@ class PersistentShell:
 # 全局Shell实例
 shell = PersistentShell()
 
+class BackgroundProcesses:
+    """后台进程管理（长时间运行的命令，按行号增量读取输出）"""
+    MAX_LINES = 5000
+
+    def __init__(self):
+        self.lock = threading.Lock()
+        self.procs = {}
+        self.counter = 0
+
+    def start(self, command, cwd=None):
+        cwd = cwd or shell.cwd
+        if not os.path.isabs(cwd):
+            cwd = os.path.join(shell.cwd, cwd)
+        proc = subprocess.Popen(
+            command,
+            shell=True,
+            cwd=cwd,
+            env=shell.env,
+            stdin=subprocess.PIPE,
+            stdout=subprocess.PIPE,
+            stderr=subprocess.STDOUT,
+            executable='/bin/bash'
+        )
+        with self.lock:
+            self.counter += 1
+            process_id = f"{int(time.time() * 1000) + self.counter}"
+            info = {
+                'proc': proc,
+                'command': command,
+                'start_time': time.time(),
+                'output': [],
+                'dropped': 0,
+                'cursor': 0,
+            }
+            self.procs[process_id] = info
+        thread = threading.Thread(target=self._collect, args=(info,))
+        thread.daemon = True
+        thread.start()
+        return process_id
+
+    def _collect(self, info):
+        for raw in iter(info['proc'].stdout.readline, b''):
+            line = raw.decode('utf-8', errors='replace').rstrip('\r\n')
+            with self.lock:
+                info['output'].append(line)
+                if len(info['output']) > self.MAX_LINES:
+                    drop = len(info['output']) - self.MAX_LINES
+                    del info['output'][:drop]
+                    info['dropped'] += drop
+        info['proc'].wait()
+
+    def _get(self, process_id):
+        info = self.procs.get(process_id)
+        if info is None:
+            raise ValueError(f"进程不存在: {process_id}")
+        return info
+
+    def send_input(self, process_id, text):
+        with self.lock:
+            info = self._get(process_id)
+        if info['proc'].poll() is not None:
+            raise ValueError("进程已结束")
+        info['proc'].stdin.write(text.encode('utf-8'))
+        info['proc'].stdin.flush()
+
+    def read(self, process_id, offset, max_lines):
+        with self.lock:
+            info = self._get(process_id)
+            if offset < 0:
+                offset = info['cursor']
+            total = info['dropped'] + len(info['output'])
+            offset = min(offset, total)
+            skipped = 0
+            if offset < info['dropped']:
+                skipped = info['dropped'] - offset
+                offset = info['dropped']
+            end = total
+            if max_lines > 0:
+                end = min(end, offset + max_lines)
+            lines = info['output'][offset - info['dropped']:end - info['dropped']]
+            info['cursor'] = end
+        code = info['proc'].poll()
+        return {
+            'success': True,
+            'lines': lines,
+            'next': end,
+            'skipped': skipped,
+            'done': code is not None,
+            'exit_code': code if code is not None else 0,
+        }
+
+    def kill(self, process_id):
+        with self.lock:
+            info = self._get(process_id)
+        if info['proc'].poll() is not None:
+            raise ValueError("进程已结束")
+        info['proc'].kill()
+
+    def list(self):
+        with self.lock:
+            items = []
+            for process_id, info in self.procs.items():
+                code = info['proc'].poll()
+                items.append({
+                    'process_id': process_id,
+                    'command': info['command'],
+                    'start_time': info['start_time'],
+                    'lines': info['dropped'] + len(info['output']),
+                    'done': code is not None,
+                    'exit_code': code if code is not None else 0,
+                })
+        items.sort(key=lambda x: x['start_time'])
+        return items
+
+# 全局后台进程管理器
+background = BackgroundProcesses()
+
 def handle_upload(data):
     """处理文件上传（支持分块和完整文件）"""
     path = data['path']
@@ -188,9 +306,12 @@ def handle_file_info(data):
     }
 
 def handle_list_dir(data):
-    """列出目录内容"""
+    """列出目录内容（带 depth 参数时递归列出，遵守 .gitignore）"""
     path = data['path']
     
+    if 'depth' in data:
+        return list_dir_recursive(data)
+    
     if not os.path.isdir(path):
         raise NotADirectoryError(f"Not a directory: {path}")
     
@@ -275,6 +396,485 @@ def handle_tar_download(data):
         'size': len(stdout)
     }
 
+# 默认跳过的目录（版本库、依赖、缓存）
+# 禁止递归列出的目录（与Go端 isOversizedListRoot 一致）：大型目录本身，以及整个虚拟文件系统
+OVERSIZED_LIST_ROOTS = ['/', '/root', '/home', '/usr', '/var', 'C:\\', 'D:\\']
+VIRTUAL_LIST_TREES = ['/proc', '/sys', '/dev']
+
+def is_oversized_list_root(path):
+    if path in OVERSIZED_LIST_ROOTS:
+        return True
+    return any(path == t or path.startswith(t + '/') for t in VIRTUAL_LIST_TREES)
+
+DEFAULT_SKIP_DIRS = ['.git', '.svn', '.hg', 'node_modules', 'vendor', '__pycache__', '.idea', '.vscode']
+
+def glob_to_regex(glob):
+    """把 glob（支持 **、*、?、[...]）转换为正则表达式"""
+    out = []
+    i = 0
+    while i < len(glob):
+        c = glob[i]
+        if c == '*':
+            if i + 1 < len(glob) and glob[i + 1] == '*':
+                i += 1
+                if i + 1 < len(glob) and glob[i + 1] == '/':
+                    i += 1
+                    out.append('(?:.*/)?')
+                else:
+                    out.append('.*')
+            else:
+                out.append('[^/]*')
+        elif c == '?':
+            out.append('[^/]')
+        elif c == '[':
+            end = glob.find(']', i + 1)
+            if end < 0:
+                out.append('\\[')
+            else:
+                cls = glob[i + 1:end]
+                if cls.startswith('!'):
+                    cls = '^' + cls[1:]
+                out.append('[' + cls + ']')
+                i = end
+        else:
+            out.append(re.escape(c))
+        i += 1
+    return ''.join(out)
+
+def match_glob(pattern, rel):
+    """匹配文件名或相对路径（模式含 / 时按路径匹配）"""
+    target = rel if '/' in pattern else rel.rsplit('/', 1)[-1]
+    try:
+        return re.fullmatch(glob_to_regex(pattern.lstrip('/')), target) is not None
+    except re.error:
+        return False
+
+def match_any_glob(patterns, rel):
+    return any(match_glob(p, rel) for p in patterns or [])
+
+class IgnoreMatcher:
+    """.gitignore 匹配器（按目录层级叠加规则，与Go端实现一致）"""
+    def __init__(self, rules=None):
+        self.rules = rules or []
+
+    def with_dir(self, dir_path, rel):
+        rules = []
+        try:
+            with open(os.path.join(dir_path, '.gitignore'), 'r', errors='replace') as f:
+                for line in f:
+                    rule = self._parse(line, rel)
+                    if rule:
+                        rules.append(rule)
+        except OSError:
+            pass
+        if not rules:
+            return self
+        return IgnoreMatcher(self.rules + rules)
+
+    @staticmethod
+    def _parse(line, base):
+        line = line.rstrip(' \t\r\n')
+        if not line or line.startswith('#'):
+            return None
+        negate = line.startswith('!')
+        if negate:
+            line = line[1:]
+        if line.startswith('\\'):
+            line = line[1:]
+        dir_only = line.endswith('/')
+        line = line.rstrip('/')
+        has_dir = '/' in line
+        line = line.lstrip('/')
+        if not line:
+            return None
+        try:
+            pattern = re.compile('^' + glob_to_regex(line) + '$')
+        except re.error:
+            return None
+        return (base, pattern, negate, dir_only, has_dir)
+
+    def match(self, rel, is_dir):
+        ignored = False
+        for base, pattern, negate, dir_only, has_dir in self.rules:
+            if dir_only and not is_dir:
+                continue
+            target = rel
+            if base:
+                if not rel.startswith(base + '/'):
+                    continue
+                target = rel[len(base) + 1:]
+            if not has_dir:
+                target = target.rsplit('/', 1)[-1]
+            if pattern.match(target):
+                ignored = not negate
+        return ignored
+
+def walk_files(root, exclude=None, no_ignore=False, skip_dirs=None, max_depth=-1, show_skipped=False):
+    """遍历目录，遵守 .gitignore 和排除规则，产出 (DirEntry, 相对路径, 深度)
+    show_skipped 为 True 时 skip_dirs 中的目录只产出不展开"""
+    skip_dirs = skip_dirs if skip_dirs is not None else DEFAULT_SKIP_DIRS
+    root_matcher = IgnoreMatcher() if no_ignore else IgnoreMatcher().with_dir(root, '')
+    stack = [(root, '', root_matcher, 0)]
+    while stack:
+        dir_path, dir_rel, matcher, depth = stack.pop()
+        try:
+            entries = sorted(os.scandir(dir_path), key=lambda e: e.name)
+        except OSError:
+            continue
+        subdirs = []
+        for entry in entries:
+            rel = entry.name if not dir_rel else dir_rel + '/' + entry.name
+            try:
+                is_dir = entry.is_dir(follow_symlinks=False)
+            except OSError:
+                continue
+            if is_dir:
+                if entry.name in skip_dirs:
+                    if show_skipped:
+                        yield entry, rel, depth + 1
+                    continue
+                if match_any_glob(exclude, rel):
+                    continue
+                if not no_ignore and matcher.match(rel, True):
+                    continue
+                if max_depth < 0 or depth + 1 < max_depth:
+                    child = matcher if no_ignore else matcher.with_dir(entry.path, rel)
+                    subdirs.append((entry.path, rel, child, depth + 1))
+                yield entry, rel, depth + 1
+            else:
+                if not no_ignore and matcher.match(rel, False):
+                    continue
+                if match_any_glob(exclude, rel):
+                    continue
+                yield entry, rel, depth + 1
+        stack.extend(reversed(subdirs))
+
+def handle_search(data):
+    """原生代码搜索（字面量/正则、大小写、include/exclude、.gitignore、上下文）"""
+    query = data['query']
+    path = data.get('path') or '.'
+    if not os.path.isabs(path):
+        path = os.path.join(shell.cwd, path)
+    path = os.path.normpath(path)
+
+    pattern = query if data.get('regex') else re.escape(query)
+    case = data.get('case', 'sensitive')
+    flags = 0
+    if case == 'ignore' or (case == 'smart' and query.lower() == query):
+        flags = re.IGNORECASE
+    regex = re.compile(pattern, flags)
+
+    include = data.get('include') or []
+    exclude = data.get('exclude') or []
+    context = int(data.get('context') or 0)
+    max_matches = int(data.get('max_matches') or 5000)
+    max_size = int(data.get('max_size') or 2 * 1024 * 1024)
+
+    result = {'success': True, 'matches': [], 'files': [], 'total': 0, 'scanned': 0, 'truncated': False}
+
+    def search_file(file_path, display):
+        try:
+            if os.path.getsize(file_path) > max_size:
+                return
+            with open(file_path, 'rb') as f:
+                raw = f.read()
+        except OSError:
+            return
+        if b'\x00' in raw[:8000]:
+            return
+        result['scanned'] += 1
+        lines = raw.decode('utf-8', errors='replace').split('\n')
+        if lines and lines[-1] == '':
+            lines.pop()
+        lines = [l.rstrip('\r') for l in lines]
+        count = 0
+        for i, line in enumerate(lines):
+            if not regex.search(line):
+                continue
+            count += 1
+            result['total'] += 1
+            if len(result['matches']) >= max_matches:
+                result['truncated'] = True
+                continue
+            match = {'file': display, 'line': i + 1, 'text': line}
+            if context > 0:
+                match['before'] = lines[max(0, i - context):i]
+                match['after'] = lines[i + 1:i + 1 + context]
+            result['matches'].append(match)
+        if count:
+            result['files'].append({'file': display, 'count': count})
+
+    if os.path.isfile(path):
+        search_file(path, data.get('path') or path)
+        return result
+    if not os.path.isdir(path):
+        raise FileNotFoundError(f"Path not found: {path}")
+
+    display_root = (data.get('path') or '.').rstrip('/') or '/'
+    for entry, rel, _ in walk_files(path, exclude, data.get('no_ignore', False), data.get('skip_dirs')):
+        if not entry.is_file(follow_symlinks=False):
+            continue
+        if include and not match_any_glob(include, rel):
+            continue
+        display = rel if display_root == '.' else display_root + '/' + rel
+        search_file(entry.path, display)
+
+    return result
+
+def count_children(path):
+    try:
+        return len(os.listdir(path))
+    except OSError:
+        return -1
+
+def list_dir_recursive(data):
+    """递归列出目录（深度限制、.gitignore过滤、行数统计，与Go端结果格式一致）"""
+    path = data.get('path') or '.'
+    if not os.path.isabs(path):
+        path = os.path.join(shell.cwd, path)
+    path = os.path.normpath(path)
+    if not os.path.isdir(path):
+        raise NotADirectoryError(f"Not a directory: {path}")
+
+    depth = max(1, int(data.get('depth') or 1))
+    if depth > 1 and is_oversized_list_root(path):
+        raise ValueError(f"为避免系统过载，禁止递归扫描大型目录: {path}（使用 depth=1 查看第一层，再进入具体子目录）")
+    pattern = data.get('pattern') or ''
+    count_lines = data.get('count_lines', False)
+    max_entries = int(data.get('max_entries') or 500)
+    max_scanned = int(data.get('max_scanned') or 20000)
+    skip_dirs = data.get('skip_dirs') or DEFAULT_SKIP_DIRS
+
+    result = {'success': True, 'entries': [], 'dirs': 0, 'files': 0, 'total_size': 0, 'truncated': False}
+
+    def add(entry):
+        if len(result['entries']) >= max_entries:
+            result['truncated'] = True
+        else:
+            result['entries'].append(entry)
+
+    scanned = 0
+    # 依赖/版本库目录只显示不展开
+    for entry, rel, level in walk_files(path, None, data.get('no_ignore', False), skip_dirs, depth, True):
+        scanned += 1
+        if scanned > max_scanned:
+            result['truncated'] = True
+            break
+        try:
+            is_dir = entry.is_dir(follow_symlinks=False)
+            st = entry.stat(follow_symlinks=False)
+        except OSError:
+            continue
+        if is_dir:
+            result['dirs'] += 1
+            children = -1
+            if level >= depth or entry.name in skip_dirs:
+                children = count_children(entry.path)
+            add({'path': rel, 'is_dir': True, 'size': 0, 'lines': -1, 'children': children, 'mtime': st.st_mtime})
+            continue
+        if pattern and not match_glob(pattern, rel):
+            continue
+        lines = -1
+        if count_lines and entry.is_file(follow_symlinks=False) and st.st_size <= 1024 * 1024:
+            try:
+                with open(entry.path, 'rb') as f:
+                    lines = f.read().count(b'\n') + 1
+            except OSError:
+                pass
+        result['files'] += 1
+        result['total_size'] += st.st_size
+        add({'path': rel, 'is_dir': False, 'size': st.st_size, 'lines': lines, 'children': -1, 'mtime': st.st_mtime})
+
+    return result
+
+def handle_find(data):
+    """按名称/类型/大小/修改时间查找文件（限制扫描数量和耗时，与Go端结果格式一致）"""
+    path = data.get('path') or '.'
+    if not os.path.isabs(path):
+        path = os.path.join(shell.cwd, path)
+    path = os.path.normpath(path)
+    if not os.path.isdir(path):
+        raise NotADirectoryError(f"Not a directory: {path}")
+
+    patterns = data.get('patterns') or []
+    file_type = data.get('type') or 'any'
+    min_size = int(data.get('min_size', -1))
+    max_size = int(data.get('max_size', -1))
+    newer_than = float(data.get('newer_than') or 0)
+    older_than = float(data.get('older_than') or 0)
+    max_depth = int(data.get('max_depth') or 0)
+    max_scanned = int(data.get('max_scanned') or 200000)
+    max_collected = int(data.get('max_collected') or 20000)
+    limit = int(data.get('limit') or 100)
+    deadline = time.time() + float(data.get('timeout') or 20)
+    now = time.time()
+
+    result = {'success': True, 'entries': [], 'total': 0, 'scanned': 0, 'truncated': False, 'timed_out': False}
+    walker = walk_files(path, data.get('exclude'), not data.get('gitignore', False), data.get('skip_dirs'),
+                        max_depth if max_depth > 0 else -1)
+    for entry, rel, _ in walker:
+        result['scanned'] += 1
+        if result['scanned'] > max_scanned:
+            result['truncated'] = True
+            break
+        if result['scanned'] % 1000 == 0 and time.time() > deadline:
+            result['timed_out'] = True
+            break
+        try:
+            st = entry.stat(follow_symlinks=False)
+        except OSError:
+            continue
+        if entry.is_symlink():
+            kind = 'l'
+        elif entry.is_dir(follow_symlinks=False):
+            kind = 'd'
+        else:
+            kind = 'f'
+        if file_type != 'any' and kind != file_type:
+            continue
+        if patterns and not match_any_glob(patterns, rel):
+            continue
+        size = 0 if kind == 'd' else st.st_size
+        if min_size >= 0 and size < min_size:
+            continue
+        if max_size >= 0 and size > max_size:
+            continue
+        if newer_than > 0 and st.st_mtime < now - newer_than:
+            continue
+        if older_than > 0 and st.st_mtime > now - older_than:
+            continue
+        result['total'] += 1
+        if len(result['entries']) >= max_collected:
+            result['truncated'] = True
+            continue
+        result['entries'].append({'path': path.rstrip('/') + '/' + rel, 'type': kind, 'size': size, 'mtime': st.st_mtime})
+
+    sort_by = data.get('sort') or 'name'
+    if sort_by == 'mtime':
+        result['entries'].sort(key=lambda e: e['mtime'], reverse=True)
+    elif sort_by == 'size':
+        result['entries'].sort(key=lambda e: e['size'], reverse=True)
+    else:
+        result['entries'].sort(key=lambda e: e['path'])
+    result['entries'] = result['entries'][:limit]
+    return result
+
+def glob_paths(pattern):
+    """展开glob（支持 **），规则与Go端 globPaths 一致：
+    从不含通配符的前缀目录开始遍历，按相对路径匹配；不含 ** 时只遍历到模式的层数"""
+    parts = pattern.replace('\\', '/').split('/')
+    i = 0
+    while i < len(parts) and not any(c in parts[i] for c in '*?['):
+        i += 1
+    base = '/'.join(parts[:i]) or ('/' if pattern.startswith('/') else '.')
+    rest = '/'.join(parts[i:])
+    try:
+        regex = re.compile(glob_to_regex(rest))
+    except re.error:
+        return []
+    recursive = '**' in rest
+    max_depth = rest.count('/') + 1
+
+    paths = []
+    for root, dirs, files in os.walk(base):
+        rel_root = os.path.relpath(root, base).replace(os.sep, '/')
+        depth = 0 if rel_root == '.' else rel_root.count('/') + 1
+        for name in dirs + files:
+            rel = name if depth == 0 else rel_root + '/' + name
+            if regex.fullmatch(rel):
+                paths.append(os.path.join(base, rel))
+        if not recursive and depth + 1 >= max_depth:
+            dirs[:] = []
+    return sorted(paths)
+
+def handle_read_files(data):
+    """批量读取多个文件（支持glob），总大小超出预算的文件只报告不返回内容"""
+    max_bytes = int(data.get('max_bytes') or 256 * 1024)
+    max_files = int(data.get('max_files') or 50)
+
+    result = {'success': True, 'files': [], 'skipped': [], 'missing': []}
+    seen = set()
+    used = 0
+    for pattern in data.get('patterns') or []:
+        full = pattern if os.path.isabs(pattern) else os.path.join(shell.cwd, pattern)
+        if any(c in pattern for c in '*?['):
+            paths = glob_paths(full)
+        else:
+            paths = [full] if os.path.exists(full) else []
+        if not paths:
+            result['missing'].append(pattern)
+            continue
+        for path in paths:
+            path = os.path.normpath(path)
+            if path in seen:
+                continue
+            seen.add(path)
+            if os.path.isdir(path):
+                result['skipped'].append({'path': path, 'size': 0, 'reason': 'dir'})
+                continue
+            try:
+                size = os.path.getsize(path)
+            except OSError as e:
+                result['skipped'].append({'path': path, 'size': 0, 'reason': str(e)})
+                continue
+            if len(result['files']) >= max_files:
+                result['skipped'].append({'path': path, 'size': size, 'reason': 'count'})
+                continue
+            if used + size > max_bytes:
+                result['skipped'].append({'path': path, 'size': size, 'reason': 'size'})
+                continue
+            try:
+                with open(path, 'rb') as f:
+                    content = f.read()
+            except OSError as e:
+                result['skipped'].append({'path': path, 'size': size, 'reason': str(e)})
+                continue
+            used += len(content)
+            result['files'].append({
+                'path': path,
+                'size': len(content),
+                'content': base64.b64encode(content).decode('utf-8')
+            })
+    return result
+
+def handle_run(data):
+    """直接执行程序（argv不经过shell，输出不截断，供git/test等结构化工具使用）"""
+    argv = data['argv']
+    if not argv:
+        raise ValueError("Missing 'argv' parameter")
+    cwd = data.get('cwd') or shell.cwd
+    if not os.path.isabs(cwd):
+        cwd = os.path.join(shell.cwd, cwd)
+    timeout = int(data.get('timeout') or 60)
+
+    try:
+        result = subprocess.run(
+            argv,
+            cwd=cwd,
+            env=shell.env,
+            input=data.get('input', '').encode('utf-8'),
+            capture_output=True,
+            timeout=timeout
+        )
+    except subprocess.TimeoutExpired as e:
+        return {
+            'success': True,
+            'stdout': (e.stdout or b'').decode('utf-8', errors='replace'),
+            'stderr': (e.stderr or b'').decode('utf-8', errors='replace'),
+            'exit_code': -1,
+            'timed_out': True
+        }
+    except FileNotFoundError:
+        raise ValueError(f"命令不存在: {argv[0]}")
+
+    return {
+        'success': True,
+        'stdout': result.stdout.decode('utf-8', errors='replace'),
+        'stderr': result.stderr.decode('utf-8', errors='replace'),
+        'exit_code': result.returncode,
+        'timed_out': False
+    }
+
 def handle_client(client_socket):
     """处理JARVIS的请求（支持大数据）"""
     try:
@@ -345,6 +945,39 @@ def handle_client(client_socket):
             # 直接打包并发送tar流
             response = handle_tar_download(request['data'])
             
+        elif action == 'search':
+            response = handle_search(request['data'])
+            
+        elif action == 'read_files':
+            response = handle_read_files(request['data'])
+            
+        elif action == 'find':
+            response = handle_find(request['data'])
+            
+        elif action == 'run':
+            response = handle_run(request['data'])
+            
+        elif action == 'proc_start':
+            data = request['data']
+            process_id = background.start(data['command'], data.get('cwd'))
+            response = {'success': True, 'process_id': process_id}
+            
+        elif action == 'proc_input':
+            data = request['data']
+            background.send_input(data['process_id'], data.get('input', ''))
+            response = {'success': True}
+            
+        elif action == 'proc_output':
+            data = request['data']
+            response = background.read(data['process_id'], int(data.get('offset', -1)), int(data.get('max_lines', 100)))
+            
+        elif action == 'proc_kill':
+            background.kill(request['data']['process_id'])
+            response = {'success': True}
+            
+        elif action == 'proc_list':
+            response = {'success': True, 'processes': background.list()}
+            
         else:
             raise ValueError(f"Unknown action: {action}")
         
@@ -86,6 +86,32 @@ func (m *Manager) UndoOperation(toolCallID string) error {
 	return fmt.Errorf("未找到备份")
 }
 
+// FindBackup 按文件路径查找备份（远程文件为 path@machine）
+func (m *Manager) FindBackup(filePath string) (OperationBackup, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, backup := range m.backups {
+		if backup.FilePath == filePath {
+			return backup, true
+		}
+	}
+	return OperationBackup{}, false
+}
+
+// RemoveBackup 删除某个文件的备份（文件已由调用方恢复时使用）
+func (m *Manager) RemoveBackup(filePath string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, backup := range m.backups {
+		if backup.FilePath == filePath {
+			m.backups = append(m.backups[:i], m.backups[i+1:]...)
+			return
+		}
+	}
+}
+
 // CommitAll 提交所有操作（清空备份）
 func (m *Manager) CommitAll() {
 	m.mutex.Lock()
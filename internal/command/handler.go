@@ -2,8 +2,11 @@ package command
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
+	"ai_assistant/internal/mcp"
+	"ai_assistant/internal/memory"
 	"ai_assistant/internal/session"
 	"ai_assistant/internal/state"
 	"ai_assistant/internal/ui"
@@ -13,13 +16,17 @@ import (
 type Handler struct {
 	sessionManager *session.Manager
 	stateManager   *state.Manager
+	memoryManager  *memory.Manager
+	mcpManager     *mcp.Manager
 }
 
 // NewHandler 创建命令处理器
-func NewHandler(sm *session.Manager, stm *state.Manager) *Handler {
+func NewHandler(sm *session.Manager, stm *state.Manager, mm *memory.Manager, mcpm *mcp.Manager) *Handler {
 	return &Handler{
 		sessionManager: sm,
 		stateManager:   stm,
+		memoryManager:  mm,
+		mcpManager:     mcpm,
 	}
 }
 
@@ -57,6 +64,12 @@ func (h *Handler) Handle(input string) (bool, error) {
 		return true, h.handleInfect(args)
 	case "/machines":
 		return true, h.handleMachines()
+	case "/diagnostics":
+		return true, h.handleDiagnostics(args)
+	case "/memory", "/mem":
+		return true, h.handleMemory(args, input)
+	case "/mcp":
+		return true, h.handleMCP(args)
 	case "/help":
 		return true, h.handleHelp()
 	default:
@@ -264,6 +277,198 @@ func (h *Handler) handleMachines() error {
 	return nil
 }
 
+// handleDiagnostics 开关当前会话的编辑后自动诊断
+func (h *Handler) handleDiagnostics(args []string) error {
+	current := h.sessionManager.GetCurrentSession()
+	if current == nil {
+		return fmt.Errorf("没有活动会话")
+	}
+
+	if len(args) == 0 {
+		status := "关闭"
+		if current.AutoDiagnostics {
+			status = "开启"
+		}
+		ui.PrintInfo(fmt.Sprintf("编辑后自动诊断: %s（用法: /diagnostics on|off）", status))
+		return nil
+	}
+
+	var enabled bool
+	switch args[0] {
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	default:
+		return fmt.Errorf("用法: /diagnostics on|off")
+	}
+
+	if err := h.sessionManager.SetAutoDiagnostics(enabled); err != nil {
+		return err
+	}
+
+	if enabled {
+		ui.PrintSuccess("已开启：编辑Go文件后自动附带所在包的 build/vet 诊断")
+	} else {
+		ui.PrintSuccess("已关闭编辑后自动诊断")
+	}
+	return nil
+}
+
+// handleMemory 查看和编辑长期记忆
+// input 为原始输入，用于保留记忆内容中的空白
+func (h *Handler) handleMemory(args []string, input string) error {
+	if len(args) == 0 {
+		memories := h.memoryManager.List()
+		fmt.Println()
+		if len(memories) == 0 {
+			ui.PrintInfo("还没有长期记忆（对AI说\"记住…\"，或 /memory add <内容>）")
+			return nil
+		}
+		ui.PrintInfo(fmt.Sprintf("长期记忆（共 %d 条）：", len(memories)))
+		fmt.Println()
+		for _, mem := range memories {
+			fmt.Println("  " + mem.String())
+		}
+		fmt.Println()
+		return nil
+	}
+
+	usage := fmt.Errorf("用法: /memory [search <关键词> | add [machine|project] <内容> | edit <ID> <新内容> | del <ID>]")
+	switch args[0] {
+	case "search":
+		if len(args) < 2 {
+			return usage
+		}
+		memories := h.memoryManager.Search(strings.Join(args[1:], " "), "")
+		if len(memories) == 0 {
+			ui.PrintInfo("没有匹配的记忆")
+			return nil
+		}
+		fmt.Println()
+		for _, mem := range memories {
+			fmt.Println("  " + mem.String())
+		}
+		fmt.Println()
+		return nil
+
+	case "add":
+		if len(args) < 2 {
+			return usage
+		}
+		// 默认全局；machine/project 记在slot1机器（及其当前目录）上
+		scope, machineID, project := memory.ScopeGlobal, "", ""
+		skip := 2
+		if args[1] == memory.ScopeMachine || args[1] == memory.ScopeProject {
+			if len(args) < 3 {
+				return usage
+			}
+			scope = args[1]
+			machineID = "local"
+			if machine := h.stateManager.GetSlot1Machine(); machine != nil {
+				machineID = machine.ID
+			}
+			if scope == memory.ScopeProject {
+				project = h.stateManager.WorkDir(machineID)
+			}
+			skip = 3
+		}
+		mem, err := h.memoryManager.Add(scope, machineID, project, restOfInput(input, skip))
+		if err != nil {
+			return err
+		}
+		ui.PrintSuccess("已记住: " + mem.String())
+		return nil
+
+	case "edit":
+		if len(args) < 3 {
+			return usage
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			return fmt.Errorf("无效的记忆编号: %s", args[1])
+		}
+		if err := h.memoryManager.Update(id, restOfInput(input, 3)); err != nil {
+			return err
+		}
+		ui.PrintSuccess(fmt.Sprintf("已修改记忆 #%d", id))
+		return nil
+
+	case "del", "delete", "rm":
+		if len(args) < 2 {
+			return usage
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			return fmt.Errorf("无效的记忆编号: %s", args[1])
+		}
+		mem, err := h.memoryManager.Remove(id)
+		if err != nil {
+			return err
+		}
+		ui.PrintSuccess("已删除: " + mem.String())
+		return nil
+
+	default:
+		return usage
+	}
+}
+
+// restOfInput 跳过前 n 个字段后的原始文本
+func restOfInput(input string, n int) string {
+	rest := strings.TrimSpace(input)
+	for i := 0; i < n && rest != ""; i++ {
+		if idx := strings.IndexAny(rest, " \t"); idx >= 0 {
+			rest = strings.TrimSpace(rest[idx:])
+		} else {
+			rest = ""
+		}
+	}
+	return rest
+}
+
+// handleMCP 查看 MCP 服务器状态，restart 重新连接
+func (h *Handler) handleMCP(args []string) error {
+	if !h.mcpManager.HasServers() {
+		ui.PrintInfo("没有配置 MCP 服务器（在 config.json 的 mcp_servers 中添加）")
+		return nil
+	}
+
+	if len(args) > 0 {
+		if args[0] != "restart" || len(args) != 2 {
+			return fmt.Errorf("用法: /mcp [restart <名称>]")
+		}
+		if err := h.mcpManager.Restart(args[1]); err != nil {
+			return fmt.Errorf("重连 %s 失败: %v", args[1], err)
+		}
+		ui.PrintSuccess(fmt.Sprintf("已重新连接 %s", args[1]))
+		return nil
+	}
+
+	fmt.Println()
+	ui.PrintInfo("MCP 服务器：")
+	fmt.Println()
+	for _, s := range h.mcpManager.Status() {
+		switch {
+		case s.Disabled:
+			fmt.Printf("  - %s (%s) 已停用\n", s.Name, s.Transport)
+		case s.Connected:
+			fmt.Printf("  [✓] %s (%s) %s，%d 个工具\n", s.Name, s.Transport, s.Info, len(s.Tools))
+			if len(s.Tools) > 0 {
+				fmt.Printf("      %s\n", strings.Join(s.Tools, ", "))
+			}
+			if len(s.Skipped) > 0 {
+				fmt.Printf("      [!] 名称过长或重复未提供: %s\n", strings.Join(s.Skipped, ", "))
+			}
+		default:
+			fmt.Printf("  [✗] %s (%s) 未连接: %s\n", s.Name, s.Transport, s.Error)
+		}
+	}
+	fmt.Println()
+	ui.PrintInfo("工具以 mcp__<服务器>__<工具> 提供给AI；/mcp restart <名称> 重新连接")
+	return nil
+}
+
 // handleHelp 显示帮助
 func (h *Handler) handleHelp() error {
 	fmt.Println()
@@ -278,6 +483,9 @@ func (h *Handler) handleHelp() error {
 	fmt.Println("  /infect <host> <user> <password> [alias] - 寄生目标服务器")
 	fmt.Println("  /machines         - 列出所有控制机")
 	fmt.Println("  /delete <ID|序号> - 删除会话")
+	fmt.Println("  /diagnostics on|off - 编辑Go文件后自动运行 build/vet（当前会话）")
+	fmt.Println("  /memory           - 查看长期记忆（search/add/edit/del 管理）")
+	fmt.Println("  /mcp [restart <名称>] - 查看 MCP 服务器及其工具，重连指定服务器")
 	fmt.Println("  /help             - 显示此帮助")
 	fmt.Println("  /exit, /quit, /q  - 退出程序")
 	fmt.Println()
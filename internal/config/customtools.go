@@ -0,0 +1,108 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// CustomTool 用户在配置目录 tools.json 中声明的工具（把团队的运维脚本暴露给AI）
+type CustomTool struct {
+	Name        string                 `json:"name"`                 // 工具名，只能用字母、数字、_ 和 -
+	Description string                 `json:"description"`          // 给模型看的说明
+	Parameters  map[string]interface{} `json:"parameters,omitempty"` // JSON Schema（type=object），不写表示没有参数
+	Command     string                 `json:"command"`              // 命令模板，{参数名} 替换为转义后的参数值
+	Machine     string                 `json:"machine,omitempty"`    // 固定在某台机器执行，不写则在slot1机器
+	Approval    string                 `json:"approval,omitempty"`   // auto(自动执行)/ask(每次询问，默认)/deny(禁用)
+	Timeout     int                    `json:"timeout,omitempty"`    // 超时秒数，默认60
+	MaxOutput   int                    `json:"max_output,omitempty"` // 返回给模型的最大输出字节数，默认16KB
+}
+
+// 自定义工具默认值
+const (
+	DefaultCustomToolTimeout   = 60
+	DefaultCustomToolMaxOutput = 16 * 1024
+)
+
+var customToolNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// CustomToolsFile 自定义工具配置文件路径
+func CustomToolsFile() string {
+	return filepath.Join(ConfigDir, "tools.json")
+}
+
+// LoadCustomTools 读取 tools.json（不存在时返回空），格式有误的工具跳过并在 warnings 中说明
+func LoadCustomTools() ([]CustomTool, []string) {
+	data, err := os.ReadFile(CustomToolsFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []string{fmt.Sprintf("读取 %s 失败: %v", CustomToolsFile(), err)}
+	}
+
+	var declared []CustomTool
+	if err := json.Unmarshal(data, &declared); err != nil {
+		return nil, []string{fmt.Sprintf("解析 %s 失败（应为工具数组）: %v", CustomToolsFile(), err)}
+	}
+
+	var tools []CustomTool
+	var warnings []string
+	seen := make(map[string]bool)
+	for i, t := range declared {
+		if err := normalizeCustomTool(&t); err != nil {
+			warnings = append(warnings, fmt.Sprintf("第 %d 个工具（%s）已跳过: %v", i+1, t.Name, err))
+			continue
+		}
+		if seen[t.Name] {
+			warnings = append(warnings, fmt.Sprintf("工具 %s 重复声明，只保留第一个", t.Name))
+			continue
+		}
+		seen[t.Name] = true
+		tools = append(tools, t)
+	}
+	return tools, warnings
+}
+
+// normalizeCustomTool 校验必填项并补默认值
+func normalizeCustomTool(t *CustomTool) error {
+	if !customToolNameRe.MatchString(t.Name) {
+		return fmt.Errorf("name 只能包含字母、数字、_ 和 -（最长64）")
+	}
+	if t.Description == "" {
+		return fmt.Errorf("缺少 description")
+	}
+	if t.Command == "" {
+		return fmt.Errorf("缺少 command")
+	}
+
+	switch t.Approval {
+	case "":
+		t.Approval = "ask"
+	case "auto", "ask", "deny":
+	default:
+		return fmt.Errorf("approval 只能是 auto/ask/deny，而不是 %q", t.Approval)
+	}
+
+	if t.Parameters == nil {
+		t.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	if typ, _ := t.Parameters["type"].(string); typ != "object" {
+		return fmt.Errorf("parameters 必须是 type=object 的 JSON Schema")
+	}
+	if props, ok := t.Parameters["properties"]; ok {
+		if _, ok := props.(map[string]interface{}); !ok {
+			return fmt.Errorf("parameters.properties 必须是对象")
+		}
+	}
+
+	if t.Timeout <= 0 {
+		t.Timeout = DefaultCustomToolTimeout
+	}
+	if t.MaxOutput <= 0 {
+		t.MaxOutput = DefaultCustomToolMaxOutput
+	}
+	return nil
+}
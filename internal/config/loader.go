@@ -20,6 +20,37 @@ type Config struct {
 	ReasoningMode    string `json:"reasoning_mode"`   // "ask", "show", "hide"
 	BaiduSearchKey   string `json:"baidu_search_key"` // 百度搜索API Key（可选）
 	AgentAPIKey      string `json:"agent_api_key"`    // 寄生虫统一密钥
+
+	// 联网搜索（均可选，配置了哪个就启用哪个）
+	SearchProviders []string `json:"search_providers,omitempty"` // 搜索源及回退顺序，如 ["searxng","brave"]；留空按 baidu→searxng→brave→bing
+	SearxngURL      string   `json:"searxng_url,omitempty"`      // 自建SearXNG地址（需开启json格式），如 http://127.0.0.1:8888
+	BraveSearchKey  string   `json:"brave_search_key,omitempty"` // Brave Search API Key
+	BingSearchKey   string   `json:"bing_search_key,omitempty"`  // Bing Web Search API Key
+
+	// 编辑后的语法检查与格式化（.go 和 .json 内置，其他扩展名按需配置）
+	AutoFormat   bool                   `json:"auto_format,omitempty"`   // 编辑后自动格式化（gofmt 及配置了 format 的命令）
+	FileCheckers map[string]FileChecker `json:"file_checkers,omitempty"` // 按扩展名配置，如 {".py": {"check": "python3 -m py_compile {file}"}}
+
+	// 外部 MCP 服务器（按名称配置），其工具以 mcp__<名称>__<工具> 提供给模型
+	MCPServers map[string]MCPServer `json:"mcp_servers,omitempty"`
+}
+
+// FileChecker 某种文件的检查/格式化命令，{file} 会替换为文件路径（不写则追加在末尾）
+type FileChecker struct {
+	Check  string `json:"check,omitempty"`  // 语法检查命令，非零退出视为有错误，如 "jq . {file}"
+	Format string `json:"format,omitempty"` // 原地格式化命令，如 "black -q {file}"
+}
+
+// MCPServer 一个 MCP 服务器：配置 command 时以子进程方式通过 stdio 连接，配置 url 时使用 Streamable HTTP
+type MCPServer struct {
+	Command     string            `json:"command,omitempty"`      // 启动命令，如 "npx"
+	Args        []string          `json:"args,omitempty"`         // 命令参数
+	Env         map[string]string `json:"env,omitempty"`          // 额外的环境变量
+	URL         string            `json:"url,omitempty"`          // Streamable HTTP 端点，如 http://127.0.0.1:8000/mcp
+	Headers     map[string]string `json:"headers,omitempty"`      // HTTP 请求头（如 Authorization）
+	AutoApprove []string          `json:"auto_approve,omitempty"` // 无需批准的工具名（只读查询类），["*"] 表示全部
+	Timeout     int               `json:"timeout,omitempty"`      // 单次调用超时秒数，默认120
+	Disabled    bool              `json:"disabled,omitempty"`     // 暂时停用
 }
 
 // 默认配置
@@ -113,6 +144,21 @@ func Initialize() error {
 	return nil
 }
 
+// Load 只加载已有配置，不引导创建（mcp-serve 等标准输入输出被占用的模式使用）
+func Load() error {
+	ConfigDir = GetConfigDir()
+	ConfigFile = filepath.Join(ConfigDir, "config.json")
+	HistoryFile = filepath.Join(ConfigDir, "chat_history.json")
+
+	if _, err := os.Stat(ConfigFile); os.IsNotExist(err) {
+		return fmt.Errorf("配置文件不存在: %s（先直接运行一次完成初始配置）", ConfigFile)
+	}
+	if err := loadConfig(); err != nil {
+		return fmt.Errorf("加载配置失败: %v", err)
+	}
+	return nil
+}
+
 // setupConfig 引导用户创建配置
 func setupConfig() error {
 	config := defaultConfig
@@ -184,7 +230,7 @@ func setupConfig() error {
 	// 5. 百度搜索API Key（可选）
 	fmt.Println()
 	fmt.Println("百度搜索API Key（可选，用于联网搜索功能）: ")
-	fmt.Println("  留空则禁用搜索功能，直接回车跳过")
+	fmt.Println("  留空则跳过，之后也可在配置文件中设置 searxng_url / brave_search_key / bing_search_key")
 	fmt.Print("请输入: ")
 
 	var searchKey string
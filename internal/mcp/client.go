@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	appconfig "ai_assistant/internal/config"
+)
+
+// ClientName 向服务器报告的客户端名称
+const ClientName = "jarvis"
+
+// Client 与一个 MCP 服务器的连接
+type Client struct {
+	name string
+	t    transport
+
+	nextID     int64
+	toolsDirty atomic.Bool // 收到 tools/list_changed，下次取工具列表时重新获取
+	serverName string
+	serverVer  string
+}
+
+// connect 建立连接并完成 initialize 握手
+func connect(ctx context.Context, name string, cfg appconfig.MCPServer) (*Client, error) {
+	c := &Client{name: name}
+
+	switch {
+	case cfg.Command != "":
+		t, err := startStdio(cfg.Command, cfg.Args, cfg.Env, c.handleMessage)
+		if err != nil {
+			return nil, fmt.Errorf("启动 %s 失败: %v", cfg.Command, err)
+		}
+		c.t = t
+	case cfg.URL != "":
+		c.t = newHTTPTransport(cfg.URL, cfg.Headers, c.handleMessage)
+	default:
+		return nil, fmt.Errorf("需要配置 command（stdio）或 url（HTTP）")
+	}
+
+	var result initializeResult
+	err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": ClientName, "version": "1.0"},
+	}, &result)
+	if err != nil {
+		c.t.close()
+		return nil, fmt.Errorf("initialize 失败: %v", err)
+	}
+	c.serverName = result.ServerInfo.Name
+	c.serverVer = result.ServerInfo.Version
+
+	if err := c.t.notify(ctx, "notifications/initialized", nil); err != nil {
+		c.t.close()
+		return nil, fmt.Errorf("发送 initialized 失败: %v", err)
+	}
+	return c, nil
+}
+
+// handleMessage 处理服务器发起的通知和请求
+func (c *Client) handleMessage(msg *rpcMessage) *rpcResponse {
+	switch msg.Method {
+	case "notifications/tools/list_changed":
+		c.toolsDirty.Store(true)
+		return nil
+	case "ping":
+		return &rpcResponse{JSONRPC: "2.0", ID: msg.ID, Result: map[string]interface{}{}}
+	}
+	if msg.isRequest() {
+		// sampling、roots 等能力没有声明，服务器不应发起
+		return &rpcResponse{JSONRPC: "2.0", ID: msg.ID, Error: &rpcError{Code: -32601, Message: "method not found: " + msg.Method}}
+	}
+	return nil
+}
+
+// call 发送请求并把结果解析到 result
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	id := atomic.AddInt64(&c.nextID, 1)
+	msg, err := c.t.roundTrip(ctx, rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		return fmt.Errorf("解析 %s 结果失败: %v", method, err)
+	}
+	return nil
+}
+
+// listTools 获取全部工具（处理分页）
+func (c *Client) listTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page listToolsResult
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// callTool 调用工具
+func (c *Client) callTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", map[string]interface{}{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) close() {
+	c.t.close()
+}
@@ -0,0 +1,335 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appconfig "ai_assistant/internal/config"
+)
+
+// 连接和调用的限制
+const (
+	ConnectTimeout     = 30 * time.Second
+	DefaultCallTimeout = 120 * time.Second
+	ToolPrefix         = "mcp__" // 提供给模型的工具名：mcp__<服务器>__<工具>
+	maxToolNameLen     = 64      // OpenAI 兼容接口对工具名的长度限制
+)
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// ToolRef 提供给模型的一个 MCP 工具
+type ToolRef struct {
+	Name        string // 带命名空间的名称
+	Server      string
+	Tool        Tool
+	AutoApprove bool
+}
+
+// Server 一个已配置的服务器及其连接状态
+type Server struct {
+	Name    string
+	Config  appconfig.MCPServer
+	client  *Client
+	tools   []ToolRef
+	skipped []string // 名称过长/重复而没有提供给模型的工具
+	err     error
+}
+
+// ServerStatus /mcp 显示的服务器状态
+type ServerStatus struct {
+	Name      string
+	Transport string // stdio / http
+	Connected bool
+	Error     string
+	Info      string // 服务器自报的名称和版本
+	Tools     []string
+	Skipped   []string
+	Disabled  bool
+}
+
+// Manager 管理所有 MCP 服务器连接
+type Manager struct {
+	mu      sync.Mutex
+	servers []*Server
+}
+
+// NewManager 按配置创建（不立即连接，调用 Start）
+func NewManager(configs map[string]appconfig.MCPServer) *Manager {
+	m := &Manager{}
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.servers = append(m.servers, &Server{Name: name, Config: configs[name]})
+	}
+	return m
+}
+
+// HasServers 是否配置了服务器
+func (m *Manager) HasServers() bool {
+	return m != nil && len(m.servers) > 0
+}
+
+// Start 并行连接所有启用的服务器，连接失败的记录错误（/mcp 查看，/mcp restart 重试）
+func (m *Manager) Start() {
+	var wg sync.WaitGroup
+	for _, s := range m.servers {
+		if s.Config.Disabled {
+			continue
+		}
+		wg.Add(1)
+		go func(s *Server) {
+			defer wg.Done()
+			m.connectServer(s)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// connectServer 连接服务器并获取工具列表
+func (m *Manager) connectServer(s *Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+	defer cancel()
+
+	client, err := connect(ctx, s.Name, s.Config)
+	var tools []Tool
+	if err == nil {
+		if tools, err = client.listTools(ctx); err != nil {
+			client.close()
+			err = fmt.Errorf("tools/list 失败: %v", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		s.client, s.tools, s.skipped, s.err = nil, nil, nil, err
+		return
+	}
+	s.client, s.err = client, nil
+	s.tools, s.skipped = buildToolRefs(s, tools)
+}
+
+// buildToolRefs 生成带命名空间的工具名，过长或冲突的跳过
+func buildToolRefs(s *Server, tools []Tool) ([]ToolRef, []string) {
+	var refs []ToolRef
+	var skipped []string
+	seen := make(map[string]bool)
+	prefix := ToolPrefix + invalidNameChars.ReplaceAllString(s.Name, "_") + "__"
+	for _, t := range tools {
+		name := prefix + invalidNameChars.ReplaceAllString(t.Name, "_")
+		if len(name) > maxToolNameLen || seen[name] {
+			skipped = append(skipped, t.Name)
+			continue
+		}
+		seen[name] = true
+		refs = append(refs, ToolRef{
+			Name:        name,
+			Server:      s.Name,
+			Tool:        t,
+			AutoApprove: autoApproved(s.Config.AutoApprove, t.Name),
+		})
+	}
+	return refs, skipped
+}
+
+// autoApproved 工具是否在 auto_approve 列表中
+func autoApproved(list []string, tool string) bool {
+	for _, name := range list {
+		if name == "*" || name == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshTools 服务器通知工具列表变化后重新获取
+func (m *Manager) refreshTools(s *Server) {
+	m.mu.Lock()
+	client := s.client
+	m.mu.Unlock()
+	if client == nil || !client.toolsDirty.CompareAndSwap(true, false) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+	defer cancel()
+	tools, err := client.listTools(ctx)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	s.tools, s.skipped = buildToolRefs(s, tools)
+	m.mu.Unlock()
+}
+
+// Tools 所有已连接服务器的工具
+func (m *Manager) Tools() []ToolRef {
+	if m == nil {
+		return nil
+	}
+	for _, s := range m.servers {
+		m.refreshTools(s)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var refs []ToolRef
+	for _, s := range m.servers {
+		refs = append(refs, s.tools...)
+	}
+	return refs
+}
+
+// Lookup 按带命名空间的名称查找工具
+func (m *Manager) Lookup(name string) (ToolRef, bool) {
+	if m == nil || !strings.HasPrefix(name, ToolPrefix) {
+		return ToolRef{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.servers {
+		for _, ref := range s.tools {
+			if ref.Name == name {
+				return ref, true
+			}
+		}
+	}
+	return ToolRef{}, false
+}
+
+// Call 调用工具；发送前发现连接已断开（如stdio进程已退出）时自动重连一次再试
+func (m *Manager) Call(ref ToolRef, args map[string]interface{}) (*CallToolResult, error) {
+	s := m.server(ref.Server)
+	if s == nil {
+		return nil, fmt.Errorf("未配置 MCP 服务器 %s", ref.Server)
+	}
+
+	timeout := DefaultCallTimeout
+	if s.Config.Timeout > 0 {
+		timeout = time.Duration(s.Config.Timeout) * time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		m.mu.Lock()
+		client, connErr := s.client, s.err
+		m.mu.Unlock()
+		if client == nil {
+			if attempt > 0 || connErr == nil {
+				return nil, fmt.Errorf("服务器 %s 未连接", s.Name)
+			}
+			m.connectServer(s)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		result, err := client.callTool(ctx, ref.Tool.Name, args)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("调用超时（%v）", timeout)
+		}
+		if !errors.Is(err, errClosed) || attempt > 0 {
+			return result, err
+		}
+
+		// 请求没有发出：关闭旧连接后重连重试
+		client.close()
+		m.connectServer(s)
+		m.mu.Lock()
+		reconnectErr := s.err
+		m.mu.Unlock()
+		if reconnectErr != nil {
+			return nil, fmt.Errorf("%v；重连失败: %v", err, reconnectErr)
+		}
+	}
+}
+
+// Restart 重新连接指定服务器（启动时连接失败的也用它重试）
+func (m *Manager) Restart(name string) error {
+	s := m.server(name)
+	if s == nil {
+		return fmt.Errorf("未配置 MCP 服务器 %s", name)
+	}
+	m.mu.Lock()
+	old := s.client
+	s.client = nil
+	m.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+
+	m.connectServer(s)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return s.err
+}
+
+// Status 所有服务器的状态
+func (m *Manager) Status() []ServerStatus {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var list []ServerStatus
+	for _, s := range m.servers {
+		st := ServerStatus{
+			Name:      s.Name,
+			Transport: "stdio",
+			Connected: s.client != nil,
+			Skipped:   s.skipped,
+			Disabled:  s.Config.Disabled,
+		}
+		if s.Config.Command == "" && s.Config.URL != "" {
+			st.Transport = "http"
+		}
+		if s.err != nil {
+			st.Error = s.err.Error()
+		}
+		if s.client != nil {
+			st.Info = strings.TrimSpace(s.client.serverName + " " + s.client.serverVer)
+		}
+		for _, ref := range s.tools {
+			st.Tools = append(st.Tools, ref.Tool.Name)
+		}
+		list = append(list, st)
+	}
+	return list
+}
+
+// Close 断开所有服务器
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	var clients []*Client
+	for _, s := range m.servers {
+		if s.client != nil {
+			clients = append(clients, s.client)
+			s.client = nil
+		}
+	}
+	m.mu.Unlock()
+	for _, c := range clients {
+		c.close()
+	}
+}
+
+func (m *Manager) server(name string) *Server {
+	for _, s := range m.servers {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
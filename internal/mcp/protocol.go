@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion 客户端请求的 MCP 协议版本
+const ProtocolVersion = "2025-03-26"
+
+// rpcRequest JSON-RPC 请求或通知（通知没有 id）
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *int64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// rpcMessage 收到的任意 JSON-RPC 消息：响应、服务器发起的请求或通知
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcResponse 回复服务器发起的请求
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError JSON-RPC 错误
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// isResponse 是否是对我方请求的响应
+func (m *rpcMessage) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// isRequest 是否是服务器发起的请求（需要回复）
+func (m *rpcMessage) isRequest() bool {
+	return m.Method != "" && len(m.ID) > 0
+}
+
+// Tool 服务器提供的工具
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+	Annotations *ToolAnnotations       `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations 工具的提示信息（由服务器声明，不可完全信任）
+type ToolAnnotations struct {
+	Title        string `json:"title,omitempty"`
+	ReadOnlyHint *bool  `json:"readOnlyHint,omitempty"`
+}
+
+// Content 工具结果中的一段内容
+type Content struct {
+	Type     string           `json:"type"` // text/image/audio/resource/resource_link
+	Text     string           `json:"text,omitempty"`
+	Data     string           `json:"data,omitempty"` // image/audio 的 base64
+	MimeType string           `json:"mimeType,omitempty"`
+	URI      string           `json:"uri,omitempty"` // resource_link
+	Name     string           `json:"name,omitempty"`
+	Resource *ResourceContent `json:"resource,omitempty"`
+}
+
+// ResourceContent 内嵌资源
+type ResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// CallToolResult tools/call 的结果
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// initializeResult initialize 的结果
+type initializeResult struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	ServerInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+}
+
+// listToolsResult tools/list 的一页结果
+type listToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
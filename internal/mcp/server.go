@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// supportedVersions 服务器模式接受的协议版本（客户端请求其中之一时按它的版本回复）
+var supportedVersions = []string{"2025-06-18", ProtocolVersion, "2024-11-05"}
+
+// ToolHandler 服务器模式下提供工具的一方
+type ToolHandler interface {
+	// ListTools 对外提供的工具
+	ListTools() []Tool
+	// CallTool 执行工具，返回false表示没有这个工具
+	CallTool(name string, args map[string]interface{}) (*CallToolResult, bool)
+}
+
+// ServerInfo 服务器自报的名称和版本
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// server 一个 stdio 连接上的服务器状态
+type server struct {
+	info    ServerInfo
+	handler ToolHandler
+
+	writeMu sync.Mutex
+	out     io.Writer
+	callMu  sync.Mutex // 工具调用共用持久Shell和机器状态，逐个执行
+	wg      sync.WaitGroup
+}
+
+// Serve 以 stdio 方式（每行一条 JSON-RPC 消息）提供 MCP 服务，直到输入结束
+// ping、tools/list 在工具执行期间也会立即回复
+func Serve(in io.Reader, out io.Writer, info ServerInfo, handler ToolHandler) error {
+	s := &server{info: info, handler: handler, out: out}
+	reader := bufio.NewReader(in)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			s.handleLine(line)
+		}
+		if err == io.EOF {
+			s.wg.Wait()
+			return nil
+		}
+		if err != nil {
+			s.wg.Wait()
+			return err
+		}
+	}
+}
+
+// handleLine 处理一条消息（请求在独立的goroutine中执行）
+func (s *server) handleLine(line []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		if len(bytes.TrimSpace(line)) > 0 {
+			s.write(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: -32700, Message: "parse error"}})
+		}
+		return
+	}
+	if !msg.isRequest() {
+		// 通知（initialized、cancelled）和对我方的响应都不需要处理
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		result, rpcErr := s.dispatch(&msg)
+		if rpcErr != nil {
+			s.write(rpcResponse{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr})
+			return
+		}
+		s.write(rpcResponse{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	}()
+}
+
+// dispatch 执行请求，返回结果或 JSON-RPC 错误
+func (s *server) dispatch(msg *rpcMessage) (interface{}, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		version := ProtocolVersion
+		for _, v := range supportedVersions {
+			if v == params.ProtocolVersion {
+				version = v
+			}
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      s.info,
+		}, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		return map[string]interface{}{"tools": s.handler.ListTools()}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params.Name == "" {
+			return nil, &rpcError{Code: -32602, Message: "invalid params: 需要 name 和 arguments"}
+		}
+		if params.Arguments == nil {
+			params.Arguments = map[string]interface{}{}
+		}
+
+		s.callMu.Lock()
+		result, ok := s.callTool(params.Name, params.Arguments)
+		s.callMu.Unlock()
+		if !ok {
+			return nil, &rpcError{Code: -32602, Message: "unknown tool: " + params.Name}
+		}
+		return result, nil
+
+	default:
+		return nil, &rpcError{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+}
+
+// callTool 执行工具，工具内部 panic（如参数类型不对）时作为错误结果返回，不让服务器退出
+func (s *server) callTool(name string, args map[string]interface{}) (result *CallToolResult, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, ok = ErrorResult(fmt.Sprintf("[✗] 执行 %s 出错（参数是否完整？）: %v", name, r)), true
+		}
+	}()
+	return s.handler.CallTool(name, args)
+}
+
+func (s *server) write(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+// TextResult 文本结果
+func TextResult(text string) *CallToolResult {
+	return &CallToolResult{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// ErrorResult 执行失败的文本结果
+func ErrorResult(text string) *CallToolResult {
+	result := TextResult(text)
+	result.IsError = true
+	return result
+}
@@ -0,0 +1,402 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxStderrTail 保留 stdio 服务器 stderr 的最后多少字节，用于报错
+const MaxStderrTail = 4096
+
+// errClosed 请求发出前发现连接已断开（进程退出、会话失效），可以重连后重试
+var errClosed = errors.New("连接已断开")
+
+// transport 与服务器交换 JSON-RPC 消息
+type transport interface {
+	// roundTrip 发送请求并等待对应的响应
+	roundTrip(ctx context.Context, req rpcRequest) (*rpcMessage, error)
+	// notify 发送通知（没有响应）
+	notify(ctx context.Context, method string, params interface{}) error
+	close() error
+}
+
+// messageHandler 处理服务器发来的通知和请求，请求返回回复内容
+type messageHandler func(msg *rpcMessage) *rpcResponse
+
+// ==================== stdio ====================
+
+// stdioTransport 子进程方式：每行一条 JSON-RPC 消息
+type stdioTransport struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	handler messageHandler
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[string]chan *rpcMessage
+	done    chan struct{}
+	exitErr error
+
+	stderrMu sync.Mutex
+	stderr   []byte
+}
+
+// startStdio 启动服务器进程
+func startStdio(command string, args []string, env map[string]string, handler messageHandler) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		handler: handler,
+		pending: make(map[string]chan *rpcMessage),
+		done:    make(chan struct{}),
+	}
+	go t.readStderr(stderr)
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+// readLoop 读取服务器输出，分发响应；进程退出时唤醒所有等待中的请求
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			t.dispatch(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	waitErr := t.cmd.Wait()
+	t.mu.Lock()
+	t.exitErr = waitErr
+	close(t.done)
+	t.mu.Unlock()
+}
+
+// dispatch 处理一条消息
+func (t *stdioTransport) dispatch(line []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		// 有的服务器会往 stdout 打日志，忽略非 JSON 行
+		return
+	}
+
+	if msg.isResponse() {
+		t.mu.Lock()
+		ch := t.pending[string(msg.ID)]
+		delete(t.pending, string(msg.ID))
+		t.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+		return
+	}
+
+	if reply := t.handler(&msg); reply != nil && msg.isRequest() {
+		t.write(reply)
+	}
+}
+
+// readStderr 保留 stderr 的末尾
+func (t *stdioTransport) readStderr(stderr io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := stderr.Read(buf)
+		if n > 0 {
+			t.stderrMu.Lock()
+			t.stderr = append(t.stderr, buf[:n]...)
+			if len(t.stderr) > MaxStderrTail {
+				t.stderr = t.stderr[len(t.stderr)-MaxStderrTail:]
+			}
+			t.stderrMu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stderrTail stderr 的最后几行，附在错误信息后
+func (t *stdioTransport) stderrTail() string {
+	t.stderrMu.Lock()
+	defer t.stderrMu.Unlock()
+	return strings.TrimSpace(string(t.stderr))
+}
+
+// closedError 进程退出的原因；请求已发出时不包装 errClosed（工具可能已经执行，不能自动重试）
+func (t *stdioTransport) closedError(sent bool) error {
+	t.mu.Lock()
+	exitErr := t.exitErr
+	t.mu.Unlock()
+
+	msg := "服务器进程已退出"
+	if exitErr != nil {
+		msg += fmt.Sprintf("（%v）", exitErr)
+	}
+	if tail := t.stderrTail(); tail != "" {
+		msg += "，stderr:\n" + tail
+	}
+	if sent {
+		return fmt.Errorf("等待结果时%s", msg)
+	}
+	return fmt.Errorf("%w: %s", errClosed, msg)
+}
+
+func (t *stdioTransport) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req rpcRequest) (*rpcMessage, error) {
+	key := fmt.Sprint(*req.ID)
+	ch := make(chan *rpcMessage, 1)
+
+	t.mu.Lock()
+	select {
+	case <-t.done:
+		t.mu.Unlock()
+		return nil, t.closedError(false)
+	default:
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(req); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, t.closedError(false)
+	}
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-t.done:
+		return nil, t.closedError(true)
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		// 告诉服务器放弃这个请求
+		t.write(rpcRequest{JSONRPC: "2.0", Method: "notifications/cancelled",
+			Params: map[string]interface{}{"requestId": *req.ID, "reason": "timeout"}})
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params interface{}) error {
+	return t.write(rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// close 关闭 stdin 让服务器自行退出，超时则结束进程
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+		<-t.done
+	}
+	return nil
+}
+
+// ==================== Streamable HTTP ====================
+
+// httpTransport Streamable HTTP：每条消息一个 POST，响应为 JSON 或 SSE 流
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	handler messageHandler
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+func newHTTPTransport(url string, headers map[string]string, handler messageHandler) *httpTransport {
+	return &httpTransport{
+		url:     url,
+		headers: headers,
+		handler: handler,
+		client:  &http.Client{},
+	}
+}
+
+// post 发送一条消息，返回 HTTP 响应（调用方负责关闭 Body）
+func (t *httpTransport) post(ctx context.Context, v interface{}) (*http.Response, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	t.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && t.hasSession() {
+		// 会话已过期，需要重新 initialize
+		resp.Body.Close()
+		t.mu.Lock()
+		t.sessionID = ""
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%w: 服务器会话已失效", errClosed)
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request) {
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+	req.Header.Set("MCP-Protocol-Version", ProtocolVersion)
+}
+
+func (t *httpTransport) hasSession() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID != ""
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req rpcRequest) (*rpcMessage, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wantID := fmt.Sprint(*req.ID)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return t.readEventStream(ctx, resp.Body, wantID)
+	}
+
+	var msg rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return &msg, nil
+}
+
+// readEventStream 读取 SSE 流直到收到对应请求的响应，期间的通知和服务器请求交给 handler
+func (t *httpTransport) readEventStream(ctx context.Context, body io.Reader, wantID string) (*rpcMessage, error) {
+	reader := bufio.NewReader(body)
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && data.Len() > 0:
+			// 空行表示一个事件结束
+			var msg rpcMessage
+			if json.Unmarshal([]byte(data.String()), &msg) == nil {
+				if msg.isResponse() && string(msg.ID) == wantID {
+					return &msg, nil
+				}
+				if reply := t.handler(&msg); reply != nil && msg.isRequest() {
+					if r, err := t.post(ctx, reply); err == nil {
+						r.Body.Close()
+					}
+				}
+			}
+			data.Reset()
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("响应流在返回结果前结束: %v", err)
+		}
+	}
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params interface{}) error {
+	resp, err := t.post(ctx, rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// close 结束服务器上的会话（服务器可能不支持，忽略错误）
+func (t *httpTransport) close() error {
+	if !t.hasSession() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	if resp, err := t.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package mcpserve
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"ai_assistant/internal/approval"
+	"ai_assistant/internal/backup"
+	appconfig "ai_assistant/internal/config"
+	"ai_assistant/internal/mcp"
+	"ai_assistant/internal/process"
+	"ai_assistant/internal/state"
+	"ai_assistant/internal/tools"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// exposedTools 对外提供的内置工具
+var exposedTools = map[string]bool{
+	"file_operation":  true,
+	"run_command":     true,
+	"sync":            true,
+	"terminal_manage": true,
+}
+
+// listMachinesTool 列出受管机器（只在 mcp-serve 模式提供）
+var listMachinesTool = mcp.Tool{
+	Name:        "list_machines",
+	Description: "列出可操作的机器（本机和已寄生的服务器）及终端槽位。其他工具的 machine 参数填这里的机器ID，不填则在 slot1 的机器上执行。",
+	InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+}
+
+// handler 把 MCP 工具调用转给工具执行器，按交互模式相同的策略批准
+type handler struct {
+	executor     *tools.ExecutorSimplified
+	stateManager *state.Manager
+	approvalMode string // reject / tty
+	nextID       int
+}
+
+// Run mcp-serve 模式入口：通过 stdio 向其他 MCP 客户端（编辑器、其他Agent）提供机器和文件工具
+func Run(args []string) int {
+	fs := flag.NewFlagSet("mcp-serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	approvalMode := fs.String("approval", "reject", "需要批准的操作（修改文件、非只读命令等）：reject 直接拒绝，tty 在当前终端询问")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *approvalMode != "reject" && *approvalMode != "tty" {
+		fmt.Fprintf(os.Stderr, "[✗] --approval 只能是 reject 或 tty\n")
+		return 2
+	}
+
+	// 标准输出留给协议，其他输出（调试信息等）改到标准错误
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+
+	if err := appconfig.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "[✗] %v\n", err)
+		return 1
+	}
+
+	sm := state.NewManager()
+	h := &handler{
+		executor:     tools.NewExecutorSimplified(process.NewManager(), backup.NewManager(), sm, nil, nil),
+		stateManager: sm,
+		approvalMode: *approvalMode,
+	}
+
+	fmt.Fprintf(os.Stderr, "[i] mcp-serve 已启动（需要批准的操作: %s），等待客户端请求...\n", *approvalMode)
+	if err := mcp.Serve(os.Stdin, protocolOut, mcp.ServerInfo{Name: "jarvis", Version: "1.0"}, h); err != nil {
+		fmt.Fprintf(os.Stderr, "[✗] %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// ListTools 内置工具中对外提供的部分，加上 list_machines
+func (h *handler) ListTools() []mcp.Tool {
+	list := []mcp.Tool{listMachinesTool}
+	for _, t := range tools.GetToolsSimplified() {
+		if !exposedTools[t.Function.Name] {
+			continue
+		}
+		schema, _ := t.Function.Parameters.(map[string]interface{})
+		list = append(list, mcp.Tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	return list
+}
+
+// CallTool 按批准策略执行工具
+func (h *handler) CallTool(name string, args map[string]interface{}) (*mcp.CallToolResult, bool) {
+	if name == listMachinesTool.Name {
+		return mcp.TextResult(h.listMachines()), true
+	}
+	if !exposedTools[name] {
+		return nil, false
+	}
+
+	data, _ := json.Marshal(args)
+	h.nextID++
+	tc := openai.ToolCall{
+		ID:       fmt.Sprintf("mcp_%d", h.nextID),
+		Type:     openai.ToolTypeFunction,
+		Function: openai.FunctionCall{Name: name, Arguments: string(data)},
+	}
+
+	switch approval.Classify(tc, h.executor) {
+	case approval.Reject:
+		return mcp.ErrorResult("[✗] 命令在黑名单中（需要TTY交互的程序无法在持久Shell中运行），已拒绝"), true
+	case approval.NeedApproval:
+		if h.approvalMode != "tty" {
+			return mcp.ErrorResult("[✗] 该操作需要用户批准，mcp-serve 以 --approval=reject 运行，已拒绝（查询类操作不受影响）"), true
+		}
+		if ok, reason := askOnTTY(tc); !ok {
+			return mcp.ErrorResult("[✗] 操作未获批准: " + reason), true
+		}
+	}
+
+	result := h.executor.Execute(tc)
+	if strings.HasPrefix(result, "[✗]") {
+		return mcp.ErrorResult(result), true
+	}
+	// 命令输出只记在终端快照里（交互模式放在系统提示词中），这里直接附上
+	if name == "run_command" || name == "terminal_manage" {
+		result += "\n\n【终端快照】\n" + h.stateManager.GetTerminalSnapshot()
+	}
+	return mcp.TextResult(result), true
+}
+
+// listMachines 机器列表和终端槽位
+func (h *handler) listMachines() string {
+	var sb strings.Builder
+	sb.WriteString("机器:\n")
+	for _, m := range h.stateManager.Machines() {
+		addr := m.Host
+		if m.Port > 0 {
+			addr = fmt.Sprintf("%s:%d", m.Host, m.Port)
+		}
+		line := fmt.Sprintf("  %s  [%s]", m.ID, m.Type)
+		if addr != "" {
+			line += "  " + addr
+		}
+		if m.Description != "" {
+			line += "  " + m.Description
+		}
+		if dir := h.stateManager.WorkDir(m.ID); dir != "" {
+			line += "  当前目录: " + dir
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\n终端槽位:\n")
+	for _, line := range strings.Split(strings.TrimSpace(h.stateManager.GetTerminalStatus()), "\n") {
+		sb.WriteString("  " + line + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// askOnTTY 在运行 mcp-serve 的终端上询问用户（标准输入输出已被协议占用，直接打开终端设备）
+func askOnTTY(tc openai.ToolCall) (bool, string) {
+	in, out := "/dev/tty", "/dev/tty"
+	if runtime.GOOS == "windows" {
+		in, out = "CONIN$", "CONOUT$"
+	}
+	ttyIn, err := os.Open(in)
+	if err != nil {
+		return false, fmt.Sprintf("没有可用的终端询问用户（%v）", err)
+	}
+	defer ttyIn.Close()
+	ttyOut, err := os.OpenFile(out, os.O_WRONLY, 0)
+	if err != nil {
+		return false, fmt.Sprintf("没有可用的终端询问用户（%v）", err)
+	}
+	defer ttyOut.Close()
+
+	fmt.Fprintf(ttyOut, "\n[!] MCP 客户端请求执行需要批准的操作：\n  %s(%s)\n批准? (y/n，默认n): ", tc.Function.Name, tc.Function.Arguments)
+	answer, _ := bufio.NewReader(ttyIn).ReadString('\n')
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	if answer == "y" || answer == "yes" {
+		return true, ""
+	}
+	return false, "用户拒绝"
+}
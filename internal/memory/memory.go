@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appconfig "ai_assistant/internal/config"
+)
+
+// 记忆范围
+const (
+	ScopeGlobal  = "global"  // 所有机器、所有项目通用
+	ScopeMachine = "machine" // 某台机器的事实，如 nginx 装在 /opt
+	ScopeProject = "project" // 某台机器上某个项目目录的事实，如 用 make release 发布
+)
+
+// Memory 一条记忆
+type Memory struct {
+	ID        int       `json:"id"`
+	Scope     string    `json:"scope"`
+	Machine   string    `json:"machine,omitempty"` // machine/project 范围使用
+	Project   string    `json:"project,omitempty"` // project 范围使用，项目根目录
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Location 记忆所属位置的描述，如 [web-2] /opt/app
+func (m *Memory) Location() string {
+	switch m.Scope {
+	case ScopeMachine:
+		return fmt.Sprintf("[%s]", m.Machine)
+	case ScopeProject:
+		return fmt.Sprintf("[%s] %s", m.Machine, m.Project)
+	default:
+		return "[全局]"
+	}
+}
+
+// String 单行显示，如 #3 [web-2] nginx 装在 /opt/nginx
+func (m *Memory) String() string {
+	return fmt.Sprintf("#%d %s %s", m.ID, m.Location(), m.Content)
+}
+
+// WorkContext 一台激活机器及其当前目录，用于挑选相关记忆
+type WorkContext struct {
+	Machine string
+	Dir     string
+}
+
+// Manager 记忆管理器
+type Manager struct {
+	memories []Memory
+	nextID   int
+	file     string
+	mutex    sync.Mutex
+}
+
+// NewManager 创建记忆管理器（从配置目录加载）
+func NewManager() (*Manager, error) {
+	m := &Manager{
+		file:   filepath.Join(appconfig.ConfigDir, "memories.json"),
+		nextID: 1,
+	}
+
+	data, err := os.ReadFile(m.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.memories); err != nil {
+		return nil, fmt.Errorf("解析记忆文件失败: %v", err)
+	}
+	for _, mem := range m.memories {
+		if mem.ID >= m.nextID {
+			m.nextID = mem.ID + 1
+		}
+	}
+	return m, nil
+}
+
+// save 保存到文件（调用者持有锁）
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(m.memories, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.file, data, 0644)
+}
+
+// Add 添加记忆；同一位置已有相同内容时直接返回已有的
+func (m *Manager) Add(scope, machine, project, content string) (Memory, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Memory{}, fmt.Errorf("记忆内容不能为空")
+	}
+
+	switch scope {
+	case ScopeGlobal:
+		machine, project = "", ""
+	case ScopeMachine:
+		if machine == "" {
+			return Memory{}, fmt.Errorf("machine 范围的记忆需要机器ID")
+		}
+		project = ""
+	case ScopeProject:
+		if machine == "" || project == "" {
+			return Memory{}, fmt.Errorf("project 范围的记忆需要机器ID和项目路径")
+		}
+		project = cleanDir(project)
+	default:
+		return Memory{}, fmt.Errorf("未知范围: %s（可选 global、machine、project）", scope)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, mem := range m.memories {
+		if mem.Scope == scope && mem.Machine == machine && mem.Project == project && mem.Content == content {
+			return mem, nil
+		}
+	}
+
+	now := time.Now()
+	mem := Memory{
+		ID:        m.nextID,
+		Scope:     scope,
+		Machine:   machine,
+		Project:   project,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.nextID++
+	m.memories = append(m.memories, mem)
+	return mem, m.save()
+}
+
+// Update 修改记忆内容
+func (m *Manager) Update(id int, content string) error {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return fmt.Errorf("记忆内容不能为空")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := range m.memories {
+		if m.memories[i].ID == id {
+			m.memories[i].Content = content
+			m.memories[i].UpdatedAt = time.Now()
+			return m.save()
+		}
+	}
+	return fmt.Errorf("记忆不存在: #%d", id)
+}
+
+// Remove 删除记忆，返回被删除的内容
+func (m *Manager) Remove(id int) (Memory, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, mem := range m.memories {
+		if mem.ID == id {
+			m.memories = append(m.memories[:i], m.memories[i+1:]...)
+			return mem, m.save()
+		}
+	}
+	return Memory{}, fmt.Errorf("记忆不存在: #%d", id)
+}
+
+// List 列出全部记忆（按范围、机器、项目排序）
+func (m *Manager) List() []Memory {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := append([]Memory(nil), m.memories...)
+	sortMemories(result)
+	return result
+}
+
+// Search 按关键词搜索（所有词都出现才算匹配，不区分大小写）；machine 非空时只看该机器和全局的记忆
+func (m *Manager) Search(query, machine string) []Memory {
+	words := strings.Fields(strings.ToLower(query))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var result []Memory
+	for _, mem := range m.memories {
+		if machine != "" && mem.Scope != ScopeGlobal && mem.Machine != machine {
+			continue
+		}
+		text := strings.ToLower(mem.Content + " " + mem.Machine + " " + mem.Project)
+		matched := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, mem)
+		}
+	}
+	sortMemories(result)
+	return result
+}
+
+// Relevant 挑出和当前激活机器、工作目录相关的记忆：
+// 全局记忆、激活机器的记忆，以及当前目录位于其项目目录内（或就是项目目录）的项目记忆
+func (m *Manager) Relevant(contexts []WorkContext) []Memory {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var result []Memory
+	for _, mem := range m.memories {
+		switch mem.Scope {
+		case ScopeGlobal:
+			result = append(result, mem)
+		case ScopeMachine:
+			for _, ctx := range contexts {
+				if ctx.Machine == mem.Machine {
+					result = append(result, mem)
+					break
+				}
+			}
+		case ScopeProject:
+			for _, ctx := range contexts {
+				if ctx.Machine == mem.Machine && isWithin(cleanDir(ctx.Dir), mem.Project) {
+					result = append(result, mem)
+					break
+				}
+			}
+		}
+	}
+	sortMemories(result)
+	return result
+}
+
+// sortMemories 全局 → 机器 → 项目，同范围按位置和ID
+func sortMemories(memories []Memory) {
+	order := map[string]int{ScopeGlobal: 0, ScopeMachine: 1, ScopeProject: 2}
+	sort.SliceStable(memories, func(i, j int) bool {
+		a, b := memories[i], memories[j]
+		if order[a.Scope] != order[b.Scope] {
+			return order[a.Scope] < order[b.Scope]
+		}
+		if a.Machine != b.Machine {
+			return a.Machine < b.Machine
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.ID < b.ID
+	})
+}
+
+// cleanDir 统一目录写法（远程机器的路径按 / 分隔处理，兼容Windows的 \）
+func cleanDir(dir string) string {
+	dir = strings.ReplaceAll(strings.TrimSpace(dir), "\\", "/")
+	if dir == "" {
+		return ""
+	}
+	return path.Clean(dir)
+}
+
+// isWithin dir 是否为 root 或其子目录
+func isWithin(dir, root string) bool {
+	if dir == "" || root == "" {
+		return false
+	}
+	if dir == root || root == "/" {
+		return true
+	}
+	return strings.HasPrefix(dir, strings.TrimSuffix(root, "/")+"/")
+}
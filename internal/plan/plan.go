@@ -0,0 +1,125 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 步骤状态
+const (
+	StatusPending    = "pending"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+	StatusSkipped    = "skipped"
+)
+
+// Step 计划中的一步
+type Step struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Note   string `json:"note,omitempty"` // 结果或阻塞原因
+}
+
+// Plan 多步骤任务的计划
+type Plan struct {
+	Goal      string    `json:"goal"`
+	Steps     []Step    `json:"steps"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// New 创建计划，步骤从1开始编号
+func New(goal string, titles []string) *Plan {
+	p := &Plan{Goal: strings.TrimSpace(goal), UpdatedAt: time.Now()}
+	p.Add(titles)
+	return p
+}
+
+// ValidStatus 是否为合法状态
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusInProgress, StatusDone, StatusSkipped:
+		return true
+	}
+	return false
+}
+
+// Add 在末尾追加步骤，返回新增的数量
+func (p *Plan) Add(titles []string) int {
+	nextID := 1
+	for _, s := range p.Steps {
+		if s.ID >= nextID {
+			nextID = s.ID + 1
+		}
+	}
+
+	added := 0
+	for _, title := range titles {
+		title = strings.TrimSpace(title)
+		if title == "" {
+			continue
+		}
+		p.Steps = append(p.Steps, Step{ID: nextID, Title: title, Status: StatusPending})
+		nextID++
+		added++
+	}
+	p.UpdatedAt = time.Now()
+	return added
+}
+
+// Step 按编号查找步骤
+func (p *Plan) Step(id int) *Step {
+	for i := range p.Steps {
+		if p.Steps[i].ID == id {
+			return &p.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Progress 已完成（含跳过）和总步数
+func (p *Plan) Progress() (int, int) {
+	finished := 0
+	for _, s := range p.Steps {
+		if s.Status == StatusDone || s.Status == StatusSkipped {
+			finished++
+		}
+	}
+	return finished, len(p.Steps)
+}
+
+// Finished 所有步骤都已完成或跳过
+func (p *Plan) Finished() bool {
+	finished, total := p.Progress()
+	return total > 0 && finished == total
+}
+
+// Marker 步骤状态对应的勾选标记
+func Marker(status string) string {
+	switch status {
+	case StatusDone:
+		return "[✓]"
+	case StatusInProgress:
+		return "[>]"
+	case StatusSkipped:
+		return "[-]"
+	default:
+		return "[ ]"
+	}
+}
+
+// Render 纯文本清单，用于工具结果和提示词
+func (p *Plan) Render() string {
+	var sb strings.Builder
+	finished, total := p.Progress()
+	sb.WriteString(fmt.Sprintf("计划: %s（%d/%d）\n", p.Goal, finished, total))
+	for _, s := range p.Steps {
+		sb.WriteString(fmt.Sprintf("  %s %d. %s", Marker(s.Status), s.ID, s.Title))
+		if s.Note != "" {
+			sb.WriteString(" — " + s.Note)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -6,20 +6,38 @@ import (
 	"io"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+// 输出缓冲上限（超出后丢弃最早的行）
+const maxOutputLines = 5000
+
 // ProcessInfo 进程信息
 type ProcessInfo struct {
-	ID       string
-	Cmd      *exec.Cmd
-	Stdin    io.WriteCloser
-	Output   []string
-	Mutex    sync.Mutex
-	Done     bool
-	ExitCode int
+	ID        string
+	Command   string
+	StartTime time.Time
+	Cmd       *exec.Cmd
+	Stdin     io.WriteCloser
+	Output    []string
+	Dropped   int // 已从Output头部丢弃的行数（Output[0]的全局行号）
+	Cursor    int // 下一次增量读取的全局行号
+	Mutex     sync.Mutex
+	Done      bool
+	ExitCode  int
+}
+
+// ProcessSummary 进程概要（供列表显示）
+type ProcessSummary struct {
+	ID        string
+	Command   string
+	StartTime time.Time
+	Lines     int // 累计输出行数
+	Done      bool
+	ExitCode  int
 }
 
 // Manager 进程管理器
@@ -27,6 +45,7 @@ type Manager struct {
 	processes map[string]*ProcessInfo
 	mutex     sync.Mutex
 	counter   int
+	shellDir  string // 持久Shell的当前目录（每条命令执行后更新）
 }
 
 // NewManager 创建进程管理器
@@ -41,12 +60,21 @@ func NewManager() *Manager {
 
 // StartProcess 启动进程
 func (pm *Manager) StartProcess(command string) (string, error) {
+	return pm.StartProcessIn(command, "")
+}
+
+// StartProcessIn 在指定工作目录启动后台进程（dir为空则使用当前目录）
+func (pm *Manager) StartProcessIn(command, dir string) (string, error) {
 	pm.mutex.Lock()
 	pm.counter++
 	processID := fmt.Sprintf("%d", time.Now().Unix()*1000+int64(pm.counter))
 	pm.mutex.Unlock()
 
 	cmd := exec.Command("bash", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("powershell.exe", "-NoLogo", "-NoProfile", "-Command", command)
+	}
+	cmd.Dir = dir
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -64,20 +92,22 @@ func (pm *Manager) StartProcess(command string) (string, error) {
 	}
 
 	process := &ProcessInfo{
-		ID:     processID,
-		Cmd:    cmd,
-		Stdin:  stdin,
-		Output: []string{},
+		ID:        processID,
+		Command:   command,
+		StartTime: time.Now(),
+		Cmd:       cmd,
+		Stdin:     stdin,
+		Output:    []string{},
 	}
 
-	pm.mutex.Lock()
-	pm.processes[processID] = process
-	pm.mutex.Unlock()
-
 	if err := cmd.Start(); err != nil {
 		return "", err
 	}
 
+	pm.mutex.Lock()
+	pm.processes[processID] = process
+	pm.mutex.Unlock()
+
 	go pm.collectOutput(process, stdout, stderr)
 
 	go func() {
@@ -99,8 +129,10 @@ func (pm *Manager) collectOutput(process *ProcessInfo, stdout, stderr io.ReadClo
 		line := scanner.Text()
 		process.Mutex.Lock()
 		process.Output = append(process.Output, line)
-		if len(process.Output) > 1000 {
-			process.Output = process.Output[len(process.Output)-1000:]
+		if len(process.Output) > maxOutputLines {
+			drop := len(process.Output) - maxOutputLines
+			process.Output = process.Output[drop:]
+			process.Dropped += drop
 		}
 		process.Mutex.Unlock()
 	}
@@ -141,16 +173,90 @@ func (pm *Manager) GetOutput(processID string) (string, string, error) {
 	defer process.Mutex.Unlock()
 
 	output := strings.Join(process.Output, "\n")
+	process.Dropped += len(process.Output)
 	process.Output = []string{}
+	process.Cursor = process.Dropped
 
-	var status string
-	if process.Done {
-		status = fmt.Sprintf("已退出(code=%d)", process.ExitCode)
-	} else {
-		status = "运行中"
+	return output, process.status(), nil
+}
+
+// ReadOutput 按全局行号增量读取输出（offset<0 表示从上次读取的位置继续）
+// 返回读取的行、下一次的offset、读取起点之前被丢弃的行数以及进程状态
+func (pm *Manager) ReadOutput(processID string, offset, maxLines int) ([]string, int, int, string, error) {
+	pm.mutex.Lock()
+	process, exists := pm.processes[processID]
+	pm.mutex.Unlock()
+
+	if !exists {
+		return nil, 0, 0, "", fmt.Errorf("进程不存在: %s", processID)
 	}
 
-	return output, status, nil
+	process.Mutex.Lock()
+	defer process.Mutex.Unlock()
+
+	if offset < 0 {
+		offset = process.Cursor
+	}
+	total := process.Dropped + len(process.Output)
+	if offset > total {
+		offset = total
+	}
+
+	// 请求的起点已被丢弃，从最早保留的行开始
+	skipped := 0
+	if offset < process.Dropped {
+		skipped = process.Dropped - offset
+		offset = process.Dropped
+	}
+
+	end := total
+	if maxLines > 0 && offset+maxLines < end {
+		end = offset + maxLines
+	}
+
+	lines := append([]string{}, process.Output[offset-process.Dropped:end-process.Dropped]...)
+	process.Cursor = end
+
+	return lines, end, skipped, process.status(), nil
+}
+
+// ListProcesses 列出所有后台进程（不含持久Shell）
+func (pm *Manager) ListProcesses() []ProcessSummary {
+	pm.mutex.Lock()
+	processes := make([]*ProcessInfo, 0, len(pm.processes))
+	for id, p := range pm.processes {
+		if id != "__persistent__" {
+			processes = append(processes, p)
+		}
+	}
+	pm.mutex.Unlock()
+
+	summaries := make([]ProcessSummary, 0, len(processes))
+	for _, p := range processes {
+		p.Mutex.Lock()
+		summaries = append(summaries, ProcessSummary{
+			ID:        p.ID,
+			Command:   p.Command,
+			StartTime: p.StartTime,
+			Lines:     p.Dropped + len(p.Output),
+			Done:      p.Done,
+			ExitCode:  p.ExitCode,
+		})
+		p.Mutex.Unlock()
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].StartTime.Before(summaries[j].StartTime)
+	})
+	return summaries
+}
+
+// status 进程状态描述（调用者需持有process.Mutex）
+func (p *ProcessInfo) status() string {
+	if p.Done {
+		return fmt.Sprintf("已退出(code=%d)", p.ExitCode)
+	}
+	return "运行中"
 }
 
 // KillProcess 终止进程
@@ -163,6 +269,13 @@ func (pm *Manager) KillProcess(processID string) error {
 		return fmt.Errorf("进程不存在: %s", processID)
 	}
 
+	process.Mutex.Lock()
+	done := process.Done
+	process.Mutex.Unlock()
+	if done {
+		return fmt.Errorf("进程已结束")
+	}
+
 	if process.Cmd.Process != nil {
 		return process.Cmd.Process.Kill()
 	}
@@ -226,6 +339,13 @@ func (pm *Manager) startPersistentShell() {
 	process.Mutex.Unlock()
 }
 
+// ShellDir 持久Shell的当前目录（还没执行过命令时为空）
+func (pm *Manager) ShellDir() string {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	return pm.shellDir
+}
+
 // ExecuteInPersistentShell 在持久Shell中执行命令（保持状态）
 func (pm *Manager) ExecuteInPersistentShell(command string) (string, error) {
 	pm.mutex.Lock()
@@ -249,12 +369,12 @@ func (pm *Manager) ExecuteInPersistentShell(command string) (string, error) {
 	// 生成唯一标记
 	marker := fmt.Sprintf("__END_%d__", time.Now().UnixNano())
 
-	// 发送命令
+	// 发送命令（结束标记后带上当前目录，用于跟踪cd）
 	var cmdLine string
 	if runtime.GOOS == "windows" {
-		cmdLine = fmt.Sprintf("%s; Write-Host '%s'\n", command, marker)
+		cmdLine = fmt.Sprintf("%s; Write-Host \"%s$PWD\"\n", command, marker)
 	} else {
-		cmdLine = fmt.Sprintf("%s; echo '%s'\n", command, marker)
+		cmdLine = fmt.Sprintf("%s; echo \"%s$PWD\"\n", command, marker)
 	}
 
 	if _, err := process.Stdin.Write([]byte(cmdLine)); err != nil {
@@ -271,8 +391,20 @@ func (pm *Manager) ExecuteInPersistentShell(command string) (string, error) {
 			lines := strings.Split(output, "\n")
 			var result []string
 			for _, line := range lines {
+				if idx := strings.Index(line, marker); idx >= 0 {
+					if dir := strings.TrimSpace(line[idx+len(marker):]); dir != "" {
+						pm.mutex.Lock()
+						pm.shellDir = dir
+						pm.mutex.Unlock()
+					}
+					// 命令输出末尾没有换行时，标记会接在最后一行后面
+					if prefix := line[:idx]; strings.TrimSpace(prefix) != "" {
+						result = append(result, prefix)
+					}
+					continue
+				}
 				// 过滤掉标记和空行
-				if !strings.Contains(line, marker) && !strings.HasPrefix(line, "__END_") {
+				if !strings.HasPrefix(line, "__END_") {
 					result = append(result, line)
 				}
 			}
@@ -5,11 +5,16 @@ import (
 	"strings"
 
 	"ai_assistant/internal/environment"
+	"ai_assistant/internal/memory"
+	"ai_assistant/internal/plan"
 	"ai_assistant/internal/state"
 )
 
+// MaxPromptMemories 提示词中最多带的记忆条数
+const MaxPromptMemories = 30
+
 // BuildSystemPrompt 构建系统提示词（带状态）
-func BuildSystemPrompt(env environment.SystemEnvironment, sm *state.Manager) string {
+func BuildSystemPrompt(env environment.SystemEnvironment, sm *state.Manager, mm *memory.Manager, currentPlan *plan.Plan) string {
 	var prompt strings.Builder
 
 	// 第一部分：人设和基调 - 更像朋友间的对话
@@ -78,24 +83,65 @@ func BuildSystemPrompt(env environment.SystemEnvironment, sm *state.Manager) str
 		prompt.WriteString("\n```\n\n")
 	}
 
+	// 当前任务计划（放在每轮重建的部分，历史被截断也不会丢）
+	if currentPlan != nil && len(currentPlan.Steps) > 0 {
+		prompt.WriteString("**当前任务计划（做完一步就用 plan update 标记）：**\n```\n")
+		prompt.WriteString(currentPlan.Render())
+		prompt.WriteString("\n```\n\n")
+	}
+
+	// 和激活机器、当前目录相关的长期记忆
+	if memories := relevantMemories(sm, mm); len(memories) > 0 {
+		prompt.WriteString("**我记得的事（长期记忆，过时了就用 memory forget 删掉）：**\n")
+		for i, mem := range memories {
+			if i >= MaxPromptMemories {
+				prompt.WriteString(fmt.Sprintf("- ……还有 %d 条，用 memory recall 查\n", len(memories)-i))
+				break
+			}
+			prompt.WriteString("- " + mem.String() + "\n")
+		}
+		prompt.WriteString("\n")
+	}
+
 	// 第四部分：工具使用指南 - 像在教搭档而不是列手册
 	prompt.WriteString(`## 🛠️ 工具使用指南（咱俩的暗号）
 
 ### 基础操作
-- 看目录/运行命令 → **run_command**（就像你亲手在敲）
+- 运行命令 → **run_command**（就像你亲手在敲）
+- 跑个不会自己结束的（dev server、tail -f、大构建）→ **process** start，拿进程ID后 read_output 慢慢看
 - 读文件/改代码 → **file_operation**（读/写/搜）
+- 看目录/项目结构 → **file_operation** 的 list（depth 控制层数，tree:true 出树形图，不用再 ls）
+- 按名字/大小/修改时间找文件（"最近1小时改过的日志"、"大于1G的文件"）→ **find**，不要在 run_command 里跑 find
+- 要看多个文件（一组配置、同目录的几个源码）→ **file_operation** read 传 files 列表或 glob，一次读完，不要逐个读
+- 大文件/找某个函数 → **file_operation** 的 outline 先看声明和行号，再 read 指定 start_line/end_line
+- 同一文件里的机械性批量修改（改版本号、改键名）→ edit 加 replace_all（必要时 regex），带上 expected_count 防止误改
+- 文件工具的相对路径以 run_command 当前所在目录为准（cd 后同样生效），结果里显示解析后的绝对路径
+- 改文件前先 read（只读相关行也行）；提示"已被外部修改"时重新 read，别凭旧内容改
+- edit 的 old 片段重复（"找到N处匹配"）→ 改用 start_line/end_line + read 结果里的 hash 按行号替换，不用硬凑更长的片段
+- 查Git状态/差异/历史、提交 → **git**（结构化结果，不用在终端里数 git status）
+- 跑Go测试 → **test**（逐个用例的结果和失败位置，修完用 rerun_failed 只重跑失败的）
+- 要翻很多日志/文件才能查清的问题（"web-3 为啥502"）→ **delegate** 交给子任务，只拿回报告，别让几十条输出淹没对话
+- 三步以上的活（跨机器迁移、多处改动）→ 先 **plan** create 列步骤，边做边 update，做完一步标一步
+- 发现值得长期记住的事实（机器上服务装在哪、项目怎么部署/测试）→ **memory** remember；用户说"记住…"也用它
+- 改完Go代码确认能否编译 → **diagnostics**（go build + go vet，问题带 file:line:col）；编辑结果里出现 [诊断] 说明用户开了自动诊断，按提示修
+- Go项目找定义/引用/接口实现 → **code_intel**（按类型解析，比 search 正则准；结果带行号，直接 read start_line/end_line）
+- 搜资料 → **web_search**；看某个网页/文档的正文 → **web_fetch**（转成纯文本，长页面用 offset 翻页）
 - 传文件 → **sync**（推/拉）
 - 管终端 → **terminal_manage**（开/关/切）
+- 工具列表里有团队自定义的工具（如 deploy_service，来自配置目录 tools.json）→ 做对应的事时优先用它，别自己拼命令绕过
+- mcp__<服务器>__<工具> 是外部 MCP 服务器提供的工具（数据库、工单、文档等）→ 查这些系统时直接用，别绕道 run_command 装客户端
 
 ### 改代码的小技巧
 - **删除一段**：找到它的"指纹"（前后唯一标记），然后 old:"整个片段", new:""
 - **插入代码**：在标记点后面加，old:"标记", new:"标记\n新代码"
 - **重要原则**：old必须是唯一的，不然会误伤友军
+- **改Go函数/类型**：直接 replace_symbol（symbol:"(*Manager).Save", new:"完整的新函数"），不用凑唯一的 old；删除用 delete_symbol，追加新函数用 insert_after_symbol
+- **改完看结果**：edit 结果里带语法检查，出现 [✗] 语法检查失败 就按位置马上修；改乱了用 rollback 恢复到本轮修改前再重来
 
 ## 🚀 行动风格
 我默认你已经想清楚要做什么，所以：
 - 你说"编译" → 我直接 go build
-- 你说"看日志" → 我 tail -f 走起
+- 你说"看日志" → 我用 process 挂个 tail -f 走起
 - 你说"这错了" → 我定位问题并给出修复方案
 - 需要确认时我会简短问，比如"覆盖原文件？"
 
@@ -103,3 +149,18 @@ func BuildSystemPrompt(env environment.SystemEnvironment, sm *state.Manager) str
 
 	return prompt.String()
 }
+
+// relevantMemories 激活slot的机器及其当前目录相关的记忆
+func relevantMemories(sm *state.Manager, mm *memory.Manager) []memory.Memory {
+	if mm == nil {
+		return nil
+	}
+	var contexts []memory.WorkContext
+	for _, machine := range sm.ActiveMachines() {
+		contexts = append(contexts, memory.WorkContext{
+			Machine: machine.ID,
+			Dir:     sm.WorkDir(machine.ID),
+		})
+	}
+	return mm.Relevant(contexts)
+}
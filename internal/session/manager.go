@@ -10,6 +10,7 @@ import (
 
 	appconfig "ai_assistant/internal/config"
 	"ai_assistant/internal/history"
+	"ai_assistant/internal/plan"
 )
 
 // Session 会话信息
@@ -19,6 +20,9 @@ type Session struct {
 	CreatedAt time.Time `json:"created_at"` // 创建时间
 	UpdatedAt time.Time `json:"updated_at"` // 最后更新时间
 	FilePath  string    `json:"file_path"`  // 历史记录文件路径
+
+	AutoDiagnostics bool       `json:"auto_diagnostics,omitempty"` // 编辑Go文件后自动附带 build/vet 诊断
+	Plan            *plan.Plan `json:"plan,omitempty"`             // 当前的任务计划（nil 表示没有）
 }
 
 // Manager 会话管理器
@@ -277,3 +281,47 @@ func (m *Manager) RenameSession(id, newTitle string) error {
 
 	return m.saveIndex(sessions)
 }
+
+// SetAutoDiagnostics 设置当前会话是否在编辑后自动诊断
+func (m *Manager) SetAutoDiagnostics(enabled bool) error {
+	if m.currentSession == nil {
+		return fmt.Errorf("没有活动会话")
+	}
+
+	sessions, err := m.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		if sessions[i].ID == m.currentSession.ID {
+			sessions[i].AutoDiagnostics = enabled
+			break
+		}
+	}
+	m.currentSession.AutoDiagnostics = enabled
+
+	return m.saveIndex(sessions)
+}
+
+// SavePlan 保存当前会话的任务计划（nil 表示清除）
+func (m *Manager) SavePlan(p *plan.Plan) error {
+	if m.currentSession == nil {
+		return fmt.Errorf("没有活动会话")
+	}
+
+	sessions, err := m.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		if sessions[i].ID == m.currentSession.ID {
+			sessions[i].Plan = p
+			break
+		}
+	}
+	m.currentSession.Plan = p
+
+	return m.saveIndex(sessions)
+}
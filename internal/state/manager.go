@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -63,13 +64,14 @@ func NewManager() *Manager {
 
 	// 确保本地机器存在
 	if _, exists := m.state.Machines["local"]; !exists {
+		wd, _ := os.Getwd()
 		m.state.Machines["local"] = &Machine{
 			ID:          "local",
 			Host:        "127.0.0.1",
 			Port:        0,
 			Type:        "local",
 			Description: "本地机器",
-			CurrentDir:  ".",
+			CurrentDir:  wd,
 		}
 	}
 
@@ -126,11 +128,9 @@ func (m *Manager) load() {
 		m.state.TerminalSlots = make(map[string]*TerminalSlot)
 	}
 
-	// 初始化运行时字段
-	for _, machine := range m.state.Machines {
-		if machine.CurrentDir == "" {
-			machine.CurrentDir = "/"
-		}
+	// 初始化运行时字段：本地为程序的工作目录，寄生虫在第一次执行命令后得知
+	if local := m.state.Machines["local"]; local != nil {
+		local.CurrentDir, _ = os.Getwd()
 	}
 }
 
@@ -176,6 +176,54 @@ func (m *Manager) GetMachine(machineID string) *Machine {
 	return m.state.Machines[machineID]
 }
 
+// ActiveMachines 获取激活slot中的机器（按slot顺序，去重）
+func (m *Manager) ActiveMachines() []*Machine {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var machines []*Machine
+	seen := make(map[string]bool)
+	for _, slotID := range []string{"slot1", "slot2"} {
+		slot := m.state.TerminalSlots[slotID]
+		if slot == nil || !slot.Active || seen[slot.MachineID] {
+			continue
+		}
+		if machine := m.state.Machines[slot.MachineID]; machine != nil {
+			seen[slot.MachineID] = true
+			machines = append(machines, machine)
+		}
+	}
+	return machines
+}
+
+// WorkDir 获取机器的当前工作目录（本地机器为持久Shell的目录；寄生虫未执行过命令时为空）
+func (m *Manager) WorkDir(machineID string) string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if machine := m.state.Machines[machineID]; machine != nil && machine.CurrentDir != "" {
+		return machine.CurrentDir
+	}
+	if machineID == "local" {
+		if wd, err := os.Getwd(); err == nil {
+			return wd
+		}
+		return "."
+	}
+	return ""
+}
+
+// SetWorkDir 更新机器的当前工作目录（本地持久Shell执行命令后调用）
+func (m *Manager) SetWorkDir(machineID, dir string) {
+	if dir == "" {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if machine := m.state.Machines[machineID]; machine != nil {
+		machine.CurrentDir = dir
+	}
+}
+
 // OpenTerminalSlot 打开终端槽位
 func (m *Manager) OpenTerminalSlot(slotID, machineID string) error {
 	m.mutex.Lock()
@@ -278,6 +326,19 @@ func (m *Manager) ListMachines() string {
 	return result
 }
 
+// Machines 所有机器的副本（按ID排序）
+func (m *Manager) Machines() []Machine {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	list := make([]Machine, 0, len(m.state.Machines))
+	for _, machine := range m.state.Machines {
+		list = append(list, *machine)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
 // AppendTerminalOutput 追加终端输出到对应slot
 func (m *Manager) AppendTerminalOutput(machineID, command, output string) {
 	m.mutex.Lock()
@@ -487,6 +548,36 @@ func (m *Manager) ExecuteOnAgent(machineID, command string) (string, error) {
 	return "", nil
 }
 
+// AgentRunResult 寄生虫直接执行程序的结果（不经过持久Shell，输出不截断）
+type AgentRunResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	TimedOut bool
+}
+
+// RunOnAgent 在寄生虫上直接执行程序（使用run action，argv不经过shell解析）
+// dir为空时使用寄生虫持久Shell的当前目录
+func (m *Manager) RunOnAgent(machineID string, argv []string, dir string, timeoutSec int) (*AgentRunResult, error) {
+	resp, err := m.CallAgentAPI(machineID, "run", map[string]interface{}{
+		"argv":    argv,
+		"cwd":     dir,
+		"timeout": timeoutSec,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AgentRunResult{}
+	result.Stdout, _ = resp["stdout"].(string)
+	result.Stderr, _ = resp["stderr"].(string)
+	if code, ok := resp["exit_code"].(float64); ok {
+		result.ExitCode = int(code)
+	}
+	result.TimedOut, _ = resp["timed_out"].(bool)
+	return result, nil
+}
+
 // getKeys 获取map的所有key（用于调试）
 func getKeys(m map[string]interface{}) []string {
 	keys := make([]string, 0, len(m))
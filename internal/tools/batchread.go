@@ -0,0 +1,299 @@
+package tools
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ai_assistant/internal/state"
+)
+
+// 批量读取限制
+const (
+	MaxBatchReadBytes = 256 * 1024 // 所有文件合计的大小预算
+	MaxBatchReadFiles = 50         // 一次最多返回的文件数
+)
+
+// BatchFile 批量读取到的一个文件
+type BatchFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Content string `json:"content"` // base64
+}
+
+// BatchSkipped 没有返回内容的文件及原因（size/count/dir 或错误信息）
+type BatchSkipped struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// BatchReadReport 批量读取结果，本地和寄生虫返回相同结构
+type BatchReadReport struct {
+	Files   []BatchFile    `json:"files"`
+	Skipped []BatchSkipped `json:"skipped"`
+	Missing []string       `json:"missing"` // 不存在或没有匹配的路径/模式
+}
+
+// isBatchRead read 操作是否按批量处理：给了 files，或 file 中含通配符
+func isBatchRead(args map[string]interface{}) bool {
+	if len(splitPatterns(args["files"])) > 0 {
+		return true
+	}
+	file, _ := args["file"].(string)
+	return hasGlobMeta(file)
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// globPaths 展开glob（支持 **），规则与寄生虫的 glob_paths 一致：
+// 从不含通配符的前缀目录开始遍历，按相对路径匹配；不含 ** 时只遍历到模式的层数
+func globPaths(pattern string) []string {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(parts) && !hasGlobMeta(parts[i]) {
+		i++
+	}
+	base := strings.Join(parts[:i], "/")
+	rest := strings.Join(parts[i:], "/")
+	switch {
+	case base == "" && strings.HasPrefix(filepath.ToSlash(pattern), "/"):
+		base = "/"
+	case base == "":
+		base = "."
+	case strings.HasSuffix(base, ":"):
+		base += "/" // Windows 盘符根目录
+	}
+	re, err := regexp.Compile("^" + globToRegexp(rest) + "$")
+	if err != nil {
+		return nil
+	}
+	recursive := strings.Contains(rest, "**")
+	maxDepth := strings.Count(rest, "/") + 1
+
+	baseDir := filepath.FromSlash(base)
+	var paths []string
+	filepath.WalkDir(baseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == baseDir {
+			return nil
+		}
+		rel, relErr := filepath.Rel(baseDir, p)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if re.MatchString(rel) {
+			paths = append(paths, p)
+		}
+		if d.IsDir() && !recursive && strings.Count(rel, "/")+1 >= maxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths
+}
+
+// ExecuteBatchRead 一次读取多个文件或glob匹配的文件，合计大小超出预算的只列出不读取
+func ExecuteBatchRead(args map[string]interface{}, sm *state.Manager) string {
+	patterns := splitPatterns(args["files"])
+	if file, _ := args["file"].(string); file != "" {
+		patterns = append([]string{file}, patterns...)
+	}
+	if len(patterns) == 0 {
+		return "[✗] read操作缺少file或files参数"
+	}
+
+	targetMachine, _ := args["_target_machine"].(string)
+	if targetMachine == "" {
+		targetMachine = "local"
+	}
+
+	var report *BatchReadReport
+	var err error
+	if targetMachine == "local" {
+		report = batchReadLocal(patterns, MaxBatchReadBytes, MaxBatchReadFiles)
+	} else {
+		report, err = batchReadOnAgent(sm, targetMachine, patterns)
+	}
+	if err != nil {
+		return fmt.Sprintf("[✗] 批量读取失败: %v", err)
+	}
+
+	// 记录读取时的内容，顺带找出上次读取后被外部修改的文件
+	var changed []string
+	for _, f := range report.Files {
+		content, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			continue
+		}
+		if prev := recordFileSnapshot(f.Path, targetMachine, content); changedSinceReadNote(prev, content) != "" {
+			changed = append(changed, f.Path)
+		}
+	}
+
+	result := formatBatchRead(patterns, report)
+	if len(changed) > 0 {
+		result = fmt.Sprintf("[!] 以下文件在你上次读取后已被外部修改，之前的内容和行号已失效: %s\n", strings.Join(changed, ", ")) + result
+	}
+	return result
+}
+
+// batchReadLocal 在本地展开glob并读取，规则与寄生虫的 read_files 一致
+func batchReadLocal(patterns []string, maxBytes int64, maxFiles int) *BatchReadReport {
+	report := &BatchReadReport{}
+	seen := make(map[string]bool)
+	var used int64
+
+	for _, pattern := range patterns {
+		var paths []string
+		if hasGlobMeta(pattern) {
+			paths = globPaths(pattern)
+		} else if _, err := os.Stat(pattern); err == nil {
+			paths = []string{pattern}
+		}
+		if len(paths) == 0 {
+			report.Missing = append(report.Missing, pattern)
+			continue
+		}
+
+		for _, path := range paths {
+			path = filepath.Clean(path)
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			info, err := os.Stat(path)
+			if err != nil {
+				report.Skipped = append(report.Skipped, BatchSkipped{Path: path, Reason: err.Error()})
+				continue
+			}
+			if info.IsDir() {
+				report.Skipped = append(report.Skipped, BatchSkipped{Path: path, Reason: "dir"})
+				continue
+			}
+			size := info.Size()
+			if len(report.Files) >= maxFiles {
+				report.Skipped = append(report.Skipped, BatchSkipped{Path: path, Size: size, Reason: "count"})
+				continue
+			}
+			if used+size > maxBytes {
+				report.Skipped = append(report.Skipped, BatchSkipped{Path: path, Size: size, Reason: "size"})
+				continue
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				report.Skipped = append(report.Skipped, BatchSkipped{Path: path, Size: size, Reason: err.Error()})
+				continue
+			}
+			used += int64(len(content))
+			report.Files = append(report.Files, BatchFile{
+				Path:    path,
+				Size:    int64(len(content)),
+				Content: base64.StdEncoding.EncodeToString(content),
+			})
+		}
+	}
+	return report
+}
+
+// batchReadOnAgent 调用寄生虫的 read_files action，一次往返取回所有文件
+func batchReadOnAgent(sm *state.Manager, machineID string, patterns []string) (*BatchReadReport, error) {
+	resp, err := sm.CallAgentAPI(machineID, "read_files", map[string]interface{}{
+		"patterns":  patterns,
+		"max_bytes": MaxBatchReadBytes,
+		"max_files": MaxBatchReadFiles,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var report BatchReadReport
+	if err := decodeAgentResponse(resp, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// formatBatchRead 每个文件一段（标题+带行号的内容），最后列出跳过的文件
+func formatBatchRead(patterns []string, report *BatchReadReport) string {
+	var sb strings.Builder
+	read := 0
+	var binaries []string
+
+	for _, f := range report.Files {
+		content, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			report.Skipped = append(report.Skipped, BatchSkipped{Path: f.Path, Size: f.Size, Reason: "解码失败"})
+			continue
+		}
+		if IsBinaryFile(content) {
+			binaries = append(binaries, f.Path)
+			continue
+		}
+
+		text, format := DecodeText(content)
+		text = strings.TrimSuffix(text, "\n")
+		lines := strings.Split(text, "\n")
+		if text == "" {
+			lines = nil
+		}
+
+		formatInfo := ""
+		if desc := format.Describe(); desc != "" {
+			formatInfo = "，" + desc
+		}
+		sb.WriteString(fmt.Sprintf("[文件] %s (共 %d 行%s，哈希 %s):\n```\n", f.Path, len(lines), formatInfo, contentHash(content)))
+		sb.WriteString(numberLines(lines, 1))
+		sb.WriteString("```\n\n")
+		read++
+	}
+
+	header := fmt.Sprintf("[✓] 读取了 %d 个文件（%s）\n\n", read, strings.Join(patterns, ", "))
+	if read == 0 {
+		header = fmt.Sprintf("[✗] 没有读取到文件（%s）\n\n", strings.Join(patterns, ", "))
+	}
+
+	var notes []string
+	for _, s := range report.Skipped {
+		switch s.Reason {
+		case "size":
+			notes = append(notes, fmt.Sprintf("  %s (%s) — 超出合计 %s 的预算，请单独读取或用 start_line/end_line 分段", s.Path, formatFileSize(s.Size), formatFileSize(MaxBatchReadBytes)))
+		case "count":
+			notes = append(notes, fmt.Sprintf("  %s — 超过单次 %d 个文件的上限", s.Path, MaxBatchReadFiles))
+		case "dir":
+			notes = append(notes, fmt.Sprintf("  %s — 是目录", s.Path))
+		default:
+			notes = append(notes, fmt.Sprintf("  %s — %s", s.Path, s.Reason))
+		}
+	}
+	for _, path := range binaries {
+		notes = append(notes, fmt.Sprintf("  %s — 二进制文件", path))
+	}
+	for _, pattern := range report.Missing {
+		notes = append(notes, fmt.Sprintf("  %s — 不存在或没有匹配的文件", pattern))
+	}
+	if len(notes) > 0 {
+		sb.WriteString(fmt.Sprintf("[!] 跳过 %d 项:\n%s\n", len(notes), strings.Join(notes, "\n")))
+	}
+
+	return header + strings.TrimRight(sb.String(), "\n")
+}
+
+// numberLines 给每行加上行号（从start开始，按最大行号对齐）
+func numberLines(lines []string, start int) string {
+	width := len(fmt.Sprint(start + len(lines) - 1))
+	var sb strings.Builder
+	for i, line := range lines {
+		sb.WriteString(fmt.Sprintf("%*d| %s\n", width, start+i, line))
+	}
+	return sb.String()
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlobPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"x.go", ".h.go", "a/y.go", "a/b/n.txt", "a/b/c/z.go"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 与寄生虫 glob_paths 的结果一致
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"**/*.go", []string{".h.go", "a/b/c/z.go", "a/y.go", "x.go"}},
+		{"*.go", []string{".h.go", "x.go"}},
+		{"a/*.go", []string{"a/y.go"}},
+		{"a/**/*.go", []string{"a/b/c/z.go", "a/y.go"}},
+		{"*/*/c/*.go", []string{"a/b/c/z.go"}},
+		{"a/**", []string{"a/b", "a/b/c", "a/b/c/z.go", "a/b/n.txt", "a/y.go"}},
+		{"nope/**/*.go", nil},
+	}
+	for _, tt := range tests {
+		var want []string
+		for _, w := range tt.want {
+			want = append(want, filepath.Join(dir, filepath.FromSlash(w)))
+		}
+		got := globPaths(filepath.Join(dir, filepath.FromSlash(tt.pattern)))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("globPaths(%q) = %v, want %v", tt.pattern, got, want)
+		}
+	}
+}
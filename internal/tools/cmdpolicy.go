@@ -0,0 +1,94 @@
+package tools
+
+import "strings"
+
+// 命令白名单：查询类命令，无需批准
+var commandWhitelist = []string{
+	// 目录操作
+	"ls", "dir", "pwd", "cd", "tree", "pushd", "popd",
+	// 文件查看
+	"cat", "type", "more", "less", "head", "tail", "echo", "Get-Content",
+	// 信息查询
+	"whoami", "hostname", "date", "time", "ver", "uname", "systeminfo",
+	// 进程查询
+	"ps", "tasklist", "Get-Process",
+	// 网络查询
+	"ipconfig", "ifconfig", "ping", "tracert", "nslookup",
+	// Git查询
+	"git status", "git log", "git diff", "git branch",
+	// 其他查询
+	"which", "where", "env", "printenv", "set", "Get-Variable",
+}
+
+// commandWhitelistBare 只有不带参数时才是查询的白名单命令（带参数时会执行别的命令，如 env rm -rf x、time make）
+var commandWhitelistBare = map[string]bool{
+	"env": true, "time": true, "set": true,
+}
+
+// 命令黑名单：需要TTY交互的命令，无法在持久Shell中工作，直接拒绝
+var commandBlacklist = []string{
+	// 文本编辑器（需要TTY）
+	"nano", "vim", "vi", "emacs", "notepad",
+	// 交互式数据库客户端（直接连接）
+	"mysql", "psql", "mongo", "redis-cli",
+	// 其他交互式程序
+	"top", "htop", "less", "more",
+	// 注意：ssh/telnet/ftp 如果使用非交互模式（如 ssh user@host "command"）是允许的
+	// 只有交互式登录（如 ssh user@host）才会有问题，但我们允许AI尝试
+}
+
+// shellControlTokens 会串联、替换或重定向命令的Shell语法（管道单独处理）
+var shellControlTokens = []string{";", "&", ">", "<", "`", "$(", "\n", "\r"}
+
+// IsBlacklistedCommand 命令是否在黑名单中（交互式程序，直接拒绝）
+func IsBlacklistedCommand(command string) bool {
+	command = strings.ToLower(strings.TrimSpace(command))
+	for _, item := range commandBlacklist {
+		if strings.HasPrefix(command, strings.ToLower(item)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWhitelistedCommand 命令是否是白名单中的查询命令，可以不经批准执行
+// 含 ; & > ` $( 等语法的一律不算；管道要求每一段都在白名单中
+func IsWhitelistedCommand(command string) bool {
+	if hasShellControl(command) {
+		return false
+	}
+	for _, segment := range strings.Split(command, "|") {
+		if !whitelistedSegment(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// whitelistedSegment 单条命令（不含管道）是否在白名单中，按完整的词匹配
+func whitelistedSegment(command string) bool {
+	command = strings.ToLower(strings.TrimSpace(command))
+	if command == "" {
+		return false
+	}
+	for _, item := range commandWhitelist {
+		item = strings.ToLower(item)
+		if command == item {
+			return true
+		}
+		if strings.HasPrefix(command, item+" ") && !commandWhitelistBare[item] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasShellControl 命令是否包含串联/替换/重定向语法
+func hasShellControl(command string) bool {
+	for _, token := range shellControlTokens {
+		if strings.Contains(command, token) {
+			return true
+		}
+	}
+	return false
+}
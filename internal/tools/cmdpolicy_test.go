@@ -0,0 +1,99 @@
+package tools
+
+import "testing"
+
+func TestIsWhitelistedCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"ls", true},
+		{"ls -la /tmp", true},
+		{"  cat README.md  ", true},
+		{"git status", true},
+		{"git log --oneline", true},
+		{"Get-Content a.txt", true},
+		{"ps aux | head -20", true},
+		{"cat a | tail -n 5 | head", true},
+
+		// 前缀相同但不是同一个命令
+		{"lsblk", false},
+		{"cattle", false},
+		{"git stash", false},
+		{"git statusx", false},
+		{"echoo hi", false},
+
+		// 管道中任一段不在白名单
+		{"ls|rm -rf x", false},
+		{"ls | rm -rf x", false},
+		{"cat a | sh", false},
+		{"ls |", false},
+
+		// 串联、重定向、命令替换
+		{"cat a; rm -rf b", false},
+		{"ls && rm -rf b", false},
+		{"ls & rm -rf b", false},
+		{"cat a > b", false},
+		{"echo x >> ~/.bashrc", false},
+		{"cat < /etc/passwd", false},
+		{"echo $(rm -rf x)", false},
+		{"echo `rm -rf x`", false},
+		{"ls\nrm -rf x", false},
+		{"ls\rrm -rf x", false},
+
+		// 只有不带参数时才是查询
+		{"env", true},
+		{"env rm x", false},
+		{"time", true},
+		{"time make", false},
+		{"set", true},
+		{"set -e", false},
+
+		{"", false},
+		{"rm -rf /", false},
+	}
+	for _, tt := range tests {
+		if got := IsWhitelistedCommand(tt.command); got != tt.want {
+			t.Errorf("IsWhitelistedCommand(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestWhitelistedSegment(t *testing.T) {
+	tests := []struct {
+		segment string
+		want    bool
+	}{
+		{"ls", true},
+		{" LS -la ", true},
+		{"which go", true},
+		{"whichx", false},
+		{"printenv PATH", true},
+		{"env FOO=1 sh", false},
+		{"   ", false},
+	}
+	for _, tt := range tests {
+		if got := whitelistedSegment(tt.segment); got != tt.want {
+			t.Errorf("whitelistedSegment(%q) = %v, want %v", tt.segment, got, tt.want)
+		}
+	}
+}
+
+func TestIsBlacklistedCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"vim a.go", true},
+		{"  top", true},
+		{"mysql -u root", true},
+		{"less README.md", true},
+		{"ls", false},
+		{"ssh host uptime", false},
+	}
+	for _, tt := range tests {
+		if got := IsBlacklistedCommand(tt.command); got != tt.want {
+			t.Errorf("IsBlacklistedCommand(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
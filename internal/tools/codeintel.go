@@ -0,0 +1,850 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai_assistant/internal/state"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Go代码智能限制
+const (
+	CodeIntelLoadTimeout = 2 * time.Minute
+	MaxCodeIntelResults  = 200 // 引用/实现最多返回的条数
+	MaxCodeIntelErrors   = 5   // 最多显示的加载/类型错误
+	MaxCodeIntelCached   = 3   // 最多缓存的模块数，超出时丢弃最久没用的
+)
+
+// codeIntelWorkspace 已加载的Go模块（含测试变体）
+type codeIntelWorkspace struct {
+	Root        string
+	Fset        *token.FileSet
+	Packages    []*packages.Package
+	Errors      []string
+	Fingerprint string
+	lastUsed    time.Time // 受 codeIntelCacheMutex 保护
+}
+
+// codeIntelTarget 解析出的目标符号
+type codeIntelTarget struct {
+	Obj types.Object
+	Pkg *packages.Package
+}
+
+// 按模块根目录缓存加载结果（文件变化后自动重新加载，最多 MaxCodeIntelCached 个）
+var (
+	codeIntelCache      = make(map[string]*codeIntelWorkspace)
+	codeIntelCacheMutex sync.Mutex
+)
+
+// ExecuteCodeIntel Go代码智能（definition/references/implementations/type_info）
+func ExecuteCodeIntel(args map[string]interface{}, sm *state.Manager) string {
+	action, _ := args["action"].(string)
+
+	if machine := resolveMachine(args, sm); machine != "local" {
+		return fmt.Sprintf("[✗] code_intel 目前只支持本地机器（%s 上请用 file_operation 的 search）", machine)
+	}
+
+	// 相对路径和结果中的显示路径都以持久Shell的当前目录为准
+	workDir := sm.WorkDir("local")
+	file, _ := args["file"].(string)
+	start := workDir
+	if file != "" {
+		file = resolvePath(file, "local", sm)
+		args["file"] = file
+		start = filepath.Dir(file)
+	} else if p, ok := args["path"].(string); ok && p != "" {
+		start = resolvePath(p, "local", sm)
+	}
+
+	root, err := findModuleRoot(start)
+	if err != nil {
+		return fmt.Sprintf("[✗] %v", err)
+	}
+
+	ws, err := loadCodeIntelWorkspace(root)
+	if err != nil {
+		return fmt.Sprintf("[✗] 加载Go包失败: %v", err)
+	}
+
+	targets, err := resolveCodeIntelTargets(ws, args)
+	if err != nil {
+		return fmt.Sprintf("[✗] %v", err)
+	}
+
+	var result string
+	switch action {
+	case "definition":
+		result = codeIntelDefinition(ws, targets, workDir)
+	case "references":
+		if len(targets) > 1 {
+			return ambiguousTargets(ws, targets, workDir)
+		}
+		result = codeIntelReferences(ws, targets[0], workDir)
+	case "implementations":
+		if len(targets) > 1 {
+			return ambiguousTargets(ws, targets, workDir)
+		}
+		result = codeIntelImplementations(ws, targets[0], workDir)
+	case "type_info":
+		result = codeIntelTypeInfo(ws, targets, workDir)
+	default:
+		return fmt.Sprintf("[✗] 未知code_intel操作: %s", action)
+	}
+
+	if len(ws.Errors) > 0 {
+		result += fmt.Sprintf("\n[!] 模块存在 %d 个编译错误，结果可能不完整，例如:\n  %s",
+			len(ws.Errors), strings.Join(ws.Errors[:min(len(ws.Errors), MaxCodeIntelErrors)], "\n  "))
+	}
+	return result
+}
+
+// findModuleRoot 向上查找 go.mod 所在目录
+func findModuleRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d, nil
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("%s 不在Go模块中（找不到 go.mod）", abs)
+		}
+	}
+}
+
+// loadCodeIntelWorkspace 加载模块内全部包（命中缓存时直接返回）
+func loadCodeIntelWorkspace(root string) (*codeIntelWorkspace, error) {
+	fingerprint := goSourceFingerprint(root)
+
+	codeIntelCacheMutex.Lock()
+	ws, ok := codeIntelCache[root]
+	if ok && ws.Fingerprint == fingerprint {
+		ws.lastUsed = time.Now()
+		codeIntelCacheMutex.Unlock()
+		return ws, nil
+	}
+	// 文件已变化的旧结果先丢掉，不在重新加载期间同时占着内存
+	delete(codeIntelCache, root)
+	codeIntelCacheMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), CodeIntelLoadTimeout)
+	defer cancel()
+
+	// 依赖也从源码做类型检查（NeedDeps），不读编译器导出数据：
+	// 本机Go版本比 x/tools 新时导出数据格式读不了，go/packages 会直接 log.Fatal
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+			packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax,
+		Context: ctx,
+		Dir:     root,
+		Fset:    fset,
+		Tests:   true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	ws = &codeIntelWorkspace{Root: root, Fset: fset, Packages: pkgs, Fingerprint: fingerprint}
+	inModule := make(map[string]bool)
+	for _, p := range pkgs {
+		inModule[p.ID] = true
+	}
+	seen := make(map[string]bool)
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if !inModule[p.ID] {
+			// 模块外的依赖只保留类型，语法树和类型信息占内存又用不到
+			p.Syntax, p.TypesInfo = nil, nil
+			return
+		}
+		for _, e := range p.Errors {
+			if !seen[e.Error()] {
+				seen[e.Error()] = true
+				ws.Errors = append(ws.Errors, e.Error())
+			}
+		}
+	})
+
+	codeIntelCacheMutex.Lock()
+	ws.lastUsed = time.Now()
+	codeIntelCache[root] = ws
+	for len(codeIntelCache) > MaxCodeIntelCached {
+		oldest := ""
+		for r, w := range codeIntelCache {
+			if oldest == "" || w.lastUsed.Before(codeIntelCache[oldest].lastUsed) {
+				oldest = r
+			}
+		}
+		delete(codeIntelCache, oldest)
+	}
+	codeIntelCacheMutex.Unlock()
+	return ws, nil
+}
+
+// goSourceFingerprint 模块内Go源文件的指纹（路径+大小+修改时间）
+func goSourceFingerprint(root string) string {
+	h := fnv.New64a()
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && isSkippedDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") && d.Name() != "go.mod" && d.Name() != "go.sum" {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(h, "%s|%d|%d\n", p, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// resolveCodeIntelTargets 按位置（file+line+column/name）或符号名（symbol）定位目标
+func resolveCodeIntelTargets(ws *codeIntelWorkspace, args map[string]interface{}) ([]codeIntelTarget, error) {
+	if symbol, _ := args["symbol"].(string); symbol != "" {
+		targets := lookupSymbol(ws, symbol)
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("未找到符号: %s", symbol)
+		}
+		return targets, nil
+	}
+
+	file, _ := args["file"].(string)
+	line := intArg(args, "line", 0)
+	if file == "" || line <= 0 {
+		return nil, fmt.Errorf("需要 symbol，或者 file + line（再加 column 或 name）来定位符号")
+	}
+	column := intArg(args, "column", 0)
+	name, _ := args["name"].(string)
+	if column <= 0 && name == "" {
+		return nil, fmt.Errorf("按位置定位时需要 column 或 name（该行上的标识符）")
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, f := findSyntaxFile(ws, abs)
+	if f == nil {
+		return nil, fmt.Errorf("文件不属于模块中的任何包（或被构建标签排除）: %s", file)
+	}
+
+	var found *ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		pos := ws.Fset.Position(id.Pos())
+		if pos.Line != line {
+			return true
+		}
+		if column > 0 {
+			if column >= pos.Column && column < pos.Column+len(id.Name) {
+				found = id
+			}
+		} else if id.Name == name {
+			found = id
+		}
+		return true
+	})
+	if found == nil {
+		if name != "" {
+			return nil, fmt.Errorf("%s:%d 上没有标识符 %s", file, line, name)
+		}
+		return nil, fmt.Errorf("%s:%d:%d 处没有标识符", file, line, column)
+	}
+
+	obj := pkg.TypesInfo.ObjectOf(found)
+	if obj == nil {
+		if implicit := pkg.TypesInfo.Implicits[found]; implicit != nil {
+			obj = implicit
+		} else {
+			return nil, fmt.Errorf("无法解析 %s 的类型信息（可能是包名或有编译错误）", found.Name)
+		}
+	}
+	return []codeIntelTarget{{Obj: originObject(obj), Pkg: pkg}}, nil
+}
+
+// findSyntaxFile 找到包含指定文件的包（优先非测试变体）
+func findSyntaxFile(ws *codeIntelWorkspace, abs string) (*packages.Package, *ast.File) {
+	var fallbackPkg *packages.Package
+	var fallbackFile *ast.File
+	for _, pkg := range ws.Packages {
+		for _, f := range pkg.Syntax {
+			if ws.Fset.File(f.Pos()).Name() != abs {
+				continue
+			}
+			if !isTestVariant(pkg) {
+				return pkg, f
+			}
+			if fallbackFile == nil {
+				fallbackPkg, fallbackFile = pkg, f
+			}
+		}
+	}
+	return fallbackPkg, fallbackFile
+}
+
+// isTestVariant 是否为 go/packages 生成的测试变体（如 "p [p.test]"）
+func isTestVariant(pkg *packages.Package) bool {
+	return pkg.ID != pkg.PkgPath
+}
+
+// lookupSymbol 按名称查找：Name、Type.Method、(*Type).Method、pkg.Name、pkg.Type.Method
+func lookupSymbol(ws *codeIntelWorkspace, symbol string) []codeIntelTarget {
+	cleaned := strings.NewReplacer("(", "", ")", "", "*", "").Replace(strings.TrimSpace(symbol))
+	parts := strings.Split(cleaned, ".")
+
+	var targets []codeIntelTarget
+	seen := make(map[string]bool)
+	add := func(obj types.Object, pkg *packages.Package) {
+		if obj == nil {
+			return
+		}
+		key := objectKey(ws.Fset, obj)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		targets = append(targets, codeIntelTarget{Obj: obj, Pkg: pkg})
+	}
+
+	// 成员查找：Type.Member
+	member := func(pkg *packages.Package, typeName, name string) types.Object {
+		tn, ok := pkg.Types.Scope().Lookup(typeName).(*types.TypeName)
+		if !ok {
+			return nil
+		}
+		obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg.Types, name)
+		return obj
+	}
+
+	// 非测试变体优先，保证和其他包引用的是同一个对象
+	ordered := make([]*packages.Package, 0, len(ws.Packages))
+	for _, pkg := range ws.Packages {
+		if !isTestVariant(pkg) {
+			ordered = append(ordered, pkg)
+		}
+	}
+	for _, pkg := range ws.Packages {
+		if isTestVariant(pkg) {
+			ordered = append(ordered, pkg)
+		}
+	}
+
+	for _, pkg := range ordered {
+		if pkg.Types == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		switch len(parts) {
+		case 1:
+			add(scope.Lookup(parts[0]), pkg)
+		case 2:
+			if pkg.Name == parts[0] {
+				add(scope.Lookup(parts[1]), pkg)
+			}
+			add(member(pkg, parts[0], parts[1]), pkg)
+		case 3:
+			if pkg.Name == parts[0] {
+				add(member(pkg, parts[1], parts[2]), pkg)
+			}
+		}
+	}
+	return targets
+}
+
+// codeIntelDefinition 跳转到定义
+func codeIntelDefinition(ws *codeIntelWorkspace, targets []codeIntelTarget, workDir string) string {
+	var sb strings.Builder
+	for i, t := range targets {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("[定义] %s (%s)\n", symbolName(t.Obj), objectKind(t.Obj)))
+		if !t.Obj.Pos().IsValid() {
+			sb.WriteString("  内置符号，没有源码位置\n")
+			continue
+		}
+
+		start, end, doc := declarationRange(ws, t.Obj)
+		pos := ws.Fset.Position(t.Obj.Pos())
+		if end > start {
+			sb.WriteString(fmt.Sprintf("  位置: %s:%d-%d\n", displayPath(pos.Filename, workDir), start, end))
+		} else {
+			sb.WriteString(fmt.Sprintf("  位置: %s:%d\n", displayPath(pos.Filename, workDir), pos.Line))
+		}
+		sb.WriteString(fmt.Sprintf("  签名: %s\n", objectString(t.Obj)))
+		if doc != "" {
+			sb.WriteString(fmt.Sprintf("  文档: %s\n", doc))
+		}
+	}
+	if len(targets) > 1 {
+		sb.WriteString(fmt.Sprintf("\n[i] 找到 %d 个同名符号，可用 包名.符号 或 类型.方法 缩小范围", len(targets)))
+	}
+	return sb.String()
+}
+
+// codeIntelReferences 查找所有引用（含声明处）
+func codeIntelReferences(ws *codeIntelWorkspace, target codeIntelTarget, workDir string) string {
+	key := objectKey(ws.Fset, target.Obj)
+
+	type ref struct {
+		pos  token.Position
+		decl bool
+	}
+	seen := make(map[string]bool)
+	var refs []ref
+	collect := func(m map[*ast.Ident]types.Object, decl bool) {
+		for id, obj := range m {
+			if obj == nil || objectKey(ws.Fset, originObject(obj)) != key {
+				continue
+			}
+			pos := ws.Fset.Position(id.Pos())
+			k := fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			refs = append(refs, ref{pos: pos, decl: decl})
+		}
+	}
+	for _, pkg := range ws.Packages {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		collect(pkg.TypesInfo.Defs, true)
+		collect(pkg.TypesInfo.Uses, false)
+	}
+
+	if len(refs) == 0 {
+		return fmt.Sprintf("[引用] %s 在模块内没有引用", symbolName(target.Obj))
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].pos.Filename != refs[j].pos.Filename {
+			return refs[i].pos.Filename < refs[j].pos.Filename
+		}
+		if refs[i].pos.Line != refs[j].pos.Line {
+			return refs[i].pos.Line < refs[j].pos.Line
+		}
+		return refs[i].pos.Column < refs[j].pos.Column
+	})
+
+	files := make(map[string]bool)
+	for _, r := range refs {
+		files[r.pos.Filename] = true
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[引用] %s (%s): %d 处，分布在 %d 个文件\n", symbolName(target.Obj), objectKind(target.Obj), len(refs), len(files)))
+
+	lines := newSourceLineCache()
+	lastFile := ""
+	for i, r := range refs {
+		if i >= MaxCodeIntelResults {
+			sb.WriteString(fmt.Sprintf("\n[!] 只显示前 %d 处引用", MaxCodeIntelResults))
+			break
+		}
+		if r.pos.Filename != lastFile {
+			sb.WriteString(fmt.Sprintf("\n%s:\n", displayPath(r.pos.Filename, workDir)))
+			lastFile = r.pos.Filename
+		}
+		mark := ""
+		if r.decl {
+			mark = "  ← 定义"
+		}
+		sb.WriteString(fmt.Sprintf("  %d: %s%s\n", r.pos.Line, truncateLine(lines.get(r.pos.Filename, r.pos.Line)), mark))
+	}
+	return sb.String()
+}
+
+// codeIntelImplementations 接口 → 实现它的类型；具体类型 → 它实现的接口
+func codeIntelImplementations(ws *codeIntelWorkspace, target codeIntelTarget, workDir string) string {
+	tn, ok := target.Obj.(*types.TypeName)
+	if !ok {
+		return fmt.Sprintf("[✗] %s 不是类型，implementations 需要接口名或类型名", symbolName(target.Obj))
+	}
+
+	type impl struct {
+		name string
+		obj  types.Object
+	}
+	var found []impl
+	seen := make(map[string]bool)
+
+	// 遍历模块内（非测试变体）包的所有具名类型
+	eachNamed := func(fn func(tn *types.TypeName)) {
+		for _, pkg := range ws.Packages {
+			if pkg.Types == nil || isTestVariant(pkg) {
+				continue
+			}
+			scope := pkg.Types.Scope()
+			for _, name := range scope.Names() {
+				other, ok := scope.Lookup(name).(*types.TypeName)
+				if !ok || other.IsAlias() {
+					continue
+				}
+				if named, ok := other.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+					continue // 泛型类型未实例化，跳过
+				}
+				key := objectKey(ws.Fset, other)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				fn(other)
+			}
+		}
+	}
+
+	var header string
+	if iface, ok := tn.Type().Underlying().(*types.Interface); ok {
+		if iface.NumMethods() == 0 {
+			return fmt.Sprintf("[✗] %s 是空接口，所有类型都实现了它", symbolName(tn))
+		}
+		header = fmt.Sprintf("[实现] 实现接口 %s 的类型", symbolName(tn))
+		eachNamed(func(other *types.TypeName) {
+			if other == tn || types.IsInterface(other.Type()) {
+				return
+			}
+			switch {
+			case types.Implements(other.Type(), iface):
+				found = append(found, impl{name: symbolName(other), obj: other})
+			case types.Implements(types.NewPointer(other.Type()), iface):
+				found = append(found, impl{name: "*" + symbolName(other), obj: other})
+			}
+		})
+	} else {
+		header = fmt.Sprintf("[实现] 类型 %s 实现的接口", symbolName(tn))
+		ptr := types.NewPointer(tn.Type())
+		check := func(other *types.TypeName) {
+			iface, ok := other.Type().Underlying().(*types.Interface)
+			if !ok || iface.NumMethods() == 0 {
+				return
+			}
+			switch {
+			case types.Implements(tn.Type(), iface):
+				found = append(found, impl{name: symbolName(other), obj: other})
+			case types.Implements(ptr, iface):
+				found = append(found, impl{name: symbolName(other) + "（需用指针 *" + tn.Name() + "）", obj: other})
+			}
+		}
+		check(types.Universe.Lookup("error").(*types.TypeName))
+		eachNamed(check)
+	}
+
+	if len(found) == 0 {
+		return header + ": 模块内没有找到"
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].name < found[j].name })
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s（%d 个）:\n", header, len(found)))
+	for i, f := range found {
+		if i >= MaxCodeIntelResults {
+			sb.WriteString(fmt.Sprintf("[!] 只显示前 %d 个\n", MaxCodeIntelResults))
+			break
+		}
+		if !f.obj.Pos().IsValid() {
+			sb.WriteString(fmt.Sprintf("  %s  (内置)\n", f.name))
+			continue
+		}
+		start, end, _ := declarationRange(ws, f.obj)
+		pos := ws.Fset.Position(f.obj.Pos())
+		if end > start {
+			sb.WriteString(fmt.Sprintf("  %s  %s:%d-%d\n", f.name, displayPath(pos.Filename, workDir), start, end))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s  %s:%d\n", f.name, displayPath(pos.Filename, workDir), pos.Line))
+		}
+	}
+	return sb.String()
+}
+
+// codeIntelTypeInfo 类型信息（类型、底层类型、方法集、常量值）
+func codeIntelTypeInfo(ws *codeIntelWorkspace, targets []codeIntelTarget, workDir string) string {
+	var sb strings.Builder
+	for i, t := range targets {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		obj := t.Obj
+		sb.WriteString(fmt.Sprintf("[类型] %s (%s)\n", symbolName(obj), objectKind(obj)))
+		sb.WriteString(fmt.Sprintf("  声明: %s\n", objectString(obj)))
+		if obj.Type() != nil {
+			sb.WriteString(fmt.Sprintf("  类型: %s\n", types.TypeString(obj.Type(), qualifyByPackageName)))
+		}
+		if obj.Pos().IsValid() {
+			pos := ws.Fset.Position(obj.Pos())
+			sb.WriteString(fmt.Sprintf("  位置: %s:%d\n", displayPath(pos.Filename, workDir), pos.Line))
+		}
+
+		switch o := obj.(type) {
+		case *types.Const:
+			sb.WriteString(fmt.Sprintf("  值: %s\n", o.Val().ExactString()))
+		case *types.TypeName:
+			switch under := o.Type().Underlying().(type) {
+			case *types.Struct, *types.Interface:
+				// 声明里已经包含
+			default:
+				sb.WriteString(fmt.Sprintf("  底层类型: %s\n", types.TypeString(under, qualifyByPackageName)))
+			}
+			sb.WriteString("  方法:\n")
+			writeMethodSet(&sb, o.Type())
+		case *types.Var:
+			// 变量/字段：顺带列出其类型的方法
+			if named := namedOf(o.Type()); named != nil {
+				sb.WriteString(fmt.Sprintf("  类型 %s 的方法:\n", named.Obj().Name()))
+				writeMethodSet(&sb, named)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// writeMethodSet 输出 *T 的方法集（指针接收者的方法标出）
+func writeMethodSet(sb *strings.Builder, t types.Type) {
+	if types.IsInterface(t) {
+		iface := t.Underlying().(*types.Interface)
+		for i := 0; i < iface.NumMethods(); i++ {
+			m := iface.Method(i)
+			sb.WriteString(fmt.Sprintf("    %s%s\n", m.Name(), strings.TrimPrefix(types.TypeString(m.Type(), qualifyByPackageName), "func")))
+		}
+		return
+	}
+	mset := types.NewMethodSet(types.NewPointer(t))
+	valueSet := types.NewMethodSet(t)
+	if mset.Len() == 0 {
+		sb.WriteString("    （没有方法）\n")
+		return
+	}
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj()
+		recv := ""
+		if valueSet.Lookup(m.Pkg(), m.Name()) == nil {
+			recv = "  [指针接收者]"
+		}
+		sb.WriteString(fmt.Sprintf("    %s%s%s\n", m.Name(), strings.TrimPrefix(types.TypeString(m.Type(), qualifyByPackageName), "func"), recv))
+	}
+}
+
+// namedOf 取类型（或其指针指向的类型）对应的具名类型
+func namedOf(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// declarationRange 找到声明的行范围（含文档注释）和文档首行
+func declarationRange(ws *codeIntelWorkspace, obj types.Object) (int, int, string) {
+	filename := ws.Fset.Position(obj.Pos()).Filename
+	for _, pkg := range ws.Packages {
+		for _, f := range pkg.Syntax {
+			if ws.Fset.File(f.Pos()).Name() != filename {
+				continue
+			}
+			path, _ := astutil.PathEnclosingInterval(f, obj.Pos(), obj.Pos())
+			var spec ast.Node // 分组声明中没有文档注释的 spec
+			for _, n := range path {
+				var node ast.Node
+				var doc *ast.CommentGroup
+				switch d := n.(type) {
+				case *ast.FuncDecl:
+					node, doc = d, d.Doc
+				case *ast.TypeSpec:
+					node, doc = d, d.Doc
+				case *ast.ValueSpec:
+					node, doc = d, d.Doc
+				case *ast.Field:
+					node, doc = d, d.Doc
+				case *ast.GenDecl:
+					// 单个声明（没有括号）时用整个 GenDecl，文档注释挂在这里
+					if d.Lparen.IsValid() {
+						if spec != nil {
+							return ws.Fset.Position(spec.Pos()).Line, ws.Fset.Position(spec.End()).Line, ""
+						}
+						continue
+					}
+					node, doc = d, d.Doc
+				default:
+					continue
+				}
+				// TypeSpec/ValueSpec 外层如果是无括号的 GenDecl，继续向外取
+				if _, isSpec := n.(ast.Spec); isSpec && doc == nil {
+					spec = node
+					continue
+				}
+				start := ws.Fset.Position(node.Pos()).Line
+				if doc != nil {
+					start = ws.Fset.Position(doc.Pos()).Line
+				}
+				return start, ws.Fset.Position(node.End()).Line, firstDocLine(doc)
+			}
+			return 0, 0, ""
+		}
+	}
+	return 0, 0, ""
+}
+
+// firstDocLine 文档注释的第一行
+func firstDocLine(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	text := strings.TrimSpace(doc.Text())
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+// ambiguousTargets 多个同名符号时提示用户指定
+func ambiguousTargets(ws *codeIntelWorkspace, targets []codeIntelTarget, workDir string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[!] 找到 %d 个同名符号，请用 包名.符号、类型.方法 或 file+line 指定:\n", len(targets)))
+	for _, t := range targets {
+		pos := ws.Fset.Position(t.Obj.Pos())
+		sb.WriteString(fmt.Sprintf("  %s (%s)  %s:%d\n", symbolName(t.Obj), objectKind(t.Obj), displayPath(pos.Filename, workDir), pos.Line))
+	}
+	return sb.String()
+}
+
+// originObject 泛型实例化后的对象映射回原始声明
+func originObject(obj types.Object) types.Object {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin()
+	case *types.Var:
+		return o.Origin()
+	}
+	return obj
+}
+
+// objectKey 跨包（含测试变体）比较对象用的键：声明位置
+func objectKey(fset *token.FileSet, obj types.Object) string {
+	if !obj.Pos().IsValid() {
+		if obj.Pkg() == nil {
+			return obj.Name()
+		}
+		return obj.Pkg().Path() + "." + obj.Name()
+	}
+	pos := fset.Position(obj.Pos())
+	return fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column)
+}
+
+// symbolName 可读的符号名：pkg.Name、(*pkg.Type).Method
+func symbolName(obj types.Object) string {
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			return fmt.Sprintf("(%s).%s", types.TypeString(recv.Type(), qualifyByPackageName), fn.Name())
+		}
+	}
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Name() + "." + obj.Name()
+}
+
+// objectKind 符号种类
+func objectKind(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Func:
+		if o.Type().(*types.Signature).Recv() != nil {
+			return "方法"
+		}
+		return "函数"
+	case *types.TypeName:
+		if types.IsInterface(o.Type()) {
+			return "接口"
+		}
+		if _, ok := o.Type().Underlying().(*types.Struct); ok {
+			return "结构体"
+		}
+		return "类型"
+	case *types.Var:
+		if o.IsField() {
+			return "字段"
+		}
+		return "变量"
+	case *types.Const:
+		return "常量"
+	case *types.PkgName:
+		return "包"
+	case *types.Builtin:
+		return "内置函数"
+	}
+	return "符号"
+}
+
+// objectString 声明形式（包名限定）
+func objectString(obj types.Object) string {
+	return types.ObjectString(obj, qualifyByPackageName)
+}
+
+func qualifyByPackageName(p *types.Package) string {
+	return p.Name()
+}
+
+// displayPath workDir（持久Shell的当前目录）下的文件显示相对路径，方便直接传给 file_operation
+func displayPath(p, workDir string) string {
+	if workDir != "" {
+		if rel, err := filepath.Rel(workDir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return p
+}
+
+// sourceLineCache 读取源码行（引用列表展示用）
+type sourceLineCache map[string][]string
+
+func newSourceLineCache() sourceLineCache {
+	return make(sourceLineCache)
+}
+
+func (c sourceLineCache) get(file string, line int) string {
+	lines, ok := c[file]
+	if !ok {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		c[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line-1])
+}
@@ -1,7 +1,12 @@
 package tools
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os/exec"
+	"time"
 
 	"ai_assistant/internal/process"
 	"ai_assistant/internal/state"
@@ -30,8 +35,9 @@ func ExecuteRunCommand(args map[string]interface{}, pm *process.Manager, sm *sta
 
 	// 根据机器类型路由
 	if targetMachine == "local" {
-		// 本地执行
+		// 本地执行，记下Shell的当前目录（文件工具按它解析相对路径）
 		output, err = pm.ExecuteInPersistentShell(command)
+		sm.SetWorkDir("local", pm.ShellDir())
 	} else {
 		// 远程寄生虫执行
 		output, err = sm.ExecuteOnAgent(targetMachine, command)
@@ -62,3 +68,58 @@ func ExecuteRunCommand(args map[string]interface{}, pm *process.Manager, sm *sta
 	return fmt.Sprintf("🖥️ [%s] %s\n[✓] 命令已执行，请查看【终端快照】",
 		machineInfo, command)
 }
+
+// resolveMachine 确定目标机器：优先使用参数指定的machine，否则使用slot1的机器
+func resolveMachine(args map[string]interface{}, sm *state.Manager) string {
+	if machineID, ok := args["machine"].(string); ok && machineID != "" {
+		return machineID
+	}
+	if slot1Machine := sm.GetSlot1Machine(); slot1Machine != nil {
+		return slot1Machine.ID
+	}
+	return "local"
+}
+
+// runArgv 在目标机器上直接执行程序（不经过持久Shell，输出完整返回）
+// 本地用 os/exec，远程用寄生虫的 run action；dir为空表示当前目录（持久Shell所在目录）
+func runArgv(sm *state.Manager, machineID, dir string, timeout time.Duration, argv ...string) (*state.AgentRunResult, error) {
+	if machineID != "local" {
+		return sm.RunOnAgent(machineID, argv, dir, int(timeout.Seconds()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if sm != nil {
+		// 和寄生虫一样，以持久Shell的当前目录为准
+		dir = resolvePath(dir, "local", sm)
+		if dir == "" {
+			dir = sm.WorkDir("local")
+		}
+	}
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := &state.AgentRunResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.TimedOut = true
+		result.ExitCode = -1
+		return result, nil
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+			return result, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
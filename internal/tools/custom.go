@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	appconfig "ai_assistant/internal/config"
+	"ai_assistant/internal/state"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 启动时从配置目录 tools.json 加载的自定义工具（与内置工具重名的已剔除）
+var customTools []appconfig.CustomTool
+
+// placeholderRe 命令模板中的 {参数名}
+var placeholderRe = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// LoadCustomTools 加载自定义工具，返回启用的工具名和需要提示用户的问题
+func LoadCustomTools() ([]string, []string) {
+	declared, warnings := appconfig.LoadCustomTools()
+
+	builtin := make(map[string]bool)
+	for _, t := range builtinTools() {
+		builtin[t.Function.Name] = true
+	}
+
+	customTools = nil
+	var names []string
+	for _, t := range declared {
+		if builtin[t.Name] {
+			warnings = append(warnings, fmt.Sprintf("工具 %s 与内置工具重名，已跳过", t.Name))
+			continue
+		}
+		for _, p := range templateParams(t.Command) {
+			if _, ok := schemaProperty(t, p); !ok {
+				warnings = append(warnings, fmt.Sprintf("工具 %s 的命令中 {%s} 不是声明的参数，将原样保留", t.Name, p))
+			}
+		}
+		if p := quotedPlaceholder(t); p != "" {
+			warnings = append(warnings, fmt.Sprintf("工具 %s 的命令中 {%s} 写在引号里，参数值会跳出引号被当作命令执行，已跳过（去掉 {%s} 两边的引号即可，程序会自动转义）", t.Name, p, p))
+			continue
+		}
+		customTools = append(customTools, t)
+		if t.Approval != "deny" {
+			names = append(names, t.Name)
+		}
+	}
+	return names, warnings
+}
+
+// customToolDefinitions 自定义工具的定义（approval=deny 的不提供给模型）
+func customToolDefinitions() []openai.Tool {
+	var defs []openai.Tool
+	for _, t := range customTools {
+		if t.Approval == "deny" {
+			continue
+		}
+		defs = append(defs, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return defs
+}
+
+// findCustomTool 按名称查找自定义工具
+func findCustomTool(name string) *appconfig.CustomTool {
+	for i := range customTools {
+		if customTools[i].Name == name {
+			return &customTools[i]
+		}
+	}
+	return nil
+}
+
+// ExecuteCustomTool 校验参数、渲染命令模板并在目标机器上执行
+func ExecuteCustomTool(tool *appconfig.CustomTool, args map[string]interface{}, sm *state.Manager) string {
+	if tool.Approval == "deny" {
+		return fmt.Sprintf("[✗] 工具 %s 已在 tools.json 中禁用（approval=deny）", tool.Name)
+	}
+
+	values, err := customToolValues(tool, args)
+	if err != nil {
+		return fmt.Sprintf("[✗] %s 参数错误: %v", tool.Name, err)
+	}
+
+	targetMachine := tool.Machine
+	if targetMachine == "" {
+		targetMachine = resolveMachine(nil, sm)
+	}
+
+	var argv []string
+	var command string
+	if targetMachine == "local" && runtime.GOOS == "windows" {
+		// cmd 的双引号挡不住 %VAR% 展开，换行会结束命令，这两种值无法安全传入
+		for name, v := range values {
+			if strings.ContainsAny(v, "%\r\n") {
+				return fmt.Sprintf("[✗] %s 参数错误: %s 含有 %% 或换行，Windows cmd 下无法安全转义", tool.Name, name)
+			}
+		}
+		command = renderCommand(tool.Command, values, cmdQuote)
+		argv = []string{"cmd", "/C", command}
+	} else {
+		command = renderCommand(tool.Command, values, shellQuote)
+		argv = []string{"sh", "-c", command}
+	}
+
+	start := time.Now()
+	timeout := time.Duration(tool.Timeout) * time.Second
+	res, err := runArgv(sm, targetMachine, "", timeout, argv...)
+	if err != nil {
+		return fmt.Sprintf("[✗] %s 执行失败（%s）: %v\n$ %s", tool.Name, targetMachine, err, command)
+	}
+
+	output := strings.TrimRight(res.Stdout, "\n")
+	if stderr := strings.TrimRight(res.Stderr, "\n"); stderr != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += "[stderr]\n" + stderr
+	}
+	output = truncateBytes(output, tool.MaxOutput)
+	if output == "" {
+		output = "（没有输出）"
+	}
+
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+	switch {
+	case res.TimedOut:
+		return fmt.Sprintf("[✗] %s 超时（%ds，%s）\n$ %s\n%s", tool.Name, tool.Timeout, targetMachine, command, output)
+	case res.ExitCode != 0:
+		return fmt.Sprintf("[✗] %s 失败（%s，退出码 %d，%v）\n$ %s\n%s", tool.Name, targetMachine, res.ExitCode, elapsed, command, output)
+	default:
+		return fmt.Sprintf("[✓] %s 完成（%s，%v）\n$ %s\n%s", tool.Name, targetMachine, elapsed, command, output)
+	}
+}
+
+// customToolValues 按参数的 JSON Schema 校验并转换为命令中使用的字符串
+// 未声明的参数直接拒绝；没传的可选参数使用 default，没有 default 则为空字符串
+func customToolValues(tool *appconfig.CustomTool, args map[string]interface{}) (map[string]string, error) {
+	props, _ := tool.Parameters["properties"].(map[string]interface{})
+
+	var unknown []string
+	for name := range args {
+		if _, ok := props[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("未声明的参数 %s", strings.Join(unknown, ", "))
+	}
+
+	if required, ok := tool.Parameters["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := args[name]; !ok && name != "" {
+				return nil, fmt.Errorf("缺少必需参数 %s", name)
+			}
+		}
+	}
+
+	values := make(map[string]string)
+	for name, p := range props {
+		schema, _ := p.(map[string]interface{})
+		v, ok := args[name]
+		if !ok {
+			v, ok = schema["default"]
+		}
+		if !ok || v == nil {
+			values[name] = ""
+			continue
+		}
+		s, err := schemaValue(name, schema, v)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = s
+	}
+	return values, nil
+}
+
+// schemaValue 检查值的类型和 enum，转为字符串（数组/对象转为JSON）
+func schemaValue(name string, schema map[string]interface{}, v interface{}) (string, error) {
+	typ, _ := schema["type"].(string)
+	var s string
+	switch typ {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("%s 应为字符串", name)
+		}
+		s = str
+	case "integer":
+		f, ok := v.(float64)
+		if !ok || f != float64(int64(f)) {
+			return "", fmt.Errorf("%s 应为整数", name)
+		}
+		s = strconv.FormatInt(int64(f), 10)
+	case "number":
+		f, ok := v.(float64)
+		if !ok {
+			return "", fmt.Errorf("%s 应为数字", name)
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return "", fmt.Errorf("%s 应为 true/false", name)
+		}
+		s = strconv.FormatBool(b)
+	default:
+		if str, ok := v.(string); ok {
+			s = str
+		} else {
+			data, _ := json.Marshal(v)
+			s = string(data)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, e := range enum {
+			if fmt.Sprint(e) == s {
+				return s, nil
+			}
+		}
+		return "", fmt.Errorf("%s 的值 %q 不在允许范围内", name, s)
+	}
+	return s, nil
+}
+
+// schemaProperty 工具参数中名为 name 的属性
+func schemaProperty(tool appconfig.CustomTool, name string) (interface{}, bool) {
+	props, _ := tool.Parameters["properties"].(map[string]interface{})
+	p, ok := props[name]
+	return p, ok
+}
+
+// templateParams 命令模板中出现的占位符名称
+func templateParams(command string) []string {
+	var names []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(command, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// quotedPlaceholder 写在引号里的参数占位符（如 "{msg}"），没有时返回空字符串
+// 转义后的值自带引号，放进引号里反而会把值露在引号外面
+func quotedPlaceholder(tool appconfig.CustomTool) string {
+	placeholders := make(map[int]string)
+	for _, m := range placeholderRe.FindAllStringSubmatchIndex(tool.Command, -1) {
+		name := tool.Command[m[2]:m[3]]
+		if _, ok := schemaProperty(tool, name); ok {
+			placeholders[m[0]] = name
+		}
+	}
+
+	var quote byte
+	for i := 0; i < len(tool.Command); i++ {
+		c := tool.Command[i]
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote != 0 && c == quote:
+			quote = 0
+		case c == '\\' && quote != '\'':
+			i++
+		case quote != 0:
+			if name, ok := placeholders[i]; ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// renderCommand 把 {参数名} 替换为转义后的值，不是参数的占位符（如 awk 的 {print}）原样保留
+func renderCommand(command string, values map[string]string, quote func(string) string) string {
+	return placeholderRe.ReplaceAllStringFunc(command, func(m string) string {
+		if v, ok := values[m[1:len(m)-1]]; ok {
+			return quote(v)
+		}
+		return m
+	})
+}
+
+// shellQuote POSIX shell 单引号转义
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// cmdQuote Windows cmd 双引号转义
+func cmdQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// truncateBytes 超出 max 字节时截断（不拆开UTF-8字符）并注明省略的字节数
+func truncateBytes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("\n... [输出过长，省略 %s]", formatFileSize(int64(len(s)-cut)))
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	appconfig "ai_assistant/internal/config"
+)
+
+func TestQuotedPlaceholder(t *testing.T) {
+	params := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"msg":  map[string]interface{}{"type": "string"},
+			"file": map[string]interface{}{"type": "string"},
+		},
+	}
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"echo {msg}", ""},
+		{"git commit -m {msg} -- {file}", ""},
+		{`echo "{msg}"`, "msg"},
+		{`echo '{msg}'`, "msg"},
+		{`echo "prefix {file} suffix"`, "file"},
+		{`echo 'a' {msg} "b"`, ""},
+		{`echo "it's" {msg}`, ""},
+		{`echo 'say "hi"' {msg}`, ""},
+		{`echo \"{msg}`, ""},
+		{`echo \'{msg}`, ""},
+		{`echo "a\"b" {msg}`, ""},
+		{`echo "a\"{msg}"`, "msg"},
+		// 不是参数的大括号不算
+		{`awk '{print $1}' {file}`, ""},
+		{`awk '{print}' {file}`, ""},
+		{`echo "{other}" {msg}`, ""},
+	}
+	for _, tt := range tests {
+		tool := appconfig.CustomTool{Name: "t", Command: tt.command, Parameters: params}
+		if got := quotedPlaceholder(tool); got != tt.want {
+			t.Errorf("quotedPlaceholder(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestShellAndCmdQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		shell string
+		cmd   string
+	}{
+		{"", `''`, `""`},
+		{"plain", `'plain'`, `"plain"`},
+		{"it's", `'it'\''s'`, `"it's"`},
+		{`say "hi"`, `'say "hi"'`, `"say ""hi"""`},
+		{"$(rm -rf x)", `'$(rm -rf x)'`, `"$(rm -rf x)"`},
+		{"a; rm -rf b", `'a; rm -rf b'`, `"a; rm -rf b"`},
+		{"line1\nline2", "'line1\nline2'", "\"line1\nline2\""},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.value); got != tt.shell {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.value, got, tt.shell)
+		}
+		if got := cmdQuote(tt.value); got != tt.cmd {
+			t.Errorf("cmdQuote(%q) = %q, want %q", tt.value, got, tt.cmd)
+		}
+	}
+}
+
+func TestRenderCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		values  map[string]string
+		want    string
+	}{
+		{"echo {msg}", map[string]string{"msg": "hi"}, `echo 'hi'`},
+		{"echo {msg}", map[string]string{"msg": "it's"}, `echo 'it'\''s'`},
+		{"echo {msg}", map[string]string{"msg": "$(id); `id`"}, "echo '$(id); `id`'"},
+		{"cp {src} {dst}", map[string]string{"src": "a b", "dst": "c"}, `cp 'a b' 'c'`},
+		{"echo {msg} {msg}", map[string]string{"msg": "x"}, `echo 'x' 'x'`},
+		// 不是参数的占位符原样保留
+		{`awk '{print}' {file}`, map[string]string{"file": "a.txt"}, `awk '{print}' 'a.txt'`},
+		{`awk '{print $1}' {file}`, map[string]string{"file": "a.txt"}, `awk '{print $1}' 'a.txt'`},
+		{"echo {missing}", map[string]string{"msg": "x"}, "echo {missing}"},
+		{"echo {bad-name} {}", map[string]string{"bad-name": "x"}, "echo {bad-name} {}"},
+	}
+	for _, tt := range tests {
+		if got := renderCommand(tt.command, tt.values, shellQuote); got != tt.want {
+			t.Errorf("renderCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+// TestRenderCommandShellRoundTrip 转义后的值经过 sh 原样传给命令
+func TestRenderCommandShellRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	values := []string{
+		"it's",
+		`say "hi"`,
+		"$(echo injected)",
+		"`echo injected`",
+		"a; echo injected",
+		"line1\nline2",
+		`back\slash $HOME`,
+	}
+	for _, v := range values {
+		command := renderCommand("printf %s {msg}", map[string]string{"msg": v}, shellQuote)
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			t.Fatalf("sh -c %q: %v", command, err)
+		}
+		if string(out) != v {
+			t.Errorf("value %q came back as %q (command %q)", v, out, command)
+		}
+	}
+}
@@ -2,48 +2,80 @@ package tools
 
 import "github.com/sashabaranov/go-openai"
 
-// GetToolsSimplified 返回简化后的工具定义
+// GetToolsSimplified 返回简化后的工具定义（内置工具 + tools.json 中的自定义工具 + MCP 服务器的工具）
 func GetToolsSimplified() []openai.Tool {
+	tools := append(builtinTools(), customToolDefinitions()...)
+	return append(tools, mcpToolDefinitions()...)
+}
+
+// builtinTools 内置工具定义
+func builtinTools() []openai.Tool {
 	return []openai.Tool{
-		// 1. 文件操作工具（整合：read/edit/rename/delete/search）
+		// 1. 文件操作工具（整合：read/edit/rename/delete/search/list/outline/按符号修改Go代码/rollback）
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "file_operation",
-				Description: "统一的文件操作工具。支持：read(读取)、edit(编辑)、rename(重命名符号)、delete(删除)、search(搜索代码)。不指定machine则在slot1机器执行。",
+				Description: "统一的文件操作工具。支持：read(读取，files 或含通配符的 file 可一次读取多个文件)、edit(编辑)、rename(重命名符号)、delete(删除)、search(搜索代码)、list(列出目录/项目树)、outline(文件大纲：函数/类型等声明及行号范围，大文件先看大纲再按行读取)、replace_symbol/insert_after_symbol/delete_symbol(按名称替换/在其后插入/删除Go声明，自动gofmt，代码片段重复时比edit可靠)、rollback(把文件恢复到本轮修改前)。read结果带行号和文件哈希；old片段不唯一时，edit可改用 start_line/end_line + hash 按行号替换。机械性批量修改（版本号、改键名）用 edit 的 regex/replace_all/expected_count。edit/rename前须在本会话read过该文件，读取后文件被外部修改则拒绝编辑并提示重新读取。edit后会自动做语法检查（Go/JSON内置，其他类型按配置），结果里报告错误位置。读写自动识别GBK/UTF-16/BOM编码和CRLF换行并按原格式写回，二进制文件只返回摘要。不指定machine则在slot1机器执行。",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"action": map[string]interface{}{
 							"type":        "string",
-							"description": "操作类型：read/edit/rename/delete/search",
-							"enum":        []string{"read", "edit", "rename", "delete", "search"},
+							"description": "操作类型：read/edit/rename/delete/search/list/outline/replace_symbol/insert_after_symbol/delete_symbol/rollback",
+							"enum":        []string{"read", "edit", "rename", "delete", "search", "list", "outline", "replace_symbol", "insert_after_symbol", "delete_symbol", "rollback"},
 						},
 						"file": map[string]interface{}{
 							"type":        "string",
-							"description": "文件路径（所有操作都需要）",
+							"description": "文件路径（除search/list外都必需；相对路径按目标机器的当前目录，即 run_command cd 后的目录解析；read时可用glob通配符，如 /etc/nginx/sites-enabled/*）",
 						},
 						"machine": map[string]interface{}{
 							"type":        "string",
 							"description": "机器ID（可选，不填则使用slot1的机器）",
 						},
 						// read 专用
+						"files": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "read操作一次读取多个文件：路径或glob模式列表（支持 **）。合计超过256KB的文件会跳过并在结果中列出",
+						},
 						"start_line": map[string]interface{}{
 							"type":        "integer",
-							"description": "起始行号（read操作，读取大文件时使用）",
+							"description": "起始行号（read操作读取大文件时使用；edit操作按行号编辑时为要替换的第一行）",
 						},
 						"end_line": map[string]interface{}{
 							"type":        "integer",
-							"description": "结束行号（read操作，读取大文件时使用）",
+							"description": "结束行号（read操作读取大文件时使用；edit操作按行号编辑时为要替换的最后一行，默认等于start_line）",
 						},
 						// edit 专用
 						"old": map[string]interface{}{
 							"type":        "string",
-							"description": "要替换的内容（edit操作必需，必须唯一匹配）",
+							"description": "要替换的内容（edit操作必需，必须唯一匹配，不要带read结果中的行号前缀）；按行号编辑时为这些行的原内容，用于校验",
+						},
+						"replace_all": map[string]interface{}{
+							"type":        "boolean",
+							"description": "edit时替换所有匹配（默认要求唯一匹配），结果中显示修改前后的行",
+						},
+						"expected_count": map[string]interface{}{
+							"type":        "integer",
+							"description": "edit时预期的匹配数，实际不符则不修改（配合replace_all防止误改）",
+						},
+						"hash": map[string]interface{}{
+							"type":        "string",
+							"description": "read结果标题中的文件哈希（edit按行号编辑时与old二选一，文件在读取后变化则拒绝编辑）",
 						},
 						"new": map[string]interface{}{
 							"type":        "string",
-							"description": "新内容（edit操作必需）",
+							"description": "新内容（edit操作必需，按行号编辑时为空表示删除这些行；replace_symbol/insert_after_symbol 时为完整的Go声明代码）",
+						},
+						"format": map[string]interface{}{
+							"type":        "boolean",
+							"description": "edit后自动格式化（Go用gofmt，其他类型用配置的format命令；默认按配置auto_format）",
+						},
+						// 按符号修改Go代码专用
+						"symbol": map[string]interface{}{
+							"type":        "string",
+							"description": "Go声明名称（replace_symbol/insert_after_symbol/delete_symbol必需），如 BuildSystemPrompt、(*Manager).Save、Manager.Save、MaxReadLines。replace时新代码不带注释则保留原文档注释。var a, b = 1, 2 这类一条声明多个名称的不支持，请用 edit",
 						},
 						// rename 专用
 						"old_symbol": map[string]interface{}{
@@ -57,18 +89,60 @@ func GetToolsSimplified() []openai.Tool {
 						// search 专用
 						"query": map[string]interface{}{
 							"type":        "string",
-							"description": "搜索关键词（search操作必需）",
+							"description": "搜索关键词（search操作必需，默认按字面量匹配）",
 						},
 						"path": map[string]interface{}{
 							"type":        "string",
-							"description": "搜索路径（search操作，默认当前目录）",
+							"description": "搜索或列出的路径（search/list操作，默认当前目录）",
 						},
-						"file_pattern": map[string]interface{}{
+						"regex": map[string]interface{}{
+							"type":        "boolean",
+							"description": "按正则解析：search操作中的query；edit操作中的old（Go RE2语法，new中可用 $1、${name} 引用捕获组）。默认false",
+						},
+						"case": map[string]interface{}{
+							"type":        "string",
+							"description": "大小写：sensitive(默认)/ignore/smart（search操作，smart表示query全小写时忽略大小写）",
+							"enum":        []string{"sensitive", "ignore", "smart"},
+						},
+						"include": map[string]interface{}{
+							"type":        "string",
+							"description": "只搜索匹配的文件，逗号分隔glob（search操作，如*.go,cmd/**/*.go）",
+						},
+						"exclude": map[string]interface{}{
 							"type":        "string",
-							"description": "文件过滤（search操作，如*.go）",
+							"description": "排除的文件或目录，逗号分隔glob（search操作，如*_test.go,docs）",
+						},
+						"no_ignore": map[string]interface{}{
+							"type":        "boolean",
+							"description": "不遵守.gitignore（search/list操作，默认false；.git/vendor/node_modules始终不展开）",
+						},
+						"context": map[string]interface{}{
+							"type":        "integer",
+							"description": "每处匹配前后显示的上下文行数（search操作，默认0，最多10）",
+						},
+						"max_results": map[string]interface{}{
+							"type":        "integer",
+							"description": "每页显示的匹配数（search操作，默认50）",
+						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "从第几处匹配开始显示（search操作翻页，默认0）",
+						},
+						// list 专用
+						"depth": map[string]interface{}{
+							"type":        "integer",
+							"description": "列出深度（list操作，默认1，最大10）",
+						},
+						"tree": map[string]interface{}{
+							"type":        "boolean",
+							"description": "以树形显示（list操作，默认false为平铺路径）",
+						},
+						"pattern": map[string]interface{}{
+							"type":        "string",
+							"description": "只列出匹配的文件（list操作，glob如*.go）",
 						},
 					},
-					"required": []string{"action", "file"},
+					"required": []string{"action"},
 				},
 			},
 		},
@@ -96,12 +170,266 @@ func GetToolsSimplified() []openai.Tool {
 			},
 		},
 
-		// 3. 网络搜索工具
+		// 3. 后台进程工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "process",
+				Description: "后台进程管理，用于长时间运行的命令（开发服务器、tail -f、耗时构建）。run_command 只等5秒，这类命令应改用 start 启动后按进程ID增量读取输出。支持：start(启动)、send_input(发送输入)、read_output(读取输出)、kill(终止)、list(列出)。不指定machine则在slot1机器执行。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{
+							"type":        "string",
+							"description": "操作类型",
+							"enum":        []string{"start", "send_input", "read_output", "kill", "list"},
+						},
+						"command": map[string]interface{}{
+							"type":        "string",
+							"description": "要启动的命令（start时必需）",
+						},
+						"cwd": map[string]interface{}{
+							"type":        "string",
+							"description": "工作目录（start时可选）",
+						},
+						"process_id": map[string]interface{}{
+							"type":        "string",
+							"description": "进程ID（send_input/read_output/kill时必需，由start返回）",
+						},
+						"input": map[string]interface{}{
+							"type":        "string",
+							"description": "发送到进程标准输入的内容（send_input时必需，自动补换行）",
+						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "从第几行开始读取（read_output可选，不填则从上次读取结束处继续）",
+						},
+						"max_lines": map[string]interface{}{
+							"type":        "integer",
+							"description": "最多返回行数（默认100，最多500）",
+						},
+						"wait": map[string]interface{}{
+							"type":        "integer",
+							"description": "start/send_input后等待输出的秒数（默认1，最多10）",
+						},
+						"machine": map[string]interface{}{
+							"type":        "string",
+							"description": "机器ID（可选，不填则使用slot1的机器）",
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+
+		// 4. Git工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "git",
+				Description: "结构化Git操作，比在终端里解析 git 输出更可靠。支持：status(状态)、diff(差异)、log(提交历史)、branch(分支列表/创建)、stash(贮藏)、commit(提交)、checkout(切换)、reset(重置)。commit/checkout/reset 等修改操作需要用户批准。不指定machine则在slot1机器执行。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{
+							"type":        "string",
+							"description": "操作类型",
+							"enum":        []string{"status", "diff", "log", "branch", "stash", "commit", "checkout", "reset"},
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "仓库路径（可选，默认当前目录）",
+						},
+						"machine": map[string]interface{}{
+							"type":        "string",
+							"description": "机器ID（可选，不填则使用slot1的机器）",
+						},
+						"file": map[string]interface{}{
+							"type":        "string",
+							"description": "只看某个文件（diff/log可选）",
+						},
+						"staged": map[string]interface{}{
+							"type":        "boolean",
+							"description": "查看暂存区差异（diff可选，默认看未暂存）",
+						},
+						"stat": map[string]interface{}{
+							"type":        "boolean",
+							"description": "只返回每个文件的增删行数（diff可选）",
+						},
+						"ref": map[string]interface{}{
+							"type":        "string",
+							"description": "提交/分支/范围：diff如HEAD~1或main..dev，log起点，checkout目标，reset目标，stash如stash@{1}",
+						},
+						"limit": map[string]interface{}{
+							"type":        "integer",
+							"description": "log返回条数（默认20，最多200）",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "新分支名（branch可选，不填则列出分支）",
+						},
+						"create": map[string]interface{}{
+							"type":        "boolean",
+							"description": "checkout时创建新分支（等同checkout -b）",
+						},
+						"stash_action": map[string]interface{}{
+							"type":        "string",
+							"description": "stash子操作（默认list）",
+							"enum":        []string{"list", "push", "pop", "apply", "drop"},
+						},
+						"message": map[string]interface{}{
+							"type":        "string",
+							"description": "提交信息（commit必需）或贮藏说明（stash push可选）",
+						},
+						"files": map[string]interface{}{
+							"type":        "string",
+							"description": "逗号分隔的文件列表：commit前先git add这些文件；reset时只取消暂存这些文件",
+						},
+						"all": map[string]interface{}{
+							"type":        "boolean",
+							"description": "提交所有已跟踪文件的修改（commit -a）",
+						},
+						"mode": map[string]interface{}{
+							"type":        "string",
+							"description": "reset模式（默认mixed）",
+							"enum":        []string{"soft", "mixed", "hard"},
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+
+		// 5. Go代码智能工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "code_intel",
+				Description: "Go代码智能（基于类型检查，比正则搜索准确）。支持：definition(跳转定义)、references(查找引用)、implementations(接口的实现/类型实现的接口)、type_info(类型、方法集、常量值)。用 symbol 按名称定位，或用 file+line+name/column 按位置定位。结果是 文件:行号，可直接用 file_operation read 的 start_line/end_line 读取。仅支持本地Go模块。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{
+							"type":        "string",
+							"description": "操作类型",
+							"enum":        []string{"definition", "references", "implementations", "type_info"},
+						},
+						"symbol": map[string]interface{}{
+							"type":        "string",
+							"description": "符号名：Name、Type.Method、(*Type).Method、pkg.Name、pkg.Type.Method",
+						},
+						"file": map[string]interface{}{
+							"type":        "string",
+							"description": "按位置定位时的文件路径",
+						},
+						"line": map[string]interface{}{
+							"type":        "integer",
+							"description": "按位置定位时的行号（从1开始）",
+						},
+						"column": map[string]interface{}{
+							"type":        "integer",
+							"description": "列号（从1开始，可选，不知道列号时用name）",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "该行上的标识符名称（代替column）",
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "模块内任意目录（用symbol定位时可选，默认当前目录）",
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+
+		// 6. 测试工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "test",
+				Description: "运行 go test -json 并解析结果：每个用例的通过/失败/跳过、失败输出和断言位置(file:line)、耗时。修复后可用 rerun_failed 只重跑上次失败的用例。比用 run_command 跑 go test 看截断的终端输出更完整。不指定machine则在slot1机器执行。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"packages": map[string]interface{}{
+							"type":        "string",
+							"description": "要测试的包，逗号分隔（默认 ./...）",
+						},
+						"run": map[string]interface{}{
+							"type":        "string",
+							"description": "只运行匹配的测试（go test -run 正则）",
+						},
+						"rerun_failed": map[string]interface{}{
+							"type":        "boolean",
+							"description": "只重跑上次失败的用例（忽略packages和run）",
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "在哪个目录执行（默认当前目录）",
+						},
+						"short": map[string]interface{}{
+							"type":        "boolean",
+							"description": "加 -short",
+						},
+						"race": map[string]interface{}{
+							"type":        "boolean",
+							"description": "加 -race",
+						},
+						"count": map[string]interface{}{
+							"type":        "integer",
+							"description": "-count 次数（传1可禁用测试缓存）",
+						},
+						"timeout": map[string]interface{}{
+							"type":        "integer",
+							"description": "超时秒数（默认300，最多1800）",
+						},
+						"machine": map[string]interface{}{
+							"type":        "string",
+							"description": "机器ID（可选，不填则使用slot1的机器）",
+						},
+					},
+				},
+			},
+		},
+
+		// 7. 编译诊断工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "diagnostics",
+				Description: "运行 go build、go vet（目标机器装了 staticcheck 时也会运行）并把输出解析成 file:line:col 的问题列表，build 和 vet 重复报告的问题会合并。改完代码后用它确认能否编译。不指定machine则在slot1机器执行。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"packages": map[string]interface{}{
+							"type":        "string",
+							"description": "要检查的包，逗号分隔（默认 ./...）",
+						},
+						"tools": map[string]interface{}{
+							"type":        "string",
+							"description": "只运行指定检查，逗号分隔：build、vet、staticcheck（默认全部，未安装的 staticcheck 自动跳过）",
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "在哪个目录执行（默认当前目录）",
+						},
+						"machine": map[string]interface{}{
+							"type":        "string",
+							"description": "机器ID（可选，不填则使用slot1的机器）",
+						},
+					},
+				},
+			},
+		},
+
+		// 8. 网络搜索工具
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
 				Name:        "web_search",
-				Description: "在互联网上搜索信息。",
+				Description: "在互联网上搜索信息。按配置的搜索源顺序尝试（baidu/searxng/brave/bing），某个源失败会自动换下一个，相同关键词短时间内走缓存。",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -111,16 +439,52 @@ func GetToolsSimplified() []openai.Tool {
 						},
 						"max_results": map[string]interface{}{
 							"type":        "integer",
-							"description": "最多返回结果数，默认5",
+							"description": "最多返回结果数，默认5，最多20",
 							"default":     5,
 						},
+						"provider": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"baidu", "searxng", "brave", "bing"},
+							"description": "只用指定的搜索源（可选，默认按配置顺序自动回退）",
+						},
 					},
 					"required": []string{"query"},
 				},
 			},
 		},
 
-		// 5. 文件同步工具（统一）
+		// 9. 网页抓取工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "web_fetch",
+				Description: "打开网页并转换为可读的Markdown文本（用于阅读 web_search 结果、文档页面）。长页面分页返回，同一会话内重复打开走缓存。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"url": map[string]interface{}{
+							"type":        "string",
+							"description": "网页地址（http/https）",
+						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "从第几个字符开始返回（翻页用，默认0）",
+						},
+						"max_chars": map[string]interface{}{
+							"type":        "integer",
+							"description": "本页最多返回字符数（默认8000，最多30000）",
+						},
+						"refresh": map[string]interface{}{
+							"type":        "boolean",
+							"description": "忽略缓存重新抓取（默认false）",
+						},
+					},
+					"required": []string{"url"},
+				},
+			},
+		},
+
+		// 10. 文件同步工具（统一）
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
@@ -158,7 +522,7 @@ func GetToolsSimplified() []openai.Tool {
 			},
 		},
 
-		// 6. 终端管理工具
+		// 11. 终端管理工具
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
@@ -186,5 +550,191 @@ func GetToolsSimplified() []openai.Tool {
 				},
 			},
 		},
+
+		// 12. 长期记忆工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "memory",
+				Description: "跨会话的长期记忆。remember(记住关于机器/项目的事实，如“web-2 的 nginx 装在 /opt”“这个项目用 make release 发布”)、recall(按关键词查，不带query则列出目标机器的全部记忆)、forget(按编号删除过时的)。和当前激活机器、目录相关的记忆已自动放在提示词里，不用每次recall。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{
+							"type":        "string",
+							"description": "操作类型",
+							"enum":        []string{"remember", "recall", "forget"},
+						},
+						"content": map[string]interface{}{
+							"type":        "string",
+							"description": "要记住的内容（remember必需），一句话写清一个事实",
+						},
+						"scope": map[string]interface{}{
+							"type":        "string",
+							"description": "记忆范围：machine(这台机器)、project(这台机器上的某个项目目录)、global(通用)。默认machine，传了project则为project",
+							"enum":        []string{"machine", "project", "global"},
+						},
+						"project": map[string]interface{}{
+							"type":        "string",
+							"description": "项目根目录（scope=project时使用，默认为机器的当前目录）",
+						},
+						"query": map[string]interface{}{
+							"type":        "string",
+							"description": "recall的关键词，多个词用空格分隔（都出现才匹配）",
+						},
+						"id": map[string]interface{}{
+							"type":        "integer",
+							"description": "要删除的记忆编号（forget必需）",
+						},
+						"machine": map[string]interface{}{
+							"type":        "string",
+							"description": "机器ID（可选，不填则使用slot1的机器）",
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+
+		// 13. 任务计划工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "plan",
+				Description: "多步骤任务的计划清单（如把服务迁移到三台机器）。create(创建计划，替换旧的)、add(追加步骤)、update(改某一步的状态/备注/标题)、show(查看)、clear(清除)。计划保存在会话里，并始终显示在提示词中，历史被截断也不会丢。开始做某一步时标 in_progress，做完立刻标 done。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{
+							"type":        "string",
+							"description": "操作类型",
+							"enum":        []string{"create", "add", "update", "show", "clear"},
+						},
+						"goal": map[string]interface{}{
+							"type":        "string",
+							"description": "计划的目标（create必需）",
+						},
+						"steps": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "步骤标题列表（create/add必需），按执行顺序",
+						},
+						"id": map[string]interface{}{
+							"type":        "integer",
+							"description": "步骤编号（update必需）",
+						},
+						"status": map[string]interface{}{
+							"type":        "string",
+							"description": "步骤状态（update使用）",
+							"enum":        []string{"pending", "in_progress", "done", "skipped"},
+						},
+						"note": map[string]interface{}{
+							"type":        "string",
+							"description": "步骤备注，如结果或卡住的原因（update使用，传空字符串清除）",
+						},
+						"title": map[string]interface{}{
+							"type":        "string",
+							"description": "新的步骤标题（update使用）",
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+
+		// 14. 子任务工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "delegate",
+				Description: "把一个独立的调查交给子任务（如“查 web-3 上 nginx 为什么返回502”）。子任务有自己的对话，只能做只读操作（读文件、搜索、只读命令、git查询、诊断、联网），跑完后只返回最终报告，不会把几十条工具输出塞进当前对话。完整记录保存在文件里。需要修改时根据报告自己动手。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"task": map[string]interface{}{
+							"type":        "string",
+							"description": "交给子任务的问题，写清背景、现象和想知道什么（子任务看不到当前对话）",
+						},
+						"machine": map[string]interface{}{
+							"type":        "string",
+							"description": "固定在这台机器上调查（可选，不填则子任务自己选机器，默认slot1）",
+						},
+						"max_steps": map[string]interface{}{
+							"type":        "integer",
+							"description": "最多几轮工具调用（默认20，最多40）",
+						},
+					},
+					"required": []string{"task"},
+				},
+			},
+		},
+
+		// 15. 查找文件工具
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "find",
+				Description: "按文件名glob、类型、大小、修改时间查找文件，可按修改时间或大小排序（如“/var/log 下最近1小时改过的 *.log”）。原生遍历，限制扫描数量和耗时（20秒），比 run_command 的 find 快且不会卡住。默认跳过 .git、node_modules 等目录。不指定machine则在slot1机器执行。",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "从哪个目录开始找（默认当前目录）",
+						},
+						"pattern": map[string]interface{}{
+							"type":        "string",
+							"description": "文件名glob，逗号分隔多个（如 *.log,*.gz）；含 / 时按相对路径匹配（如 **/conf.d/*.conf）",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"description": "类型：f(文件，默认)、d(目录)、l(符号链接)、any",
+							"enum":        []string{"f", "d", "l", "any"},
+						},
+						"min_size": map[string]interface{}{
+							"type":        "string",
+							"description": "最小大小，如 1024、512k、10M、1G",
+						},
+						"max_size": map[string]interface{}{
+							"type":        "string",
+							"description": "最大大小，格式同 min_size",
+						},
+						"newer_than": map[string]interface{}{
+							"type":        "string",
+							"description": "只要这段时间内修改过的，如 30m、1h、2d、1w",
+						},
+						"older_than": map[string]interface{}{
+							"type":        "string",
+							"description": "只要这段时间之前修改的（找旧文件清理），格式同 newer_than",
+						},
+						"max_depth": map[string]interface{}{
+							"type":        "integer",
+							"description": "最大深度（1 表示只看path下一层，默认不限）",
+						},
+						"exclude": map[string]interface{}{
+							"type":        "string",
+							"description": "排除的文件或目录glob，逗号分隔",
+						},
+						"gitignore": map[string]interface{}{
+							"type":        "boolean",
+							"description": "遵守 .gitignore（在项目里找源码时使用，默认false）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序：name(路径，默认)、mtime(最新的在前)、size(最大的在前)",
+							"enum":        []string{"name", "mtime", "size"},
+						},
+						"limit": map[string]interface{}{
+							"type":        "integer",
+							"description": "最多返回多少项（默认100，最多1000）",
+						},
+						"machine": map[string]interface{}{
+							"type":        "string",
+							"description": "机器ID（可选，不填则使用slot1的机器）",
+						},
+					},
+				},
+			},
+		},
 	}
 }
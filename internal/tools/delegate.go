@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	appconfig "ai_assistant/internal/config"
+	"ai_assistant/internal/history"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 子任务限制
+const (
+	DefaultDelegateSteps     = 20 // 默认最多几轮工具调用
+	MaxDelegateSteps         = 40
+	DelegateCallTimeout      = 5 * time.Minute // 单次模型调用超时
+	DelegateCommandTimeout   = 60 * time.Second
+	MaxDelegateCommandOutput = 16 * 1024 // 子任务命令输出最多返回的字节数
+)
+
+// delegateTools 子任务可用的工具（只读），其余工具不会提供给子任务
+var delegateTools = map[string]bool{
+	"file_operation": true,
+	"run_command":    true,
+	"git":            true,
+	"code_intel":     true,
+	"diagnostics":    true,
+	"find":           true,
+	"web_search":     true,
+	"web_fetch":      true,
+}
+
+// delegateFileActions 子任务允许的 file_operation 操作
+var delegateFileActions = map[string]bool{
+	"read":    true,
+	"search":  true,
+	"list":    true,
+	"outline": true,
+}
+
+// executeDelegate 启动一个独立的子对话完成调查，只把最终报告返回给主对话
+func (e *ExecutorSimplified) executeDelegate(args map[string]interface{}) string {
+	task, _ := args["task"].(string)
+	if strings.TrimSpace(task) == "" {
+		return "[✗] delegate缺少task参数（要调查的问题）"
+	}
+	if e.Client == nil {
+		return "[✗] 子任务不可用：没有配置模型客户端"
+	}
+
+	machine, _ := args["machine"].(string)
+	if machine != "" && machine != "local" && e.StateManager.GetMachine(machine) == nil {
+		return fmt.Sprintf("[✗] 机器不存在: %s", machine)
+	}
+	maxSteps := intArg(args, "max_steps", DefaultDelegateSteps)
+	if maxSteps <= 0 || maxSteps > MaxDelegateSteps {
+		maxSteps = MaxDelegateSteps
+	}
+
+	var toolDefs []openai.Tool
+	for _, t := range GetToolsSimplified() {
+		if delegateTools[t.Function.Name] {
+			toolDefs = append(toolDefs, t)
+		}
+	}
+
+	messages := []history.Message{
+		{Role: "system", Content: e.delegateSystemPrompt(machine)},
+		{Role: "user", Content: task},
+	}
+
+	toolCallCount := 0
+	var report string
+	for step := 0; ; step++ {
+		// 步数用完后不再提供工具，要求直接给出报告
+		final := step >= maxSteps
+		if final {
+			messages = append(messages, history.Message{
+				Role:    "user",
+				Content: "工具调用次数已用完，请根据目前掌握的信息直接给出最终报告，说明还有哪些没查清。",
+			})
+		}
+
+		msg, err := e.delegateCompletion(messages, toolDefs, final)
+		if err != nil {
+			path := saveDelegateTranscript(messages)
+			return fmt.Sprintf("[✗] 子任务调用模型失败（第 %d 轮）: %v\n[i] 子任务记录: %s", step+1, err, path)
+		}
+		messages = append(messages, history.Message{
+			Role:             "assistant",
+			Content:          msg.Content,
+			ToolCalls:        msg.ToolCalls,
+			ReasoningContent: msg.ReasoningContent,
+		})
+
+		if len(msg.ToolCalls) == 0 || final {
+			report = strings.TrimSpace(msg.Content)
+			break
+		}
+
+		for _, toolCall := range msg.ToolCalls {
+			toolCallCount++
+			messages = append(messages, history.Message{
+				Role:       "tool",
+				Content:    e.executeDelegateTool(toolCall, machine),
+				ToolCallID: toolCall.ID,
+			})
+		}
+	}
+
+	path := saveDelegateTranscript(messages)
+	if report == "" {
+		report = "（子任务没有给出报告）"
+	}
+	return fmt.Sprintf("[✓] 子任务完成（%d 次工具调用）\n%s\n\n[i] 子任务完整记录: %s", toolCallCount, report, path)
+}
+
+// delegateCompletion 调用一次模型（非流式）
+func (e *ExecutorSimplified) delegateCompletion(messages []history.Message, toolDefs []openai.Tool, final bool) (openai.ChatCompletionMessage, error) {
+	req := openai.ChatCompletionRequest{
+		Model:    appconfig.GlobalConfig.Model,
+		Messages: history.ConvertToOpenAI(messages),
+	}
+	if !final {
+		req.Tools = toolDefs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DelegateCallTimeout)
+	defer cancel()
+	resp, err := e.Client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("模型没有返回结果")
+	}
+	return resp.Choices[0].Message, nil
+}
+
+// executeDelegateTool 执行子任务的工具调用：只放行只读操作，固定机器时强制使用该机器
+func (e *ExecutorSimplified) executeDelegateTool(toolCall openai.ToolCall, machine string) string {
+	name := toolCall.Function.Name
+	if !delegateTools[name] {
+		return fmt.Sprintf("[✗] 子任务不能使用 %s", name)
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return fmt.Sprintf("[✗] 参数解析失败: %v", err)
+	}
+
+	if name == "file_operation" {
+		action, _ := args["action"].(string)
+		if !delegateFileActions[action] {
+			return fmt.Sprintf("[✗] 子任务只能读取文件（read/search/list/outline），不能 %s", action)
+		}
+	}
+	if name == "run_command" {
+		command, _ := args["command"].(string)
+		if reason := delegateCommandRejection(command); reason != "" {
+			return "[✗] " + reason + "。请在报告里写明需要主对话执行什么"
+		}
+	}
+	if machine != "" {
+		args["machine"] = machine
+	}
+
+	data, _ := json.Marshal(args)
+	toolCall.Function.Arguments = string(data)
+
+	// 需要用户批准的操作（非只读命令、git提交等）子任务一律拒绝
+	if e.NeedsImmediateApproval(toolCall) {
+		return "[✗] 子任务只能执行只读操作，这个调用需要用户批准，已拒绝。请在报告里写明需要主对话执行什么"
+	}
+	if name == "run_command" {
+		return e.executeDelegateCommand(args)
+	}
+	return e.Execute(toolCall)
+}
+
+// executeDelegateCommand 子任务的命令在独立进程中执行，输出直接返回给子任务，
+// 不进持久Shell（不改主对话的当前目录）也不写终端快照
+func (e *ExecutorSimplified) executeDelegateCommand(args map[string]interface{}) string {
+	command, _ := args["command"].(string)
+	targetMachine := resolveMachine(args, e.StateManager)
+
+	argv := []string{"sh", "-c", command}
+	if targetMachine == "local" && runtime.GOOS == "windows" {
+		argv = []string{"cmd", "/C", command}
+	}
+	res, err := runArgv(e.StateManager, targetMachine, "", DelegateCommandTimeout, argv...)
+	if err != nil {
+		return fmt.Sprintf("[✗] 命令执行失败（%s）: %v", targetMachine, err)
+	}
+
+	output := strings.TrimRight(res.Stdout, "\n")
+	if stderr := strings.TrimRight(res.Stderr, "\n"); stderr != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += "[stderr]\n" + stderr
+	}
+	output = truncateBytes(output, MaxDelegateCommandOutput)
+	if output == "" {
+		output = "（没有输出）"
+	}
+
+	switch {
+	case res.TimedOut:
+		return fmt.Sprintf("[✗] 命令超时（%v，%s）\n$ %s\n%s", DelegateCommandTimeout, targetMachine, command, output)
+	case res.ExitCode != 0:
+		return fmt.Sprintf("[✗] 命令失败（%s，退出码 %d）\n$ %s\n%s", targetMachine, res.ExitCode, command, output)
+	default:
+		return fmt.Sprintf("[✓] 命令完成（%s）\n$ %s\n%s", targetMachine, command, output)
+	}
+}
+
+// delegateUnsafeFlags 白名单命令中会写文件或执行其他命令的参数
+var delegateUnsafeFlags = []string{"-exec", "-execdir", "-ok", "-okdir", "-delete", "-o", "--output"}
+
+// delegateForbiddenCommands 白名单中子任务也不能用的命令（切换目录会影响主对话的持久Shell）
+var delegateForbiddenCommands = map[string]bool{"cd": true, "pushd": true, "popd": true}
+
+// delegateCommandRejection 子任务的命令比主对话更严：必须是白名单命令（主对话中免批准的那些），
+// 不能有管道、串联、重定向、命令替换，也不能带 -exec/-delete/-o 这类参数；允许时返回空字符串
+func delegateCommandRejection(command string) string {
+	if strings.TrimSpace(command) == "" {
+		return "缺少command参数"
+	}
+	if hasShellControl(command) || strings.Contains(command, "|") {
+		return "子任务的命令不能使用管道、;、&、重定向或命令替换"
+	}
+	if fields := strings.Fields(command); delegateForbiddenCommands[strings.ToLower(fields[0])] {
+		return fmt.Sprintf("子任务不能切换目录（%s），请直接在命令或 path 参数里写完整路径", fields[0])
+	}
+	for _, field := range strings.Fields(command) {
+		for _, flag := range delegateUnsafeFlags {
+			if field == flag || strings.HasPrefix(field, flag+"=") {
+				return fmt.Sprintf("子任务的命令不能使用 %s 参数", flag)
+			}
+		}
+	}
+	if IsBlacklistedCommand(command) || !IsWhitelistedCommand(command) {
+		return "子任务只能执行白名单中的查询命令（ls/cat/head/tail/ps 等），查找和搜索请用 find、file_operation search"
+	}
+	return ""
+}
+
+// delegateSystemPrompt 子任务的系统提示词
+func (e *ExecutorSimplified) delegateSystemPrompt(machine string) string {
+	var sb strings.Builder
+	sb.WriteString(`你是J.A.R.V.I.S派出的调查子任务，独立完成主对话交给你的问题，然后给出一份报告。
+
+规则：
+- 只能做只读操作：读文件、搜索、列目录、白名单查询命令（ls/cat/head/tail/ps 等，不能用管道和重定向）、git 查询、联网查资料；搜内容用 file_operation search，找文件用 find
+- 不要修改任何东西；需要修改或重启的，写进报告的建议里
+- 先想清楚要验证什么，再动手，别漫无目的地翻
+- 查清楚了就停，不用用完所有步数
+
+最终报告（用中文，不再调用工具）包含：
+1. 结论：问题的根因或答案
+2. 证据：关键的 文件:行号、命令输出片段
+3. 建议：主对话下一步该做什么（具体到命令或改动）
+4. 没查清的地方（如果有）
+`)
+
+	sb.WriteString("\n## 可用机器\n")
+	sb.WriteString(e.StateManager.ListMachines())
+	sb.WriteString("\n")
+	if machine != "" {
+		sb.WriteString(fmt.Sprintf("\n所有操作都固定在机器 [%s] 上执行（machine参数会被忽略）。\n", machine))
+	}
+	return sb.String()
+}
+
+// saveDelegateTranscript 保存子任务的完整对话，返回文件路径
+func saveDelegateTranscript(messages []history.Message) string {
+	dir := filepath.Join(appconfig.ConfigDir, "delegates")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Sprintf("（保存失败: %v）", err)
+	}
+	path := filepath.Join(dir, time.Now().Format("20060102_150405.000")+".json")
+	if err := history.Save(path, messages); err != nil {
+		return fmt.Sprintf("（保存失败: %v）", err)
+	}
+	return path
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"ai_assistant/internal/backup"
+	"ai_assistant/internal/process"
+	"ai_assistant/internal/state"
+)
+
+func TestDelegateCommandRejection(t *testing.T) {
+	tests := []struct {
+		command string
+		reject  string // 拒绝原因中应包含的内容，空表示允许
+	}{
+		{"ls -la", ""},
+		{"cat /etc/os-release", ""},
+		{"tail -n 50 /var/log/syslog", ""},
+		{"ps aux", ""},
+		{"git log --oneline", ""},
+
+		{"", "缺少command"},
+		{"   ", "缺少command"},
+		{"ls|rm -rf x", "管道"},
+		{"cat a | head", "管道"},
+		{"cat a > b", "重定向"},
+		{"cat a; rm -rf b", "管道"},
+		{"echo $(id)", "命令替换"},
+		{"echo `id`", "命令替换"},
+		{"ls\nrm -rf x", "管道"},
+		{"find / -delete", "-delete"},
+		{"find . -exec rm {} +", "-exec"},
+		{"sort -o out.txt in.txt", "-o"},
+		{"git diff --output=/etc/hosts", "--output"},
+		{"env rm x", "白名单"},
+		{"rm -rf /", "白名单"},
+		{"vim a.go", "白名单"},
+		{"cd /tmp", "切换目录"},
+		{"CD /tmp", "切换目录"},
+		{"pushd /tmp", "切换目录"},
+		{"popd", "切换目录"},
+	}
+	for _, tt := range tests {
+		got := delegateCommandRejection(tt.command)
+		if tt.reject == "" {
+			if got != "" {
+				t.Errorf("delegateCommandRejection(%q) = %q, want allowed", tt.command, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.reject) {
+			t.Errorf("delegateCommandRejection(%q) = %q, want containing %q", tt.command, got, tt.reject)
+		}
+	}
+}
+
+func TestExecuteDelegateCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	useTempConfigDir(t)
+
+	sm := state.NewManager()
+	e := NewExecutorSimplified(process.NewManager(), backup.NewManager(), sm, nil, nil)
+	workDir := sm.WorkDir("local")
+	snapshot := sm.GetTerminalSnapshot()
+
+	got := e.executeDelegateCommand(map[string]interface{}{"command": "echo delegate-output"})
+	if !strings.HasPrefix(got, "[✓]") || !strings.Contains(got, "delegate-output") {
+		t.Errorf("output not returned to the delegate:\n%s", got)
+	}
+	got = e.executeDelegateCommand(map[string]interface{}{"command": "ls /nonexistent-dir-for-test"})
+	if !strings.HasPrefix(got, "[✗]") || !strings.Contains(got, "[stderr]") {
+		t.Errorf("failure and stderr not reported:\n%s", got)
+	}
+
+	if sm.WorkDir("local") != workDir {
+		t.Errorf("work dir changed: %q -> %q", workDir, sm.WorkDir("local"))
+	}
+	if sm.GetTerminalSnapshot() != snapshot {
+		t.Errorf("delegate command leaked into the terminal snapshot")
+	}
+}
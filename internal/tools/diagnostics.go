@@ -0,0 +1,278 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_assistant/internal/state"
+)
+
+// 诊断工具限制
+const (
+	DiagnosticsTimeout     = 3 * time.Minute
+	AutoDiagnosticsTimeout = time.Minute // 编辑后自动诊断用更短的超时
+	MaxDiagnosticsShown    = 50
+	MaxAutoDiagnostics     = 10 // 编辑后附带的最多条数
+)
+
+// Diagnostic 一条编译/静态检查问题
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+	Tool    string // build/vet/staticcheck
+}
+
+// 诊断行，如 "internal/x/a.go:12:3: undefined: foo"，go vet 的类型错误带 "vet: " 前缀
+var diagnosticLineRe = regexp.MustCompile(`^(?:vet: )?((?:[A-Za-z]:)?[^:\s][^:]*\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// ExecuteDiagnostics 运行 go build / go vet（以及已安装的 staticcheck）并汇总问题
+func ExecuteDiagnostics(args map[string]interface{}, sm *state.Manager) string {
+	targetMachine := resolveMachine(args, sm)
+	dir, _ := args["path"].(string)
+
+	packages := splitPatterns(args["packages"])
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+
+	checks := splitPatterns(args["tools"])
+	explicit := len(checks) > 0
+	if !explicit {
+		checks = []string{"build", "vet", "staticcheck"}
+	}
+
+	var diags []Diagnostic
+	var ran, notes []string
+	for _, check := range checks {
+		if check == "staticcheck" && !commandAvailable(sm, targetMachine, "staticcheck") {
+			// 默认组合里缺少staticcheck时静默跳过，显式指定才提示
+			if explicit {
+				notes = append(notes, "[!] 目标机器没有安装 staticcheck，已跳过")
+			}
+			continue
+		}
+		found, note, err := runDiagnosticCheck(sm, targetMachine, dir, check, packages, DiagnosticsTimeout)
+		if err != nil {
+			return fmt.Sprintf("[✗] %v", err)
+		}
+		ran = append(ran, check)
+		diags = append(diags, found...)
+		if note != "" {
+			notes = append(notes, note)
+		}
+	}
+	if len(ran) == 0 {
+		return "[✗] 没有可运行的检查\n" + strings.Join(notes, "\n")
+	}
+
+	result := formatDiagnostics(dedupeDiagnostics(diags), ran, packages, notes)
+	if targetMachine != "local" {
+		result = fmt.Sprintf("[机器] %s\n%s", targetMachine, result)
+	}
+	return result
+}
+
+// runDiagnosticCheck 运行单项检查；note 为无法解析成诊断的失败输出
+func runDiagnosticCheck(sm *state.Manager, targetMachine, dir, check string, packages []string, timeout time.Duration) ([]Diagnostic, string, error) {
+	var argv []string
+	switch check {
+	case "build":
+		// 丢弃编译产物，避免在目录里留下可执行文件
+		devNull := "/dev/null"
+		if targetMachine == "local" {
+			devNull = os.DevNull
+		}
+		argv = append([]string{"go", "build", "-o", devNull}, packages...)
+	case "vet":
+		argv = append([]string{"go", "vet"}, packages...)
+	case "staticcheck":
+		argv = append([]string{"staticcheck", "-f", "text"}, packages...)
+	default:
+		return nil, "", fmt.Errorf("未知检查: %s（可选 build、vet、staticcheck）", check)
+	}
+
+	res, err := runArgv(sm, targetMachine, dir, timeout, argv...)
+	if err != nil {
+		return nil, "", fmt.Errorf("运行 %s 失败: %v", strings.Join(argv[:2], " "), err)
+	}
+	if res.TimedOut {
+		return nil, "", fmt.Errorf("%s 超时（%v），可用 packages 缩小范围", check, timeout)
+	}
+
+	diags, other := ParseDiagnostics(res.Stderr+"\n"+res.Stdout, check)
+	for i := range diags {
+		if dir != "" && !filepath.IsAbs(diags[i].File) {
+			diags[i].File = filepath.Join(dir, diags[i].File)
+		}
+	}
+
+	// 非零退出却没有解析出问题，说明是环境或参数错误，原样报告
+	var note string
+	if res.ExitCode != 0 && len(diags) == 0 {
+		msg := strings.TrimSpace(strings.Join(other, "\n"))
+		if msg == "" {
+			msg = fmt.Sprintf("退出码 %d，没有输出", res.ExitCode)
+		}
+		note = fmt.Sprintf("[✗] %s 失败:\n%s", check, truncateOutputLines(msg, MaxTestBuildOutput))
+	}
+	return diags, note, nil
+}
+
+// ParseDiagnostics 解析 file:line[:col]: message 形式的输出，返回诊断和其余行
+func ParseDiagnostics(out, tool string) ([]Diagnostic, []string) {
+	var diags []Diagnostic
+	var other []string
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimRight(line, "\r")
+		if strings.TrimSpace(trimmed) == "" || strings.HasPrefix(trimmed, "# ") {
+			continue
+		}
+		if m := diagnosticLineRe.FindStringSubmatch(trimmed); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			col, _ := strconv.Atoi(m[3])
+			diags = append(diags, Diagnostic{
+				File:    filepath.Clean(m[1]),
+				Line:    lineNo,
+				Column:  col,
+				Message: m[4],
+				Tool:    tool,
+			})
+			continue
+		}
+		// 缩进的续行（如 have/want）归到上一条
+		if len(diags) > 0 && strings.HasPrefix(trimmed, "\t") {
+			last := &diags[len(diags)-1]
+			last.Message += "\n    " + strings.TrimSpace(trimmed)
+			continue
+		}
+		if strings.Contains(trimmed, "too many errors") {
+			continue
+		}
+		other = append(other, trimmed)
+	}
+	return diags, other
+}
+
+// dedupeDiagnostics 去掉 build 和 vet 重复报告的同一问题
+func dedupeDiagnostics(diags []Diagnostic) []Diagnostic {
+	seen := make(map[string]bool)
+	var result []Diagnostic
+	for _, d := range diags {
+		key := fmt.Sprintf("%s:%d:%d:%s", d.File, d.Line, d.Column, d.Message)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, d)
+	}
+	return result
+}
+
+// formatDiagnostics 格式化诊断结果
+func formatDiagnostics(diags []Diagnostic, ran, packages, notes []string) string {
+	var sb strings.Builder
+	scope := fmt.Sprintf("%s（%s）", strings.Join(ran, "、"), strings.Join(packages, " "))
+
+	if len(diags) == 0 {
+		if len(notes) > 0 {
+			sb.WriteString(fmt.Sprintf("[!] %s 没有发现可定位的问题\n", scope))
+			sb.WriteString(strings.Join(notes, "\n"))
+			return sb.String()
+		}
+		return fmt.Sprintf("[✓] %s 没有发现问题", scope)
+	}
+
+	counts := make(map[string]int)
+	for _, d := range diags {
+		counts[d.Tool]++
+	}
+	var parts []string
+	for _, tool := range ran {
+		if counts[tool] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", tool, counts[tool]))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("[✗] %s 发现 %d 个问题（%s）:\n", scope, len(diags), strings.Join(parts, ", ")))
+	sb.WriteString(formatDiagnosticLines(diags, MaxDiagnosticsShown))
+	if len(notes) > 0 {
+		sb.WriteString("\n" + strings.Join(notes, "\n"))
+	}
+	return sb.String()
+}
+
+// formatDiagnosticLines 每条诊断一行：file:line:col: [tool] message
+func formatDiagnosticLines(diags []Diagnostic, limit int) string {
+	var lines []string
+	for i, d := range diags {
+		if i >= limit {
+			lines = append(lines, fmt.Sprintf("... 还有 %d 个问题未显示", len(diags)-limit))
+			break
+		}
+		pos := fmt.Sprintf("%s:%d", d.File, d.Line)
+		if d.Column > 0 {
+			pos += fmt.Sprintf(":%d", d.Column)
+		}
+		lines = append(lines, fmt.Sprintf("  %s: [%s] %s", pos, d.Tool, d.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiagnosticsAfterEdit 编辑Go文件后对其所在包运行 build 和 vet，返回追加在编辑结果后的诊断行
+// （通过时也提示一行）；不是Go文件时返回空串
+func DiagnosticsAfterEdit(file, targetMachine string, sm *state.Manager) string {
+	if !strings.HasSuffix(file, ".go") {
+		return ""
+	}
+	dir := filepath.Dir(file)
+
+	var diags []Diagnostic
+	for _, check := range []string{"build", "vet"} {
+		found, note, err := runDiagnosticCheck(sm, targetMachine, dir, check, []string{"."}, AutoDiagnosticsTimeout)
+		if err != nil {
+			return fmt.Sprintf("\n[诊断] %v", err)
+		}
+		if note != "" {
+			// 不在模块内等环境问题不影响编辑结果，只简单提示
+			return fmt.Sprintf("\n[诊断] %s 无法运行: %s", check, firstLine(note))
+		}
+		diags = append(diags, found...)
+		// 编译失败时 vet 只会重复同样的错误
+		if check == "build" && len(found) > 0 {
+			break
+		}
+	}
+	if len(diags) == 0 {
+		return fmt.Sprintf("\n[诊断] %s 所在包 build/vet 通过", filepath.Base(file))
+	}
+	diags = dedupeDiagnostics(diags)
+	return fmt.Sprintf("\n[诊断] %s 所在包有 %d 个问题:\n%s", filepath.Base(file), len(diags), formatDiagnosticLines(diags, MaxAutoDiagnostics))
+}
+
+// commandAvailable 目标机器上是否能找到某个程序
+func commandAvailable(sm *state.Manager, targetMachine, name string) bool {
+	if targetMachine == "local" {
+		_, err := exec.LookPath(name)
+		return err == nil
+	}
+	res, err := runArgv(sm, targetMachine, "", 10*time.Second, "which", name)
+	return err == nil && res.ExitCode == 0
+}
+
+// firstLine 多行文本的第一行非空内容
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "[") {
+			return line
+		}
+	}
+	return strings.TrimSpace(s)
+}
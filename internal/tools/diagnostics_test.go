@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		tool      string
+		wantDiags []Diagnostic
+		wantOther []string
+	}{
+		{
+			name: "build errors",
+			input: `# ai_assistant/internal/x
+internal/x/a.go:12:3: undefined: foo
+internal/x/b.go:7: missing return
+`,
+			tool: "build",
+			wantDiags: []Diagnostic{
+				{File: filepath.Clean("internal/x/a.go"), Line: 12, Column: 3, Message: "undefined: foo", Tool: "build"},
+				{File: filepath.Clean("internal/x/b.go"), Line: 7, Message: "missing return", Tool: "build"},
+			},
+		},
+		{
+			name: "vet prefix",
+			input: `# ai_assistant/internal/x
+vet: internal/x/a.go:5:2: undefined: bar
+internal/x/c.go:20:2: fmt.Sprintf format %d has arg s of wrong type string
+`,
+			tool: "vet",
+			wantDiags: []Diagnostic{
+				{File: filepath.Clean("internal/x/a.go"), Line: 5, Column: 2, Message: "undefined: bar", Tool: "vet"},
+				{File: filepath.Clean("internal/x/c.go"), Line: 20, Column: 2, Message: "fmt.Sprintf format %d has arg s of wrong type string", Tool: "vet"},
+			},
+		},
+		{
+			name: "continuation lines",
+			input: `internal/x/a.go:30:9: not enough return values
+	have (string)
+	want (string, error)
+internal/x/a.go:31:1: missing return
+`,
+			tool: "build",
+			wantDiags: []Diagnostic{
+				{File: filepath.Clean("internal/x/a.go"), Line: 30, Column: 9, Message: "not enough return values\n    have (string)\n    want (string, error)", Tool: "build"},
+				{File: filepath.Clean("internal/x/a.go"), Line: 31, Column: 1, Message: "missing return", Tool: "build"},
+			},
+		},
+		{
+			name:  "windows path and crlf",
+			input: "C:\\src\\x\\a.go:3:1: syntax error: unexpected }\r\n",
+			tool:  "build",
+			wantDiags: []Diagnostic{
+				{File: filepath.Clean(`C:\src\x\a.go`), Line: 3, Column: 1, Message: "syntax error: unexpected }", Tool: "build"},
+			},
+		},
+		{
+			name: "other lines kept, too many errors dropped",
+			input: `go: updates to go.mod needed; to update it:
+	go mod tidy
+internal/x/a.go:1:1: expected 'package', found 'EOF'
+too many errors
+`,
+			tool: "build",
+			wantDiags: []Diagnostic{
+				{File: filepath.Clean("internal/x/a.go"), Line: 1, Column: 1, Message: "expected 'package', found 'EOF'", Tool: "build"},
+			},
+			wantOther: []string{"go: updates to go.mod needed; to update it:", "\tgo mod tidy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags, other := ParseDiagnostics(tt.input, tt.tool)
+			if !reflect.DeepEqual(diags, tt.wantDiags) {
+				t.Errorf("diagnostics:\n got %+v\nwant %+v", diags, tt.wantDiags)
+			}
+			if !reflect.DeepEqual(other, tt.wantOther) {
+				t.Errorf("other lines:\n got %q\nwant %q", other, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestDedupeDiagnostics(t *testing.T) {
+	d := Diagnostic{File: "a.go", Line: 1, Column: 2, Message: "undefined: x"}
+	build, vet := d, d
+	build.Tool, vet.Tool = "build", "vet"
+	got := dedupeDiagnostics([]Diagnostic{build, vet, {File: "a.go", Line: 3, Message: "other", Tool: "vet"}})
+	if len(got) != 2 || got[0].Tool != "build" {
+		t.Errorf("dedupeDiagnostics = %+v", got)
+	}
+}
@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ai_assistant/internal/backup"
+	appconfig "ai_assistant/internal/config"
+	"ai_assistant/internal/state"
+)
+
+// 编辑后检查限制
+const (
+	FileCheckTimeout     = 30 * time.Second
+	MaxSyntaxErrorsShown = 10
+	MaxCheckOutputLines  = 20
+)
+
+// rollbackHint 检查失败时提示如何恢复
+const rollbackHint = "[i] 修正后重新 edit，或用 action=rollback 恢复到本轮修改前的内容"
+
+// CheckEditedFile 编辑写入后做语法检查，按需自动格式化；返回附加到编辑结果后的说明
+// content 是解码后的文本，f 为原文件格式（gofmt 结果按它重新编码后写回）
+// .go 和 .json 内置检查，其他扩展名使用配置中的 file_checkers（配置优先于内置）
+func CheckEditedFile(file, targetMachine string, content []byte, f TextFormat, autoFormat bool, sm *state.Manager) string {
+	ext := strings.ToLower(filepath.Ext(file))
+	if checker, ok := appconfig.GlobalConfig.FileCheckers[ext]; ok {
+		return runFileChecker(checker, file, targetMachine, autoFormat, sm)
+	}
+
+	switch ext {
+	case ".go":
+		return checkGoSource(file, targetMachine, content, f, autoFormat, sm)
+	case ".json":
+		return checkJSONSource(content)
+	default:
+		return ""
+	}
+}
+
+// editAutoFormat 本次编辑是否自动格式化：参数 format 优先，否则看配置 auto_format
+func editAutoFormat(args map[string]interface{}) bool {
+	if v, ok := args["format"].(bool); ok {
+		return v
+	}
+	return appconfig.GlobalConfig.AutoFormat
+}
+
+// checkGoSource 解析Go代码报告语法错误，通过时按需gofmt（按原编码和换行写回）
+func checkGoSource(file, targetMachine string, content []byte, f TextFormat, autoFormat bool, sm *state.Manager) string {
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, file, content, parser.AllErrors); err != nil {
+		var lines []string
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			// 解析器会在同一位置重复报错
+			list.RemoveMultiples()
+			for i, e := range list {
+				if i >= MaxSyntaxErrorsShown {
+					lines = append(lines, fmt.Sprintf("  ... 还有 %d 个错误", len(list)-i))
+					break
+				}
+				lines = append(lines, fmt.Sprintf("  %s:%d:%d: %s", file, e.Pos.Line, e.Pos.Column, e.Msg))
+			}
+		} else {
+			lines = append(lines, "  "+err.Error())
+		}
+		return fmt.Sprintf("\n[✗] 语法检查失败（文件已写入）:\n%s\n%s", strings.Join(lines, "\n"), rollbackHint)
+	}
+
+	formatted, err := format.Source(content)
+	if err != nil || bytes.Equal(formatted, content) {
+		return "\n[✓] 语法检查通过"
+	}
+	if !autoFormat {
+		return "\n[✓] 语法检查通过（格式不符合gofmt，可传 format=true 自动格式化）"
+	}
+	encoded, err := EncodeText(string(formatted), f)
+	if err != nil {
+		return fmt.Sprintf("\n[✓] 语法检查通过\n[!] gofmt结果无法按 %s 编码，未写入: %v", f.Encoding, err)
+	}
+	if err := writeFileContent(file, targetMachine, encoded, sm); err != nil {
+		return fmt.Sprintf("\n[✓] 语法检查通过\n[!] gofmt结果写入失败: %v", err)
+	}
+	return "\n[✓] 语法检查通过，已gofmt"
+}
+
+// checkJSONSource 校验JSON并定位出错的行列
+func checkJSONSource(content []byte) string {
+	var v interface{}
+	err := json.Unmarshal(content, &v)
+	if err == nil {
+		return "\n[✓] JSON校验通过"
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, col := offsetLineColumn(content, int(syntaxErr.Offset))
+		return fmt.Sprintf("\n[✗] JSON语法错误（文件已写入）: 第 %d 行第 %d 列: %v\n%s", line, col, syntaxErr, rollbackHint)
+	}
+	return fmt.Sprintf("\n[✗] JSON语法错误（文件已写入）: %v\n%s", err, rollbackHint)
+}
+
+// runFileChecker 运行配置的格式化和检查命令
+func runFileChecker(checker appconfig.FileChecker, file, targetMachine string, autoFormat bool, sm *state.Manager) string {
+	var notes []string
+
+	if autoFormat && checker.Format != "" {
+		argv := checkerArgv(checker.Format, file)
+		res, err := runArgv(sm, targetMachine, "", FileCheckTimeout, argv...)
+		switch {
+		case err != nil:
+			notes = append(notes, fmt.Sprintf("[!] 格式化命令无法运行（%s）: %v", argv[0], err))
+		case res.TimedOut:
+			notes = append(notes, fmt.Sprintf("[!] 格式化超时（%v）", FileCheckTimeout))
+		case res.ExitCode != 0:
+			notes = append(notes, fmt.Sprintf("[!] 格式化失败（退出码 %d）:\n%s", res.ExitCode, checkerOutput(res)))
+		default:
+			notes = append(notes, fmt.Sprintf("[✓] 已格式化（%s）", argv[0]))
+			refreshFileSnapshot(file, targetMachine, sm)
+		}
+	}
+
+	if checker.Check != "" {
+		argv := checkerArgv(checker.Check, file)
+		res, err := runArgv(sm, targetMachine, "", FileCheckTimeout, argv...)
+		switch {
+		case err != nil:
+			notes = append(notes, fmt.Sprintf("[!] 检查命令无法运行（%s）: %v", argv[0], err))
+		case res.TimedOut:
+			notes = append(notes, fmt.Sprintf("[!] 检查超时（%v）", FileCheckTimeout))
+		case res.ExitCode != 0:
+			notes = append(notes, fmt.Sprintf("[✗] 语法检查失败（%s，文件已写入）:\n%s\n%s", argv[0], checkerOutput(res), rollbackHint))
+		default:
+			notes = append(notes, fmt.Sprintf("[✓] 语法检查通过（%s）", argv[0]))
+		}
+	}
+
+	if len(notes) == 0 {
+		return ""
+	}
+	return "\n" + strings.Join(notes, "\n")
+}
+
+// checkerArgv 拆分命令行并替换 {file}，没有占位符时把文件路径追加在末尾
+func checkerArgv(cmdline, file string) []string {
+	fields := strings.Fields(cmdline)
+	replaced := false
+	for i, f := range fields {
+		if strings.Contains(f, "{file}") {
+			fields[i] = strings.ReplaceAll(f, "{file}", file)
+			replaced = true
+		}
+	}
+	if !replaced {
+		fields = append(fields, file)
+	}
+	return fields
+}
+
+// checkerOutput 检查命令的输出（优先stderr），限制行数
+func checkerOutput(res *state.AgentRunResult) string {
+	out := strings.TrimSpace(res.Stderr)
+	if out == "" {
+		out = strings.TrimSpace(res.Stdout)
+	}
+	if out == "" {
+		return "  （没有输出）"
+	}
+	return truncateOutputLines(out, MaxCheckOutputLines)
+}
+
+// offsetLineColumn 字节偏移对应的行列（从1开始）
+func offsetLineColumn(content []byte, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	line, col := 1, 1
+	for _, b := range content[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// ExecuteRollbackFile 用本轮的备份恢复文件（撤销尚未确认的修改）
+func ExecuteRollbackFile(args map[string]interface{}, bm *backup.Manager, sm *state.Manager) string {
+	file := args["file"].(string)
+	targetMachine, _ := args["_target_machine"].(string)
+	if targetMachine == "" {
+		targetMachine = "local"
+	}
+
+	backupPath := file
+	if targetMachine != "local" {
+		backupPath = file + "@" + targetMachine
+	}
+
+	b, ok := bm.FindBackup(backupPath)
+	if !ok {
+		return fmt.Sprintf("[✗] 没有 %s 的备份（只能回滚本轮尚未确认的修改）", backupPath)
+	}
+
+	if err := writeFileContent(file, targetMachine, b.OldContent, sm); err != nil {
+		return fmt.Sprintf("[✗] 恢复失败: %v", err)
+	}
+	bm.RemoveBackup(backupPath)
+
+	return fmt.Sprintf("[✓] 已回滚 %s 到本轮修改前的内容（撤销了 %d 次修改）", backupPath, b.EditCount)
+}
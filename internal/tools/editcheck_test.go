@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ai_assistant/internal/backup"
+	appconfig "ai_assistant/internal/config"
+	"ai_assistant/internal/process"
+	"ai_assistant/internal/state"
+
+	"github.com/sashabaranov/go-openai"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// useTempConfigDir 让 state.Manager 等把文件写到临时目录，而不是包目录
+func useTempConfigDir(t *testing.T) {
+	t.Helper()
+	orig := appconfig.ConfigDir
+	appconfig.ConfigDir = t.TempDir()
+	t.Cleanup(func() { appconfig.ConfigDir = orig })
+}
+
+func TestCheckGoSourceKeepsFileFormat(t *testing.T) {
+	const unformatted = "package p\n\n// 注释\nfunc F( ) int { return 1 }\n"
+	const formatted = "package p\n\n// 注释\nfunc F() int { return 1 }\n"
+	gbk := func(s string) []byte {
+		b, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+		return b
+	}
+	crlf := func(s string) string { return string(bytes.ReplaceAll([]byte(s), []byte("\n"), []byte("\r\n"))) }
+
+	tests := []struct {
+		name     string
+		original []byte
+		want     []byte
+	}{
+		{"utf8 lf", []byte(unformatted), []byte(formatted)},
+		{"crlf", []byte(crlf(unformatted)), []byte(crlf(formatted))},
+		{"bom crlf", append([]byte{0xEF, 0xBB, 0xBF}, crlf(unformatted)...), append([]byte{0xEF, 0xBB, 0xBF}, crlf(formatted)...)},
+		{"gbk", gbk(unformatted), gbk(formatted)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "p.go")
+			if err := os.WriteFile(file, tt.original, 0644); err != nil {
+				t.Fatal(err)
+			}
+			text, f := DecodeText(tt.original)
+
+			CheckEditedFile(file, "local", []byte(text), f, true, nil)
+
+			got, _ := os.ReadFile(file)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("file after gofmt:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackNeedsApproval(t *testing.T) {
+	useTempConfigDir(t)
+	e := NewExecutorSimplified(process.NewManager(), backup.NewManager(), state.NewManager(), nil, nil)
+
+	tests := []struct {
+		args string
+		want bool
+	}{
+		{`{"action":"rollback","file":"a.go"}`, true},
+		{`{"action":"edit","file":"a.go"}`, true},
+		{`{"action":"read","file":"a.go"}`, false},
+	}
+	for _, tt := range tests {
+		tc := openai.ToolCall{Function: openai.FunctionCall{Name: "file_operation", Arguments: tt.args}}
+		if got := e.NeedsImmediateApproval(tc); got != tt.want {
+			t.Errorf("NeedsImmediateApproval(%s) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxEditPreview 批量/正则替换时预览的最多处数
+const MaxEditPreview = 10
+
+// editMatch 一处匹配及其替换结果
+type editMatch struct {
+	start, end int
+	repl       string
+}
+
+// replaceMatches 字面量或正则替换：默认要求唯一匹配，replace_all 时替换全部，expected_count 校验匹配数
+// 返回新文本和结果说明（正则/全部替换时附带修改预览），失败时返回错误信息
+func replaceMatches(text, old, new string, args map[string]interface{}) (string, string, string) {
+	useRegex, _ := args["regex"].(bool)
+	replaceAll, _ := args["replace_all"].(bool)
+	expected := intArg(args, "expected_count", 0)
+
+	if old == "" {
+		return "", "", "[✗] old不能为空"
+	}
+
+	var matches []editMatch
+	if useRegex {
+		re, err := regexp.Compile(old)
+		if err != nil {
+			return "", "", fmt.Sprintf("[✗] 正则表达式无效: %v", err)
+		}
+		for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+			if m[0] == m[1] {
+				return "", "", "[✗] 正则匹配到了空字符串，请检查表达式（如 x* 这类可以不匹配任何字符的写法）"
+			}
+			matches = append(matches, editMatch{m[0], m[1], string(re.ExpandString(nil, new, text, m))})
+		}
+	} else {
+		for offset := 0; ; {
+			idx := strings.Index(text[offset:], old)
+			if idx < 0 {
+				break
+			}
+			start := offset + idx
+			matches = append(matches, editMatch{start, start + len(old), new})
+			offset = start + len(old)
+		}
+	}
+
+	count := len(matches)
+	if count == 0 {
+		return "", "", "[✗] 未找到要替换的内容"
+	}
+	if expected > 0 && count != expected {
+		return "", "", fmt.Sprintf("[✗] 找到%d处匹配（第 %s 行），与 expected_count=%d 不符，文件未改动",
+			count, strings.Join(matchStartLines(text, matches), "、"), expected)
+	}
+	if count > 1 && !replaceAll {
+		return "", "", fmt.Sprintf("[✗] 找到%d处匹配（第 %s 行），无法确定唯一位置\n"+
+			"提示: 扩大 old 片段使其唯一，或用 start_line/end_line 加 read 结果中的 hash 按行号编辑；要全部替换时传 replace_all=true",
+			count, strings.Join(matchStartLines(text, matches), "、"))
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(text[last:m.start])
+		sb.WriteString(m.repl)
+		last = m.end
+	}
+	sb.WriteString(text[last:])
+
+	// 普通的唯一替换不需要预览
+	detail := ""
+	if useRegex || replaceAll {
+		detail = fmt.Sprintf("\n[i] 共替换 %d 处:\n%s", count, editPreview(text, matches))
+	}
+	return sb.String(), detail, ""
+}
+
+// matchStartLines 每处匹配的起始行号
+func matchStartLines(text string, matches []editMatch) []string {
+	var result []string
+	for _, m := range matches {
+		result = append(result, fmt.Sprint(strings.Count(text[:m.start], "\n")+1))
+	}
+	return result
+}
+
+// editPreview 修改前后的行（同一行上的多处匹配合并显示）
+func editPreview(text string, matches []editMatch) string {
+	var sb strings.Builder
+	shown := 0
+	for i := 0; i < len(matches); {
+		if shown >= MaxEditPreview {
+			sb.WriteString(fmt.Sprintf("  ... 还有 %d 处\n", len(matches)-i))
+			break
+		}
+
+		lineStart := strings.LastIndex(text[:matches[i].start], "\n") + 1
+		lineEnd := lineEndIndex(text, matches[i].end)
+
+		// 合并落在同一段行上的后续匹配
+		var after strings.Builder
+		pos := lineStart
+		for ; i < len(matches) && matches[i].start < lineEnd; i++ {
+			after.WriteString(text[pos:matches[i].start])
+			after.WriteString(matches[i].repl)
+			pos = matches[i].end
+			lineEnd = lineEndIndex(text, pos)
+		}
+		after.WriteString(text[pos:lineEnd])
+
+		lineNo := strings.Count(text[:lineStart], "\n") + 1
+		for j, line := range strings.Split(text[lineStart:lineEnd], "\n") {
+			sb.WriteString(fmt.Sprintf("  %5d- %s\n", lineNo+j, line))
+		}
+		for _, line := range strings.Split(after.String(), "\n") {
+			sb.WriteString(fmt.Sprintf("  %5s+ %s\n", "", line))
+		}
+		shown++
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// lineEndIndex offset 所在行的行尾（不含换行符）
+func lineEndIndex(text string, offset int) int {
+	if idx := strings.Index(text[offset:], "\n"); idx >= 0 {
+		return offset + idx
+	}
+	return len(text)
+}
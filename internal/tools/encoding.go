@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 文件编码
+const (
+	EncodingUTF8    = "UTF-8"
+	EncodingUTF8BOM = "UTF-8 BOM"
+	EncodingUTF16LE = "UTF-16LE"
+	EncodingUTF16BE = "UTF-16BE"
+	EncodingGBK     = "GBK"     // 兼容GB2312
+	EncodingGB18030 = "GB18030" // GBK无法解码时再试
+	EncodingUnknown = "未知"      // 无法识别，按原始字节处理
+)
+
+// 换行风格
+const (
+	LineEndingLF    = "LF"
+	LineEndingCRLF  = "CRLF"
+	LineEndingMixed = "混合"
+)
+
+// TextFormat 文本文件的编码和换行信息，编辑后按原样写回
+type TextFormat struct {
+	Encoding        string
+	LineEnding      string
+	TrailingNewline bool
+}
+
+// Describe 非默认格式的说明（UTF-8 + LF 返回空串），如 "GB18030，CRLF换行"
+func (f TextFormat) Describe() string {
+	var parts []string
+	if f.Encoding != EncodingUTF8 {
+		parts = append(parts, f.Encoding+"编码")
+	}
+	if f.LineEnding != LineEndingLF {
+		parts = append(parts, f.LineEnding+"换行")
+	}
+	return strings.Join(parts, "，")
+}
+
+// DecodeText 识别编码并解码为UTF-8文本，同时记录换行风格
+// CRLF 文件返回的文本已统一为 \n；混合换行的文件保持原样，避免改乱
+func DecodeText(content []byte) (string, TextFormat) {
+	var f TextFormat
+	var text string
+
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		f.Encoding = EncodingUTF8BOM
+		text = string(content[3:])
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		f.Encoding = EncodingUTF16LE
+		text = decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), content)
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		f.Encoding = EncodingUTF16BE
+		text = decodeWith(unicode.UTF16(unicode.BigEndian, unicode.UseBOM), content)
+	case utf8.Valid(content):
+		f.Encoding = EncodingUTF8
+		text = string(content)
+	default:
+		// 国内服务器上常见的GBK配置；解码出替换字符说明不是这种编码
+		f.Encoding, text = EncodingUnknown, string(content)
+		for _, cand := range []struct {
+			name string
+			enc  encoding.Encoding
+		}{
+			{EncodingGBK, simplifiedchinese.GBK},
+			{EncodingGB18030, simplifiedchinese.GB18030},
+		} {
+			if decoded, err := cand.enc.NewDecoder().Bytes(content); err == nil && !bytes.ContainsRune(decoded, utf8.RuneError) {
+				f.Encoding, text = cand.name, string(decoded)
+				break
+			}
+		}
+	}
+
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n")
+	switch {
+	case crlf > 0 && crlf == lf:
+		f.LineEnding = LineEndingCRLF
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	case crlf > 0:
+		f.LineEnding = LineEndingMixed
+	default:
+		f.LineEnding = LineEndingLF
+	}
+	f.TrailingNewline = strings.HasSuffix(text, "\n")
+	return text, f
+}
+
+// EncodeText 按原文件的格式写回：恢复换行风格、末尾换行和编码
+func EncodeText(text string, f TextFormat) ([]byte, error) {
+	// 保持原来的末尾换行状态
+	hasTrailing := strings.HasSuffix(text, "\n")
+	if f.TrailingNewline && !hasTrailing && text != "" {
+		text += "\n"
+	} else if !f.TrailingNewline && hasTrailing {
+		text = strings.TrimSuffix(text, "\n")
+		if f.LineEnding == LineEndingMixed {
+			text = strings.TrimSuffix(text, "\r")
+		}
+	}
+
+	if f.LineEnding == LineEndingCRLF {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+
+	switch f.Encoding {
+	case EncodingUTF8BOM:
+		return append([]byte{0xEF, 0xBB, 0xBF}, text...), nil
+	case EncodingUTF16LE:
+		return encodeWith(unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), text)
+	case EncodingUTF16BE:
+		return encodeWith(unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), text)
+	case EncodingGBK:
+		return encodeWith(simplifiedchinese.GBK, text)
+	case EncodingGB18030:
+		return encodeWith(simplifiedchinese.GB18030, text)
+	default:
+		return []byte(text), nil
+	}
+}
+
+// NormalizeNewlines 把模型给出的片段统一为 \n，和 DecodeText 的结果对齐
+func NormalizeNewlines(s string, f TextFormat) string {
+	if f.LineEnding == LineEndingCRLF {
+		return strings.ReplaceAll(s, "\r\n", "\n")
+	}
+	return s
+}
+
+func decodeWith(enc encoding.Encoding, content []byte) string {
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return string(content)
+	}
+	return string(decoded)
+}
+
+func encodeWith(enc encoding.Encoding, text string) ([]byte, error) {
+	encoded, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("内容无法用原编码保存: %v", err)
+	}
+	return encoded, nil
+}
+
+// IsBinaryFile 判断是否为二进制文件：带UTF-16 BOM的按文本处理，否则看NUL字节和控制字符比例
+func IsBinaryFile(content []byte) bool {
+	if bytes.HasPrefix(content, []byte{0xFF, 0xFE}) || bytes.HasPrefix(content, []byte{0xFE, 0xFF}) {
+		return false
+	}
+	if isBinaryContent(content) {
+		return true
+	}
+
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if len(head) == 0 {
+		return false
+	}
+	control := 0
+	for _, b := range head {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' && b != 0x1b {
+			control++
+		}
+	}
+	return control*10 > len(head)
+}
+
+// BinarySummary 二进制文件的摘要：类型、大小和开头的十六进制
+func BinarySummary(file string, content []byte) string {
+	head := content
+	if len(head) > 32 {
+		head = head[:32]
+	}
+	var hex strings.Builder
+	for i, b := range head {
+		if i > 0 {
+			hex.WriteByte(' ')
+		}
+		fmt.Fprintf(&hex, "%02x", b)
+	}
+
+	return fmt.Sprintf("[文件] %s 是二进制文件，不显示内容\n"+
+		"类型: %s\n"+
+		"大小: %s\n"+
+		"开头: %s\n"+
+		"提示: 需要查看时用 run_command 执行 file / xxd / strings 等命令",
+		file, http.DetectContentType(content), formatFileSize(int64(len(content))), hex.String())
+}
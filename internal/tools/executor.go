@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"ai_assistant/internal/backup"
+	"ai_assistant/internal/memory"
 	"ai_assistant/internal/process"
+	"ai_assistant/internal/session"
 	"ai_assistant/internal/state"
 
 	"github.com/sashabaranov/go-openai"
@@ -17,14 +19,21 @@ type ExecutorSimplified struct {
 	ProcessManager *process.Manager
 	BackupManager  *backup.Manager
 	StateManager   *state.Manager
+	MemoryManager  *memory.Manager
+	SessionManager *session.Manager
+	Client         *openai.Client // delegate 子任务调用模型使用
+
+	AutoDiagnostics bool // 编辑Go文件后自动附带诊断（由会话设置同步）
 }
 
 // NewExecutorSimplified 创建简化版执行器
-func NewExecutorSimplified(pm *process.Manager, bm *backup.Manager, sm *state.Manager) *ExecutorSimplified {
+func NewExecutorSimplified(pm *process.Manager, bm *backup.Manager, sm *state.Manager, mm *memory.Manager, sessm *session.Manager) *ExecutorSimplified {
 	return &ExecutorSimplified{
 		ProcessManager: pm,
 		BackupManager:  bm,
 		StateManager:   sm,
+		MemoryManager:  mm,
+		SessionManager: sessm,
 	}
 }
 
@@ -40,11 +49,37 @@ func (e *ExecutorSimplified) Execute(toolCall openai.ToolCall) string {
 		return ExecuteRunCommand(args, e.ProcessManager, e.StateManager)
 	case "web_search":
 		return ExecuteWebSearch(args)
+	case "web_fetch":
+		return ExecuteWebFetch(args)
 	case "sync":
 		return ExecuteSync(args, e.StateManager)
 	case "terminal_manage":
 		return ExecuteTerminalManage(args, e.StateManager)
+	case "process":
+		return ExecuteProcess(args, e.ProcessManager, e.StateManager)
+	case "git":
+		return ExecuteGit(args, e.StateManager)
+	case "code_intel":
+		return ExecuteCodeIntel(args, e.StateManager)
+	case "test":
+		return ExecuteTest(args, e.StateManager)
+	case "diagnostics":
+		return ExecuteDiagnostics(args, e.StateManager)
+	case "memory":
+		return ExecuteMemory(args, e.MemoryManager, e.StateManager)
+	case "plan":
+		return ExecutePlan(args, e.SessionManager)
+	case "delegate":
+		return e.executeDelegate(args)
+	case "find":
+		return ExecuteFind(args, e.StateManager)
 	default:
+		if tool := findCustomTool(toolCall.Function.Name); tool != nil {
+			return ExecuteCustomTool(tool, args, e.StateManager)
+		}
+		if ref, ok := mcpManager.Lookup(toolCall.Function.Name); ok {
+			return ExecuteMCPTool(ref, args)
+		}
 		return fmt.Sprintf("[✗] 未知工具: %s", toolCall.Function.Name)
 	}
 }
@@ -73,22 +108,57 @@ func (e *ExecutorSimplified) executeFileOperation(toolCallID string, args map[st
 	// 将targetMachine注入到args中供后续函数使用
 	args["_target_machine"] = targetMachine
 
+	// 相对路径按目标机器的当前目录解析（rename 只处理本地文件）
+	if action == "rename" {
+		resolvePathArgs(args, "local", e.StateManager)
+	} else {
+		resolvePathArgs(args, targetMachine, e.StateManager)
+	}
+
+	// 除 search/list 和批量 read 外的操作都需要 file 参数
+	if action != "search" && action != "list" && !(action == "read" && isBatchRead(args)) {
+		if file, ok := args["file"].(string); !ok || file == "" {
+			return fmt.Sprintf("[✗] %s操作缺少file参数", action)
+		}
+	}
+
 	switch action {
 	case "read":
+		if isBatchRead(args) {
+			return ExecuteBatchRead(args, e.StateManager)
+		}
 		return ExecuteReadFile(args, e.StateManager)
 	case "edit":
-		return ExecuteEditFile(toolCallID, args, e.BackupManager, e.StateManager)
+		return e.withDiagnostics(args, targetMachine, ExecuteEditFile(toolCallID, args, e.BackupManager, e.StateManager))
 	case "rename":
-		return ExecuteRenameSymbol(toolCallID, args, e.BackupManager)
+		// rename 只处理本地文件
+		return e.withDiagnostics(args, "local", ExecuteRenameSymbol(toolCallID, args, e.BackupManager))
 	case "delete":
 		return ExecuteDeleteFile(toolCallID, args, e.BackupManager)
 	case "search":
 		return ExecuteSearchCode(args, e.StateManager)
+	case "list":
+		return ExecuteListDirectory(args, e.StateManager)
+	case "outline":
+		return ExecuteFileOutline(args, e.StateManager)
+	case "rollback":
+		return ExecuteRollbackFile(args, e.BackupManager, e.StateManager)
+	case "replace_symbol", "insert_after_symbol", "delete_symbol":
+		return e.withDiagnostics(args, targetMachine, ExecuteGoSymbolEdit(toolCallID, args, e.BackupManager, e.StateManager))
 	default:
 		return fmt.Sprintf("[✗] 未知文件操作: %s", action)
 	}
 }
 
+// withDiagnostics 会话开启自动诊断时，在成功的编辑结果后附带所在包的诊断
+func (e *ExecutorSimplified) withDiagnostics(args map[string]interface{}, targetMachine, result string) string {
+	if !e.AutoDiagnostics || !strings.HasPrefix(result, "[✓]") {
+		return result
+	}
+	file, _ := args["file"].(string)
+	return result + DiagnosticsAfterEdit(file, targetMachine, e.StateManager)
+}
+
 // NeedsImmediateApproval 是否需要立即批准（简化版）
 func (e *ExecutorSimplified) NeedsImmediateApproval(toolCall openai.ToolCall) bool {
 	switch toolCall.Function.Name {
@@ -101,9 +171,49 @@ func (e *ExecutorSimplified) NeedsImmediateApproval(toolCall openai.ToolCall) bo
 		var args map[string]interface{}
 		json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
 		action, _ := args["action"].(string)
-		// edit, rename, delete 需要批准
-		return action == "edit" || action == "rename" || action == "delete"
+		// edit, rename, delete、按符号修改和 rollback（覆盖文件）需要批准
+		switch action {
+		case "edit", "rename", "delete", "replace_symbol", "insert_after_symbol", "delete_symbol", "rollback":
+			return true
+		}
+		return false
+	case "process":
+		var args map[string]interface{}
+		json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+		action, _ := args["action"].(string)
+		command, _ := args["command"].(string)
+		// 启动非只读命令、向进程发送输入需要批准
+		return (action == "start" && !isReadOnlyCommand(command)) || action == "send_input"
+	case "test":
+		// 测试会执行项目代码，和 run_command 中的 go test 一样需要批准
+		return true
+	case "git":
+		var args map[string]interface{}
+		json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+		action, _ := args["action"].(string)
+		switch action {
+		case "commit", "checkout", "reset":
+			return true
+		case "stash":
+			// 只有 list 是只读的
+			stashAction, _ := args["stash_action"].(string)
+			return stashAction != "" && stashAction != "list"
+		case "branch":
+			// 指定name会创建分支
+			name, _ := args["name"].(string)
+			return name != ""
+		default:
+			return false
+		}
 	default:
+		// 自定义工具按 tools.json 中的 approval：ask 需要批准，deny 执行时直接拒绝
+		if tool := findCustomTool(toolCall.Function.Name); tool != nil {
+			return tool.Approval == "ask"
+		}
+		// MCP 工具除了配置在 auto_approve 中的都需要批准
+		if ref, ok := mcpManager.Lookup(toolCall.Function.Name); ok {
+			return !ref.AutoApprove
+		}
 		return false
 	}
 }
@@ -155,3 +265,11 @@ func isReadOnlyCommand(command string) bool {
 
 	return false
 }
+
+// intArg 读取整数参数（JSON数字解码为float64）
+func intArg(args map[string]interface{}, key string, def int) int {
+	if v, ok := args[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
@@ -0,0 +1,651 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// 网页抓取限制
+const (
+	FetchTimeout         = 20 * time.Second
+	MaxFetchBytes        = 5 * 1024 * 1024 // 最多下载5MB
+	MaxFetchRedirects    = 5
+	DefaultFetchMaxChars = 8000 // 每页默认返回的字符数
+	MaxFetchMaxChars     = 30000
+	FetchCacheTTL        = 15 * time.Minute
+)
+
+// fetchedPage 已抓取并转换的页面
+type fetchedPage struct {
+	URL       string // 最终地址（跟随重定向后）
+	Title     string
+	Content   string // 转换后的Markdown/文本
+	Type      string
+	Truncated bool // 原始内容超过大小限制被截断
+	FetchedAt time.Time
+}
+
+// 会话内的抓取缓存（切换会话时清空）
+var (
+	fetchCache      = make(map[string]*fetchedPage)
+	fetchCacheMutex sync.Mutex
+)
+
+// ResetFetchCache 清空网页缓存（切换/新建/清空会话时调用）
+func ResetFetchCache() {
+	fetchCacheMutex.Lock()
+	defer fetchCacheMutex.Unlock()
+	fetchCache = make(map[string]*fetchedPage)
+}
+
+// ExecuteWebFetch 抓取网页并转换为可读的Markdown（支持分页）
+func ExecuteWebFetch(args map[string]interface{}) string {
+	rawURL, _ := args["url"].(string)
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "[✗] 抓取失败: 缺少url参数"
+	}
+
+	offset := intArg(args, "offset", 0)
+	maxChars := intArg(args, "max_chars", DefaultFetchMaxChars)
+	if maxChars <= 0 || maxChars > MaxFetchMaxChars {
+		maxChars = MaxFetchMaxChars
+	}
+	refresh, _ := args["refresh"].(bool)
+
+	page, cached, err := getFetchedPage(rawURL, refresh)
+	if err != nil {
+		return fmt.Sprintf("[✗] 抓取失败: %v", err)
+	}
+
+	return formatFetchedPage(page, cached, offset, maxChars)
+}
+
+// getFetchedPage 优先从缓存读取
+func getFetchedPage(rawURL string, refresh bool) (*fetchedPage, bool, error) {
+	fetchCacheMutex.Lock()
+	page, ok := fetchCache[rawURL]
+	fetchCacheMutex.Unlock()
+	if ok && !refresh && time.Since(page.FetchedAt) < FetchCacheTTL {
+		return page, true, nil
+	}
+
+	page, err := FetchURL(rawURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fetchCacheMutex.Lock()
+	fetchCache[rawURL] = page
+	fetchCacheMutex.Unlock()
+	return page, false, nil
+}
+
+// FetchURL 下载网页（限制大小、超时、重定向次数和内容类型）并转换为文本
+func FetchURL(rawURL string) (*fetchedPage, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的URL（只支持http/https）: %s", rawURL)
+	}
+
+	client := &http.Client{
+		Timeout:   FetchTimeout,
+		Transport: newFetchTransport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= MaxFetchRedirects {
+				return fmt.Errorf("重定向超过 %d 次", MaxFetchRedirects)
+			}
+			return nil
+		},
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; JARVIS/1.0)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain,application/json;q=0.9,*/*;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) && urlErr.Timeout() {
+			return nil, fmt.Errorf("请求超时（%v）", FetchTimeout)
+		}
+		var blocked *blockedAddrError
+		if errors.As(err, &blocked) {
+			return nil, blocked
+		}
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("HTTP %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = "text/html"
+	}
+	if !isReadableContentType(mediaType) {
+		return nil, fmt.Errorf("不支持的内容类型: %s（只能读取网页和文本）", mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxFetchBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	truncated := len(body) > MaxFetchBytes
+	if truncated {
+		body = body[:MaxFetchBytes]
+	}
+
+	page := &fetchedPage{
+		URL:       resp.Request.URL.String(),
+		Type:      mediaType,
+		Truncated: truncated,
+		FetchedAt: time.Now(),
+	}
+
+	isHTML := mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	text := decodeBody(body, params["charset"], isHTML)
+	if isHTML {
+		page.Title, page.Content = HTMLToMarkdown(text, resp.Request.URL)
+	} else {
+		page.Content = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	return page, nil
+}
+
+var htmlMetaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([-\w.:]+)`)
+
+// decodeBody 按 Content-Type 的 charset（HTML 没有时看 <meta charset>）转成UTF-8
+// 识别不了的编码原样返回
+func decodeBody(body []byte, charset string, isHTML bool) string {
+	if charset == "" && isHTML {
+		head := body
+		if len(head) > 4096 {
+			head = head[:4096]
+		}
+		if m := htmlMetaCharsetRe.FindSubmatch(head); m != nil {
+			charset = string(m[1])
+		}
+	}
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == "utf-8" || charset == "utf8" {
+		return string(body)
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(body)
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return string(body)
+	}
+	return string(decoded)
+}
+
+// blockedAddrError 目标是本机或内网地址
+type blockedAddrError struct {
+	host string
+	ip   net.IP
+}
+
+func (e *blockedAddrError) Error() string {
+	return fmt.Sprintf("不允许访问本机或内网地址: %s (%s)", e.host, e.ip)
+}
+
+// fetchIPAllowed 是否允许连接该地址：拒绝回环、链路本地（169.254.x，含云主机元数据服务）、私有网段和未指定地址
+var fetchIPAllowed = func(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified())
+}
+
+// newFetchTransport 连接前检查解析出的IP（重定向和DNS重绑定同样经过这里）
+// 使用代理时连接的是代理，目标地址改为在选择代理时检查
+func newFetchTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+
+	var proxyHosts sync.Map
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxy, err := http.ProxyFromEnvironment(req)
+		if err != nil || proxy == nil {
+			return proxy, err
+		}
+		if _, err := resolveAllowedIPs(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		proxyHosts.Store(proxy.Hostname(), true)
+		return proxy, nil
+	}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := proxyHosts.Load(host); ok {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		ips, err := resolveAllowedIPs(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		// 直接连接检查过的IP，不再重新解析
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+	return transport
+}
+
+// resolveAllowedIPs 解析主机名，任一地址不允许访问时报错
+func resolveAllowedIPs(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		if !fetchIPAllowed(ip) {
+			return nil, &blockedAddrError{host: host, ip: ip}
+		}
+	}
+	return ips, nil
+}
+
+// isReadableContentType 是否为可以转换成文本的内容类型
+func isReadableContentType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/xhtml+xml", "application/json", "application/xml", "application/javascript",
+		"application/x-yaml", "application/yaml", "application/toml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// formatFetchedPage 按字符偏移分页输出
+func formatFetchedPage(page *fetchedPage, cached bool, offset, maxChars int) string {
+	content := []rune(page.Content)
+	total := len(content)
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= total && total > 0 {
+		return fmt.Sprintf("[✗] offset 超出范围: %d（共 %d 字符）", offset, total)
+	}
+
+	end := offset + maxChars
+	if end > total {
+		end = total
+	} else {
+		// 尽量在换行处分页
+		for i := end; i > offset+maxChars/2; i-- {
+			if content[i-1] == '\n' {
+				end = i
+				break
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[网页] %s\n", page.URL))
+	if page.Title != "" {
+		sb.WriteString(fmt.Sprintf("标题: %s\n", page.Title))
+	}
+	source := "实时抓取"
+	if cached {
+		source = "会话缓存，refresh:true 可重新抓取"
+	}
+	sb.WriteString(fmt.Sprintf("类型: %s | 共 %d 字符 | 第 %d-%d 字符 | %s\n", page.Type, total, offset+1, end, source))
+	if page.Truncated {
+		sb.WriteString(fmt.Sprintf("[!] 页面超过 %d MB，只处理了前面部分\n", MaxFetchBytes/1024/1024))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(string(content[offset:end]))
+
+	if end < total {
+		sb.WriteString(fmt.Sprintf("\n\n提示: 还有 %d 字符，使用 offset=%d 继续阅读", total-end, end))
+	}
+	return sb.String()
+}
+
+// HTML转换时整块丢弃的标签
+var htmlSkipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "svg": true, "template": true,
+	"iframe": true, "head": true, "nav": true, "footer": true, "form": true, "button": true,
+}
+
+// 块级标签（前后换行）
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "header": true,
+	"aside": true, "ul": true, "ol": true, "table": true, "blockquote": true,
+	"dl": true, "dt": true, "dd": true, "figure": true, "figcaption": true, "hr": true,
+}
+
+var (
+	htmlAttrRe      = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	htmlSpaceRe     = regexp.MustCompile(`[ \t\r\n\f]+`)
+	htmlBlankLineRe = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+)
+
+// htmlTag 解析后的标签
+type htmlTag struct {
+	name    string
+	closing bool
+	attrs   map[string]string
+}
+
+// HTMLToMarkdown 把HTML转换为Markdown（标题、段落、列表、链接、代码块、表格行）
+// 存在 <main> 或 <article> 时只转换其中的正文
+func HTMLToMarkdown(doc string, base *url.URL) (string, string) {
+	title := ""
+	if m := regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`).FindStringSubmatch(doc); m != nil {
+		title = strings.TrimSpace(htmlSpaceRe.ReplaceAllString(html.UnescapeString(m[1]), " "))
+	}
+
+	for _, tag := range []string{"main", "article"} {
+		re := regexp.MustCompile(`(?is)<` + tag + `[\s>].*</` + tag + `>`)
+		if body := re.FindString(doc); body != "" {
+			doc = body
+			break
+		}
+	}
+
+	var out strings.Builder
+	var linkHref string
+	var linkText strings.Builder
+	inLink := false
+	preDepth := 0
+	skipDepth := 0
+	skipTag := ""
+	listDepth := 0
+	var orderedCounters []int
+
+	newline := func(n int) {
+		s := out.String()
+		trailing := len(s) - len(strings.TrimRight(s, "\n"))
+		for i := trailing; i < n; i++ {
+			out.WriteString("\n")
+		}
+	}
+	write := func(text string) {
+		if inLink {
+			linkText.WriteString(text)
+		} else {
+			out.WriteString(text)
+		}
+	}
+
+	pos := 0
+	for pos < len(doc) {
+		lt := strings.IndexByte(doc[pos:], '<')
+		if lt < 0 {
+			lt = len(doc) - pos
+		}
+
+		// 文本节点
+		if lt > 0 && skipDepth == 0 {
+			text := html.UnescapeString(doc[pos : pos+lt])
+			if preDepth == 0 {
+				text = htmlSpaceRe.ReplaceAllString(text, " ")
+				s := out.String()
+				if strings.HasSuffix(s, "\n") || s == "" {
+					text = strings.TrimLeft(text, " ")
+				}
+			}
+			write(text)
+		}
+		pos += lt
+		if pos >= len(doc) {
+			break
+		}
+
+		// 注释和声明
+		if strings.HasPrefix(doc[pos:], "<!--") {
+			end := strings.Index(doc[pos:], "-->")
+			if end < 0 {
+				break
+			}
+			pos += end + 3
+			continue
+		}
+		if strings.HasPrefix(doc[pos:], "<!") || strings.HasPrefix(doc[pos:], "<?") {
+			end := strings.IndexByte(doc[pos:], '>')
+			if end < 0 {
+				break
+			}
+			pos += end + 1
+			continue
+		}
+
+		end := findTagEnd(doc, pos)
+		if end < 0 {
+			write(html.UnescapeString(doc[pos:]))
+			break
+		}
+		tag, ok := parseHTMLTag(doc[pos+1 : end])
+		pos = end + 1
+		if !ok {
+			continue
+		}
+
+		// 丢弃的整块内容（script/style等）
+		if skipDepth > 0 {
+			if tag.name == skipTag {
+				if tag.closing {
+					skipDepth--
+				} else {
+					skipDepth++
+				}
+			}
+			continue
+		}
+		if htmlSkipTags[tag.name] && !tag.closing {
+			skipDepth = 1
+			skipTag = tag.name
+			continue
+		}
+
+		switch tag.name {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			newline(2)
+			if !tag.closing {
+				out.WriteString(strings.Repeat("#", int(tag.name[1]-'0')) + " ")
+			}
+		case "br":
+			write("\n")
+		case "hr":
+			newline(2)
+			out.WriteString("---")
+			newline(2)
+		case "li":
+			if !tag.closing {
+				newline(1)
+				indent := strings.Repeat("  ", max(listDepth-1, 0))
+				if n := len(orderedCounters); n > 0 && orderedCounters[n-1] > 0 {
+					out.WriteString(fmt.Sprintf("%s%d. ", indent, orderedCounters[n-1]))
+					orderedCounters[n-1]++
+				} else {
+					out.WriteString(indent + "- ")
+				}
+			}
+		case "ul", "ol":
+			newline(1)
+			if tag.closing {
+				listDepth = max(listDepth-1, 0)
+				if len(orderedCounters) > 0 {
+					orderedCounters = orderedCounters[:len(orderedCounters)-1]
+				}
+				if listDepth == 0 {
+					newline(2)
+				}
+			} else {
+				listDepth++
+				if tag.name == "ol" {
+					orderedCounters = append(orderedCounters, 1)
+				} else {
+					orderedCounters = append(orderedCounters, 0)
+				}
+			}
+		case "pre":
+			if tag.closing {
+				newline(1)
+				preDepth = max(preDepth-1, 0)
+				out.WriteString("```")
+				newline(2)
+			} else {
+				newline(2)
+				preDepth++
+				out.WriteString("```\n")
+			}
+		case "code":
+			if preDepth == 0 {
+				write("`")
+			}
+		case "strong", "b":
+			write("**")
+		case "em", "i":
+			write("*")
+		case "a":
+			if tag.closing {
+				if inLink {
+					inLink = false
+					text := strings.TrimSpace(linkText.String())
+					switch {
+					case text == "":
+					case linkHref == "" || strings.HasPrefix(linkHref, "#") || strings.HasPrefix(linkHref, "javascript:"):
+						out.WriteString(text)
+					default:
+						out.WriteString(fmt.Sprintf("[%s](%s)", text, linkHref))
+					}
+				}
+			} else {
+				inLink = true
+				linkText.Reset()
+				linkHref = resolveHref(base, tag.attrs["href"])
+			}
+		case "img":
+			if alt := strings.TrimSpace(tag.attrs["alt"]); alt != "" {
+				write(fmt.Sprintf("[图片: %s]", alt))
+			}
+		case "tr":
+			newline(1)
+			if !tag.closing {
+				out.WriteString("|")
+			}
+		case "td", "th":
+			if tag.closing {
+				write(" |")
+			} else {
+				write(" ")
+			}
+		default:
+			if htmlBlockTags[tag.name] {
+				newline(2)
+			}
+		}
+	}
+
+	content := out.String()
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	content = strings.Join(lines, "\n")
+	content = htmlBlankLineRe.ReplaceAllString(content, "\n\n")
+	return title, strings.TrimSpace(content)
+}
+
+// findTagEnd 找到标签结束的 >（跳过引号中的内容）
+func findTagEnd(doc string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(doc); i++ {
+		c := doc[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseHTMLTag 解析标签名和属性
+func parseHTMLTag(raw string) (htmlTag, bool) {
+	raw = strings.TrimSpace(raw)
+	tag := htmlTag{}
+	if strings.HasPrefix(raw, "/") {
+		tag.closing = true
+		raw = strings.TrimSpace(raw[1:])
+	}
+	raw = strings.TrimSuffix(raw, "/")
+
+	nameEnd := strings.IndexAny(raw, " \t\r\n")
+	if nameEnd < 0 {
+		nameEnd = len(raw)
+	}
+	tag.name = strings.ToLower(raw[:nameEnd])
+	if tag.name == "" {
+		return tag, false
+	}
+
+	if !tag.closing && nameEnd < len(raw) {
+		tag.attrs = make(map[string]string)
+		for _, m := range htmlAttrRe.FindAllStringSubmatch(raw[nameEnd:], -1) {
+			tag.attrs[strings.ToLower(m[1])] = html.UnescapeString(strings.Trim(m[2], `"'`))
+		}
+	}
+	return tag, true
+}
+
+// resolveHref 把相对链接转换为绝对地址
+func resolveHref(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || base == nil || strings.HasPrefix(href, "#") {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// allowLoopbackFetch 测试服务器监听在 127.0.0.1，测试期间放开回环地址
+func allowLoopbackFetch(t *testing.T) {
+	t.Helper()
+	orig := fetchIPAllowed
+	fetchIPAllowed = func(ip net.IP) bool { return ip.IsLoopback() || orig(ip) }
+	t.Cleanup(func() { fetchIPAllowed = orig })
+}
+
+func TestFetchURL(t *testing.T) {
+	allowLoopbackFetch(t)
+
+	gbkTitle, _ := simplifiedchinese.GBK.NewEncoder().String("中文标题")
+	gbkBody, _ := simplifiedchinese.GBK.NewEncoder().String("<p>你好，世界</p>")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>Test Page</title><script>alert(1)</script></head>
+<body><nav>菜单</nav><main>
+<h1>Hello</h1>
+<p>Some <b>bold</b> text and a <a href="/docs">link</a>.</p>
+<ul><li>one</li><li>two</li></ul>
+<pre><code>go test ./...</code></pre>
+</main></body></html>`)
+	})
+	mux.HandleFunc("/loop/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/loop/"), "%d", &n)
+		http.Redirect(w, r, fmt.Sprintf("/loop/%d", n+1), http.StatusFound)
+	})
+	mux.HandleFunc("/redirect-once", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("a", MaxFetchBytes+1024)))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	})
+	mux.HandleFunc("/gbk-header", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=GBK")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>%s</body></html>", gbkTitle, gbkBody)
+	})
+	mux.HandleFunc("/gbk-meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><meta charset="gbk"><title>%s</title></head><body>%s</body></html>`, gbkTitle, gbkBody)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		wantErr   string
+		wantTitle string
+		want      []string
+		notWant   []string
+		truncated bool
+	}{
+		{
+			name:      "html to markdown",
+			path:      "/page",
+			wantTitle: "Test Page",
+			want:      []string{"# Hello", "**bold**", "[link](" + srv.URL + "/docs)", "- one", "- two", "```\ngo test ./...\n```"},
+			notWant:   []string{"alert(1)", "菜单"},
+		},
+		{name: "follows redirect", path: "/redirect-once", wantTitle: "Test Page", want: []string{"# Hello"}},
+		{name: "redirect cap", path: "/loop/0", wantErr: fmt.Sprintf("重定向超过 %d 次", MaxFetchRedirects)},
+		{name: "oversize body", path: "/big", truncated: true},
+		{name: "rejected content type", path: "/image", wantErr: "不支持的内容类型: image/png"},
+		{name: "gbk charset header", path: "/gbk-header", wantTitle: "中文标题", want: []string{"你好，世界"}},
+		{name: "gbk meta charset", path: "/gbk-meta", wantTitle: "中文标题", want: []string{"你好，世界"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := FetchURL(srv.URL + tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchURL: %v", err)
+			}
+			if page.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", page.Title, tt.wantTitle)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(page.Content, s) {
+					t.Errorf("content missing %q:\n%s", s, page.Content)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(page.Content, s) {
+					t.Errorf("content should not contain %q:\n%s", s, page.Content)
+				}
+			}
+			if page.Truncated != tt.truncated {
+				t.Errorf("truncated = %v, want %v", page.Truncated, tt.truncated)
+			}
+			if tt.truncated && len(page.Content) != MaxFetchBytes {
+				t.Errorf("content length = %d, want %d", len(page.Content), MaxFetchBytes)
+			}
+		})
+	}
+}
+
+func TestFetchURLBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+
+	tests := []string{
+		srv.URL,
+		"http://localhost:" + port,
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/",
+		"http://192.168.1.1/",
+		"http://[::1]:" + port,
+	}
+	for _, rawURL := range tests {
+		t.Run(rawURL, func(t *testing.T) {
+			_, err := FetchURL(rawURL)
+			var blocked *blockedAddrError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("err = %v, want blockedAddrError", err)
+			}
+		})
+	}
+}
+
+func TestFetchURLBlocksRedirectToPrivate(t *testing.T) {
+	allowLoopbackFetch(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	_, err := FetchURL(srv.URL)
+	var blocked *blockedAddrError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("err = %v, want blockedAddrError", err)
+	}
+}
+
+func TestHTMLToMarkdownResolvesRelativeLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/a/b.html")
+	title, md := HTMLToMarkdown(`<title> T </title><p>see <a href="c.html">c</a> and <a href="#x">x</a></p>`, base)
+	if title != "T" {
+		t.Errorf("title = %q", title)
+	}
+	if !strings.Contains(md, "[c](https://example.com/a/c.html)") {
+		t.Errorf("relative link not resolved:\n%s", md)
+	}
+}
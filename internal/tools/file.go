@@ -2,7 +2,9 @@ package tools
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -32,42 +34,51 @@ func ExecuteReadFile(args map[string]interface{}, sm *state.Manager) string {
 		targetMachine = "local"
 	}
 
-	// 远程机器：通过寄生虫读取
-	if targetMachine != "local" {
-		// 使用base64编码传输，避免特殊字符问题
-		cmd := fmt.Sprintf("cat '%s' 2>/dev/null | base64 -w 0 || base64 < '%s'", file, file)
-		output, err := sm.ExecuteOnAgent(targetMachine, cmd)
-		if err != nil {
-			return fmt.Sprintf("[✗] 读取失败: %v", err)
-		}
+	content, err := readFileContent(file, targetMachine, sm)
+	if err != nil {
+		return fmt.Sprintf("[✗] 读取失败: %v", err)
+	}
+	result := processFileContent(file, content, args)
+	if strings.HasPrefix(result, "[✗]") {
+		return result
+	}
+
+	// 记录读取时的内容，编辑前据此判断文件是否被外部修改
+	prev := recordFileSnapshot(file, targetMachine, content)
+	return changedSinceReadNote(prev, content) + result
+}
 
-		// 清理所有空白字符
-		output = strings.ReplaceAll(output, "\n", "")
-		output = strings.ReplaceAll(output, "\r", "")
-		output = strings.ReplaceAll(output, " ", "")
-		output = strings.TrimSpace(output)
+// readFileContent 读取本地或寄生虫上的文件内容
+func readFileContent(file, targetMachine string, sm *state.Manager) ([]byte, error) {
+	if targetMachine == "local" {
+		return os.ReadFile(file)
+	}
 
-		// 解码base64
-		decoded, err := base64.StdEncoding.DecodeString(output)
-		if err != nil {
-			// 如果解码失败，尝试直接读取
-			directCmd := fmt.Sprintf("cat '%s' 2>/dev/null", file)
-			directOutput, err2 := sm.ExecuteOnAgent(targetMachine, directCmd)
-			if err2 != nil {
-				return fmt.Sprintf("[✗] 读取失败: base64解码错误(%v), 直接读取也失败(%v)", err, err2)
-			}
-			content := []byte(directOutput)
-			return processFileContent(file, content, args)
-		}
-		return processFileContent(file, decoded, args)
+	// 远程机器：使用base64编码传输，避免特殊字符问题
+	cmd := fmt.Sprintf("cat '%s' 2>/dev/null | base64 -w 0 || base64 < '%s'", file, file)
+	output, err := sm.ExecuteOnAgent(targetMachine, cmd)
+	if err != nil {
+		return nil, err
 	}
 
-	// 本地机器：直接读取
-	content, err := os.ReadFile(file)
+	// 清理所有空白字符
+	output = strings.ReplaceAll(output, "\n", "")
+	output = strings.ReplaceAll(output, "\r", "")
+	output = strings.ReplaceAll(output, " ", "")
+	output = strings.TrimSpace(output)
+
+	// 解码base64
+	decoded, err := base64.StdEncoding.DecodeString(output)
 	if err != nil {
-		return fmt.Sprintf("[✗] 读取失败: %v", err)
+		// 如果解码失败，尝试直接读取
+		directCmd := fmt.Sprintf("cat '%s' 2>/dev/null", file)
+		directOutput, err2 := sm.ExecuteOnAgent(targetMachine, directCmd)
+		if err2 != nil {
+			return nil, fmt.Errorf("base64解码错误(%v), 直接读取也失败(%v)", err, err2)
+		}
+		return []byte(directOutput), nil
 	}
-	return processFileContent(file, content, args)
+	return decoded, nil
 }
 
 // hasLineRange 检查是否指定了行号范围
@@ -90,16 +101,29 @@ func processFileContent(file string, content []byte, args map[string]interface{}
 			file)
 	}
 
-	// 分割成行
-	lines := strings.Split(string(content), "\n")
+	// 二进制文件只返回摘要
+	if IsBinaryFile(content) {
+		return BinarySummary(file, content)
+	}
+
+	// 转成UTF-8，统一换行后分割成行
+	text, format := DecodeText(content)
+	// 末尾换行不算一行，行号与按行号编辑一致
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
 	totalLines := len(lines)
 
+	// 非UTF-8或非LF的文件在标题里注明，编辑时会按原格式写回
+	formatInfo := ""
+	if desc := format.Describe(); desc != "" {
+		formatInfo = "，" + desc
+	}
+
 	// 检查行数限制
 	if totalLines > MaxReadLines && !hasLineRange(args) {
 		return fmt.Sprintf("[✗] 文件行数过多: %s (%d 行)\n"+
 			"限制: %d 行\n"+
-			"提示: 使用 start_line 和 end_line 参数分段读取\n"+
-			"示例: {\"file\": \"%s\", \"start_line\": 1, \"end_line\": 100}",
+			"提示: 先用 outline 查看符号及行号，再用 start_line 和 end_line 参数分段读取\n"+
+			"示例: {\"action\": \"outline\", \"file\": \"%s\"}",
 			file,
 			totalLines,
 			MaxReadLines,
@@ -155,17 +179,24 @@ func processFileContent(file string, content []byte, args map[string]interface{}
 
 	// 提取指定范围的行
 	selectedLines := lines[startLine-1 : endLine]
-	result := strings.Join(selectedLines, "\n")
 
-	// 格式化输出
+	// 格式化输出（带行号和文件哈希，按行号编辑时用哈希确认文件没有变化）
+	result := strings.TrimSuffix(numberLines(selectedLines, startLine), "\n")
+	hash := contentHash(content)
 	if startLine == 1 && endLine == totalLines {
-		return fmt.Sprintf("[文件] %s (共 %d 行):\n```\n%s\n```", file, totalLines, result)
+		return fmt.Sprintf("[文件] %s (共 %d 行%s，哈希 %s):\n```\n%s\n```", file, totalLines, formatInfo, hash, result)
 	} else {
-		return fmt.Sprintf("[文件] %s (第 %d-%d 行，共 %d 行):\n```\n%s\n```",
-			file, startLine, endLine, totalLines, result)
+		return fmt.Sprintf("[文件] %s (第 %d-%d 行，共 %d 行%s，哈希 %s):\n```\n%s\n```",
+			file, startLine, endLine, totalLines, formatInfo, hash, result)
 	}
 }
 
+// contentHash 文件原始内容的短哈希
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
 // formatFileSize 格式化文件大小
 func formatFileSize(size int64) string {
 	const unit = 1024
@@ -181,10 +212,15 @@ func formatFileSize(size int64) string {
 }
 
 // ExecuteEditFile 编辑文件（支持远程）
+// 按原文件的编码、换行风格和末尾换行写回
 func ExecuteEditFile(toolCallID string, args map[string]interface{}, bm *backup.Manager, sm *state.Manager) string {
 	file := args["file"].(string)
-	old := args["old"].(string)
-	new := args["new"].(string)
+	old, hasOld := args["old"].(string)
+	new, _ := args["new"].(string)
+	lineMode := hasLineRange(args)
+	if !hasOld && !lineMode {
+		return "[✗] edit操作缺少old参数（或用 start_line/end_line 按行号编辑）"
+	}
 
 	// 获取目标机器（由executor注入）
 	targetMachine, _ := args["_target_machine"].(string)
@@ -192,92 +228,115 @@ func ExecuteEditFile(toolCallID string, args map[string]interface{}, bm *backup.
 		targetMachine = "local"
 	}
 
-	// 远程机器：先读取备份，然后用sed编辑
-	if targetMachine != "local" {
-		// 1. 先读取原文件内容（用于备份）
-		// 使用 base64 -w 0 确保输出没有换行符
-		readCmd := fmt.Sprintf("cat '%s' 2>/dev/null | base64 -w 0 || base64 < '%s'", file, file)
-		b64Content, err := sm.ExecuteOnAgent(targetMachine, readCmd)
-		if err != nil {
-			return fmt.Sprintf("[✗] 读取文件失败: %v", err)
-		}
-
-		// 清理所有空白字符（换行、空格等）
-		b64Content = strings.ReplaceAll(b64Content, "\n", "")
-		b64Content = strings.ReplaceAll(b64Content, "\r", "")
-		b64Content = strings.ReplaceAll(b64Content, " ", "")
-		b64Content = strings.TrimSpace(b64Content)
+	oldContent, err := readFileContent(file, targetMachine, sm)
+	if err != nil {
+		return fmt.Sprintf("[✗] 读取文件失败: %v", err)
+	}
+	if targetMachine != "local" && len(oldContent) == 0 {
+		return fmt.Sprintf("[✗] 文件为空或读取失败: %s", file)
+	}
+	if IsBinaryFile(oldContent) {
+		return fmt.Sprintf("[✗] %s 是二进制文件，不能用 edit 修改", file)
+	}
+	if msg := checkReadBeforeEdit(file, targetMachine, oldContent, true); msg != "" {
+		return msg
+	}
 
-		// 验证是否为有效的base64
-		if b64Content == "" {
-			return fmt.Sprintf("[✗] 文件为空或读取失败: %s", file)
+	// 在解码后的文本上替换（CRLF文件已统一为 \n，模型给出的片段也统一）
+	text, format := DecodeText(oldContent)
+	old = NormalizeNewlines(old, format)
+	new = NormalizeNewlines(new, format)
+
+	var newText, detail string
+	if lineMode {
+		var errMsg string
+		newText, detail, errMsg = replaceLineRange(text, oldContent, args, old, hasOld, new)
+		if errMsg != "" {
+			return errMsg
 		}
-
-		oldContent, err := base64.StdEncoding.DecodeString(b64Content)
-		if err != nil {
-			// 如果base64解码失败，尝试直接读取
-			directCmd := fmt.Sprintf("cat '%s' 2>/dev/null", file)
-			directContent, err2 := sm.ExecuteOnAgent(targetMachine, directCmd)
-			if err2 != nil {
-				return fmt.Sprintf("[✗] 读取文件失败: base64解码错误(%v), 直接读取也失败(%v)", err, err2)
-			}
-			oldContent = []byte(directContent)
+	} else {
+		var errMsg string
+		newText, detail, errMsg = replaceMatches(text, old, new, args)
+		if errMsg != "" {
+			return errMsg
 		}
+	}
 
-		// 2. 检查匹配数量
-		text := string(oldContent)
-		count := strings.Count(text, old)
-
-		if count == 0 {
-			return "[✗] 未找到要替换的内容"
-		}
-		if count > 1 {
-			return fmt.Sprintf("[✗] 找到%d处匹配，无法确定唯一位置", count)
-		}
+	// 按原格式编码
+	newContent, err := EncodeText(newText, format)
+	if err != nil {
+		return fmt.Sprintf("[✗] %v（文件为 %s 编码），文件未改动", err, format.Encoding)
+	}
 
-		// 3. 执行替换（在Go中完成，确保一致性）
-		newText := strings.Replace(text, old, new, 1)
+	if err := writeFileContent(file, targetMachine, newContent, sm); err != nil {
+		return fmt.Sprintf("[✗] 写入失败: %v", err)
+	}
 
-		// 4. 写回远程文件（使用base64避免特殊字符问题）
-		newB64 := base64.StdEncoding.EncodeToString([]byte(newText))
-		writeCmd := fmt.Sprintf("echo '%s' | base64 -d > '%s'", newB64, file)
-		_, err = sm.ExecuteOnAgent(targetMachine, writeCmd)
-		if err != nil {
-			return fmt.Sprintf("[✗] 写入失败: %v", err)
-		}
+	// 保存备份
+	backupPath := file
+	if targetMachine != "local" {
+		backupPath = file + "@" + targetMachine
+	}
+	bm.AddBackup(toolCallID, "edit", backupPath, oldContent)
 
-		// 5. 保存备份（和本地一样）
-		bm.AddBackup(toolCallID, "edit", file+"@"+targetMachine, oldContent)
+	formatInfo := detail
+	if desc := format.Describe(); desc != "" {
+		formatInfo += fmt.Sprintf("\n[i] 已按原格式保存（%s）", desc)
+	}
 
-		return fmt.Sprintf("[✓] 文件已修改: %s (机器: %s, 等待用户确认)", file, targetMachine)
+	// 语法检查（及可选的格式化），有错误时提示可以回滚
+	check := CheckEditedFile(file, targetMachine, []byte(newText), format, editAutoFormat(args), sm)
+	if targetMachine != "local" {
+		return fmt.Sprintf("[✓] 文件已修改: %s (机器: %s, 等待用户确认)%s%s", file, targetMachine, formatInfo, check)
 	}
+	return fmt.Sprintf("[✓] 文件已修改: %s（等待用户确认）%s%s", file, formatInfo, check)
+}
 
-	// 本地机器：原逻辑
-	oldContent, err := os.ReadFile(file)
-	if err != nil {
-		return fmt.Sprintf("[✗] 读取文件失败: %v", err)
+// replaceLineRange 按行号替换：先用 hash（读取时的文件哈希）或 old（这些行的原内容）确认文件没有变化
+// 返回新文本和结果说明，校验失败时返回错误信息
+func replaceLineRange(text string, raw []byte, args map[string]interface{}, old string, hasOld bool, new string) (string, string, string) {
+	hash, _ := args["hash"].(string)
+	hash = strings.TrimSpace(hash)
+	if hash == "" && !hasOld {
+		return "", "", "[✗] 按行号编辑需要 hash（read 结果中的文件哈希）或 old（这些行的原内容），用来确认文件没有变化"
 	}
 
-	text := string(oldContent)
-	count := strings.Count(text, old)
+	// 末尾换行不算一行
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	startLine := intArg(args, "start_line", 0)
+	endLine := intArg(args, "end_line", startLine)
+	if startLine < 1 || endLine < startLine || endLine > len(lines) {
+		return "", "", fmt.Sprintf("[✗] 行号范围无效: %d-%d（文件共 %d 行）", startLine, endLine, len(lines))
+	}
+	current := lines[startLine-1 : endLine]
 
-	if count == 0 {
-		return "[✗] 未找到要替换的内容"
+	if hash != "" && hash != contentHash(raw) {
+		return "", "", fmt.Sprintf("[✗] 文件已变化（当前哈希 %s，读取时为 %s），行号可能已失效，文件未改动\n"+
+			"第 %d-%d 行现在是:\n```\n%s```\n提示: 重新 read 后再按行号编辑",
+			contentHash(raw), hash, startLine, endLine, numberLines(current, startLine))
 	}
-	if count > 1 {
-		return fmt.Sprintf("[✗] 找到%d处匹配，无法确定唯一位置", count)
+	if hasOld && strings.TrimSuffix(old, "\n") != strings.Join(current, "\n") {
+		return "", "", fmt.Sprintf("[✗] 第 %d-%d 行的内容与 old 不一致，文件可能已变化，文件未改动\n"+
+			"这些行现在是:\n```\n%s```",
+			startLine, endLine, numberLines(current, startLine))
 	}
 
-	// 执行替换
-	newText := strings.Replace(text, old, new, 1)
-	if err := os.WriteFile(file, []byte(newText), 0644); err != nil {
-		return fmt.Sprintf("[✗] 写入失败: %v", err)
+	// new 为空表示删除这些行
+	var replacement []string
+	if new != "" {
+		replacement = strings.Split(strings.TrimSuffix(new, "\n"), "\n")
 	}
+	result := append([]string{}, lines[:startLine-1]...)
+	result = append(result, replacement...)
+	result = append(result, lines[endLine:]...)
 
-	// 保存备份
-	bm.AddBackup(toolCallID, "edit", file, oldContent)
-
-	return fmt.Sprintf("[✓] 文件已修改: %s（等待用户确认）", file)
+	newText := strings.Join(result, "\n")
+	if strings.HasSuffix(text, "\n") {
+		newText += "\n"
+	}
+	detail := fmt.Sprintf("\n[i] 第 %d-%d 行（%d 行）已替换为 %d 行，之后的行号已变化，继续按行号编辑前请重新 read",
+		startLine, endLine, endLine-startLine+1, len(replacement))
+	return newText, detail, ""
 }
 
 // ExecuteRenameSymbol 重命名符号
@@ -291,6 +350,10 @@ func ExecuteRenameSymbol(toolCallID string, args map[string]interface{}, bm *bac
 	if err != nil {
 		return fmt.Sprintf("[✗] 读取文件失败: %v", err)
 	}
+	// 和 edit 一样：要求本会话读过，且读取后没有被外部修改
+	if msg := checkReadBeforeEdit(file, "local", oldContent, true); msg != "" {
+		return msg
+	}
 
 	var result string
 	var newContent []byte
@@ -345,6 +408,7 @@ func ExecuteRenameSymbol(toolCallID string, args map[string]interface{}, bm *bac
 	if err := os.WriteFile(file, newContent, 0644); err != nil {
 		return fmt.Sprintf("[✗] 写入文件失败: %v", err)
 	}
+	recordFileSnapshot(file, "local", newContent)
 
 	// 保存备份
 	bm.AddBackup(toolCallID, "rename", file, oldContent)
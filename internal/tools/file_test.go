@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai_assistant/internal/backup"
+)
+
+func TestRenameSymbolRequiresFreshRead(t *testing.T) {
+	ResetReadTracker()
+	t.Cleanup(ResetReadTracker)
+
+	file := filepath.Join(t.TempDir(), "a.py")
+	content := []byte("def old_name():\n    return old_name\n")
+	if err := os.WriteFile(file, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := func() map[string]interface{} {
+		return map[string]interface{}{"file": file, "old_symbol": "old_name", "new_symbol": "new_name"}
+	}
+
+	// 没读过：拒绝，文件不变
+	if got := ExecuteRenameSymbol("call_1", args(), backup.NewManager()); !strings.Contains(got, "还没有读取过") {
+		t.Fatalf("unread file: %s", got)
+	}
+
+	// 读过后被外部修改：拒绝
+	recordFileSnapshot(file, "local", content)
+	changed := []byte("def old_name():\n    return 1\n")
+	os.WriteFile(file, changed, 0644)
+	if got := ExecuteRenameSymbol("call_2", args(), backup.NewManager()); !strings.Contains(got, "已被外部修改") {
+		t.Fatalf("stale file: %s", got)
+	}
+
+	// 重新读取后可以重命名
+	recordFileSnapshot(file, "local", changed)
+	if got := ExecuteRenameSymbol("call_3", args(), backup.NewManager()); !strings.HasPrefix(got, "[✓]") {
+		t.Fatalf("fresh read: %s", got)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "def new_name():\n    return 1\n" {
+		t.Errorf("file after rename = %q", data)
+	}
+}
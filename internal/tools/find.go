@@ -0,0 +1,403 @@
+package tools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_assistant/internal/state"
+)
+
+// 查找限制
+const (
+	DefaultFindLimit = 100
+	MaxFindLimit     = 1000
+	MaxFindScanned   = 200000           // 最多遍历的条目数
+	MaxFindCollected = 20000            // 排序前最多保留的匹配数
+	FindTimeout      = 20 * time.Second // 遍历超时，超时返回已找到的部分
+)
+
+// FindOptions 查找条件
+type FindOptions struct {
+	Path         string
+	Patterns     []string
+	Type         string  // f/d/l/any
+	MinSize      int64   // -1 表示不限
+	MaxSize      int64   // -1 表示不限
+	NewerThan    float64 // 秒，修改时间在这之内
+	OlderThan    float64 // 秒，修改时间早于这之前
+	MaxDepth     int     // 0 表示不限
+	Exclude      []string
+	Gitignore    bool
+	Sort         string // name/mtime/size
+	Limit        int
+	MaxScanned   int
+	MaxCollected int
+	Timeout      float64 // 秒
+	SkipDirs     []string
+}
+
+// FindEntry 找到的一项
+type FindEntry struct {
+	Path  string  `json:"path"`
+	Type  string  `json:"type"`
+	Size  int64   `json:"size"`
+	Mtime float64 `json:"mtime"`
+}
+
+// FindReport 查找结果（本地和寄生虫共用）
+type FindReport struct {
+	Entries   []FindEntry `json:"entries"`
+	Total     int         `json:"total"`
+	Scanned   int         `json:"scanned"`
+	Truncated bool        `json:"truncated"`
+	TimedOut  bool        `json:"timed_out"`
+}
+
+// ExecuteFind 按名称、类型、大小和修改时间查找文件（限制扫描量和耗时）
+func ExecuteFind(args map[string]interface{}, sm *state.Manager) string {
+	targetMachine := resolveMachine(args, sm)
+	dir, _ := args["path"].(string)
+	newerThan, _ := args["newer_than"].(string)
+	olderThan, _ := args["older_than"].(string)
+
+	opts := FindOptions{
+		Path:         resolvePath(dir, targetMachine, sm),
+		Patterns:     splitPatterns(args["pattern"]),
+		Type:         "f",
+		MinSize:      -1,
+		MaxSize:      -1,
+		MaxDepth:     intArg(args, "max_depth", 0),
+		Exclude:      splitPatterns(args["exclude"]),
+		Sort:         "name",
+		Limit:        intArg(args, "limit", DefaultFindLimit),
+		MaxScanned:   MaxFindScanned,
+		MaxCollected: MaxFindCollected,
+		Timeout:      FindTimeout.Seconds(),
+		SkipDirs:     defaultSkipDirs,
+	}
+	if opts.Path == "" && targetMachine == "local" {
+		opts.Path = sm.WorkDir("local")
+	}
+	if t, ok := args["type"].(string); ok && t != "" {
+		if t != "f" && t != "d" && t != "l" && t != "any" {
+			return fmt.Sprintf("[✗] 无效的type: %s（可选 f、d、l、any）", t)
+		}
+		opts.Type = t
+	}
+	if s, ok := args["sort"].(string); ok && s != "" {
+		if s != "name" && s != "mtime" && s != "size" {
+			return fmt.Sprintf("[✗] 无效的sort: %s（可选 name、mtime、size）", s)
+		}
+		opts.Sort = s
+	}
+	if g, ok := args["gitignore"].(bool); ok {
+		opts.Gitignore = g
+	}
+	if opts.Limit <= 0 || opts.Limit > MaxFindLimit {
+		opts.Limit = MaxFindLimit
+	}
+
+	var err error
+	if opts.MinSize, err = parseSizeArg(args["min_size"]); err != nil {
+		return fmt.Sprintf("[✗] min_size无效: %v", err)
+	}
+	if opts.MaxSize, err = parseSizeArg(args["max_size"]); err != nil {
+		return fmt.Sprintf("[✗] max_size无效: %v", err)
+	}
+	newer, err := parseAgeArg(newerThan)
+	if err != nil {
+		return fmt.Sprintf("[✗] newer_than无效: %v", err)
+	}
+	older, err := parseAgeArg(olderThan)
+	if err != nil {
+		return fmt.Sprintf("[✗] older_than无效: %v", err)
+	}
+	opts.NewerThan, opts.OlderThan = newer.Seconds(), older.Seconds()
+
+	var report *FindReport
+	if targetMachine == "local" {
+		report, err = FindFiles(opts)
+	} else {
+		report, err = findOnAgent(sm, targetMachine, opts)
+	}
+	if err != nil {
+		return fmt.Sprintf("[✗] 查找失败: %v", err)
+	}
+	return formatFindReport(opts, targetMachine, report)
+}
+
+// FindFiles 本地遍历查找，规则与寄生虫的 find action 一致
+func FindFiles(opts FindOptions) (*FindReport, error) {
+	root := filepath.Clean(opts.Path)
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("不是目录: %s", root)
+	}
+
+	report := &FindReport{}
+	matchers := map[string]*ignoreMatcher{}
+	if opts.Gitignore {
+		matchers[root] = newIgnoreMatcher(root)
+	}
+	now := time.Now()
+	deadline := now.Add(time.Duration(opts.Timeout * float64(time.Second)))
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			return nil
+		}
+		report.Scanned++
+		if report.Scanned > opts.MaxScanned {
+			report.Truncated = true
+			return filepath.SkipAll
+		}
+		if report.Scanned%1000 == 0 && time.Now().After(deadline) {
+			report.TimedOut = true
+			return filepath.SkipAll
+		}
+
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		level := strings.Count(rel, "/") + 1
+
+		kind := "f"
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			kind = "l"
+		case d.IsDir():
+			kind = "d"
+			if isSkippedDir(d.Name()) || matchAnyGlob(opts.Exclude, rel) {
+				return filepath.SkipDir
+			}
+			if opts.Gitignore {
+				parent := matchers[filepath.Dir(p)]
+				if parent.Match(rel, true) {
+					return filepath.SkipDir
+				}
+				matchers[p] = parent.withDir(p, rel)
+			}
+		default:
+			if opts.Gitignore && matchers[filepath.Dir(p)].Match(rel, false) {
+				return nil
+			}
+			if matchAnyGlob(opts.Exclude, rel) {
+				return nil
+			}
+		}
+
+		// 超过深度的目录仍然参与匹配，但不再展开
+		var result error
+		if kind == "d" && opts.MaxDepth > 0 && level >= opts.MaxDepth {
+			result = filepath.SkipDir
+		}
+
+		if opts.Type != "any" && kind != opts.Type {
+			return result
+		}
+		if len(opts.Patterns) > 0 && !matchAnyGlob(opts.Patterns, rel) {
+			return result
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return result
+		}
+		size := fi.Size()
+		if kind == "d" {
+			size = 0
+		}
+		if (opts.MinSize >= 0 && size < opts.MinSize) || (opts.MaxSize >= 0 && size > opts.MaxSize) {
+			return result
+		}
+		age := now.Sub(fi.ModTime()).Seconds()
+		if (opts.NewerThan > 0 && age > opts.NewerThan) || (opts.OlderThan > 0 && age < opts.OlderThan) {
+			return result
+		}
+
+		report.Total++
+		if len(report.Entries) >= opts.MaxCollected {
+			report.Truncated = true
+			return result
+		}
+		report.Entries = append(report.Entries, FindEntry{
+			Path:  filepath.ToSlash(p),
+			Type:  kind,
+			Size:  size,
+			Mtime: float64(fi.ModTime().UnixNano()) / 1e9,
+		})
+		return result
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortFindEntries(report.Entries, opts.Sort)
+	if len(report.Entries) > opts.Limit {
+		report.Entries = report.Entries[:opts.Limit]
+	}
+	return report, nil
+}
+
+// sortFindEntries 按名称升序，或按修改时间/大小降序
+func sortFindEntries(entries []FindEntry, by string) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		switch by {
+		case "mtime":
+			return entries[i].Mtime > entries[j].Mtime
+		case "size":
+			return entries[i].Size > entries[j].Size
+		default:
+			return entries[i].Path < entries[j].Path
+		}
+	})
+}
+
+// findOnAgent 调用寄生虫的 find action
+func findOnAgent(sm *state.Manager, machineID string, opts FindOptions) (*FindReport, error) {
+	resp, err := sm.CallAgentAPI(machineID, "find", map[string]interface{}{
+		"path":          opts.Path,
+		"patterns":      opts.Patterns,
+		"type":          opts.Type,
+		"min_size":      opts.MinSize,
+		"max_size":      opts.MaxSize,
+		"newer_than":    opts.NewerThan,
+		"older_than":    opts.OlderThan,
+		"max_depth":     opts.MaxDepth,
+		"exclude":       opts.Exclude,
+		"gitignore":     opts.Gitignore,
+		"sort":          opts.Sort,
+		"limit":         opts.Limit,
+		"max_scanned":   opts.MaxScanned,
+		"max_collected": opts.MaxCollected,
+		"timeout":       opts.Timeout,
+		"skip_dirs":     opts.SkipDirs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var report FindReport
+	if err := decodeAgentResponse(resp, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// formatFindReport 每项一行：修改时间、大小、路径
+func formatFindReport(opts FindOptions, targetMachine string, report *FindReport) string {
+	where := opts.Path
+	if where == "" {
+		where = "当前目录"
+	}
+	if targetMachine != "local" {
+		where = fmt.Sprintf("%s (机器: %s)", where, targetMachine)
+	}
+
+	var notes []string
+	if report.TimedOut {
+		notes = append(notes, fmt.Sprintf("[!] 遍历超过 %v 已停止，结果不完整，请缩小 path 或加 max_depth/exclude", FindTimeout))
+	}
+	if report.Truncated {
+		notes = append(notes, "[!] 扫描或匹配数量达到上限，结果不完整，请缩小范围")
+	}
+
+	if report.Total == 0 {
+		result := fmt.Sprintf("[✗] %s 中没有符合条件的项（扫描 %d 项）", where, report.Scanned)
+		if len(notes) > 0 {
+			result += "\n" + strings.Join(notes, "\n")
+		}
+		return result
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[查找] %s 中找到 %d 项（扫描 %d 项，按%s排序", where, report.Total, report.Scanned, findSortLabel(opts.Sort)))
+	if report.Total > len(report.Entries) {
+		sb.WriteString(fmt.Sprintf("，显示前 %d 项", len(report.Entries)))
+	}
+	sb.WriteString("）:\n")
+	for _, e := range report.Entries {
+		mtime := time.Unix(int64(e.Mtime), 0).Format("2006-01-02 15:04")
+		size := formatFileSize(e.Size)
+		name := e.Path
+		switch e.Type {
+		case "d":
+			size, name = "-", name+"/"
+		case "l":
+			name += " (链接)"
+		}
+		sb.WriteString(fmt.Sprintf("  %s  %9s  %s\n", mtime, size, name))
+	}
+	for _, note := range notes {
+		sb.WriteString(note + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func findSortLabel(by string) string {
+	switch by {
+	case "mtime":
+		return "修改时间（新→旧）"
+	case "size":
+		return "大小（大→小）"
+	default:
+		return "路径"
+	}
+}
+
+// parseSizeArg 解析大小：字节数，或带单位的字符串（如 10M、1.5G、512k），未指定返回 -1
+func parseSizeArg(v interface{}) (int64, error) {
+	switch val := v.(type) {
+	case nil:
+		return -1, nil
+	case float64:
+		return int64(val), nil
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(val))
+		if s == "" {
+			return -1, nil
+		}
+		s = strings.TrimSuffix(s, "B")
+		unit := int64(1)
+		if s != "" {
+			if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+				unit = int64(1) << (10 * (i + 1))
+				s = s[:len(s)-1]
+			}
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("无法解析 %q（示例: 1024、512k、10M、1.5G）", val)
+		}
+		return int64(n * float64(unit)), nil
+	default:
+		return 0, fmt.Errorf("类型错误")
+	}
+}
+
+// parseAgeArg 解析时长：Go时长格式（30m、1h30m），另外支持天(d)和周(w)，为空返回0
+func parseAgeArg(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			if n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64); err == nil && n >= 0 {
+				return time.Duration(n * float64(unit)), nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("无法解析 %q（示例: 30m、1h、2d、1w）", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,569 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"ai_assistant/internal/state"
+)
+
+// Git工具限制
+const (
+	GitTimeout        = 60 * time.Second
+	DefaultGitLogSize = 20
+	MaxGitLogSize     = 200
+	MaxGitDiffChars   = 30000 // diff正文最多返回的字符数
+)
+
+// GitStatus 解析后的 git status --porcelain=v2
+type GitStatus struct {
+	Branch    string
+	Oid       string
+	Upstream  string
+	Ahead     int
+	Behind    int
+	Staged    []GitFileChange
+	Unstaged  []GitFileChange
+	Untracked []string
+	Conflicts []string
+}
+
+// GitFileChange 单个文件的变更
+type GitFileChange struct {
+	Path     string
+	OrigPath string // 重命名/复制前的路径
+	Status   string // 中文状态描述
+}
+
+// GitCommit 结构化的提交记录
+type GitCommit struct {
+	Hash    string
+	Author  string
+	Email   string
+	Date    string
+	Subject string
+}
+
+// ExecuteGit Git工具（status/diff/log/branch/stash/commit/checkout/reset）
+func ExecuteGit(args map[string]interface{}, sm *state.Manager) string {
+	action, _ := args["action"].(string)
+	targetMachine := resolveMachine(args, sm)
+
+	// 仓库路径：本地默认当前目录，远程默认寄生虫Shell的当前目录
+	repo, _ := args["path"].(string)
+
+	git := func(gitArgs ...string) (string, error) {
+		return runGit(sm, targetMachine, repo, gitArgs...)
+	}
+
+	// ref/name 会原样作为 git 参数，以 - 开头会被当成选项（如 diff --output=文件 可覆盖任意文件）
+	for _, key := range []string{"ref", "name"} {
+		if v, _ := args[key].(string); strings.HasPrefix(v, "-") {
+			return fmt.Sprintf("[✗] %s 不能以 - 开头: %s", key, v)
+		}
+	}
+
+	var result string
+	switch action {
+	case "status":
+		result = gitStatus(git)
+	case "diff":
+		result = gitDiff(git, args)
+	case "log":
+		result = gitLog(git, args)
+	case "branch":
+		result = gitBranch(git, args)
+	case "stash":
+		result = gitStash(git, args)
+	case "commit":
+		result = gitCommit(git, args)
+	case "checkout":
+		ref, _ := args["ref"].(string)
+		if ref == "" {
+			return "[✗] checkout操作缺少ref参数"
+		}
+		checkoutArgs := []string{"checkout", ref}
+		if create, ok := args["create"].(bool); ok && create {
+			checkoutArgs = []string{"checkout", "-b", ref}
+		}
+		out, err := git(checkoutArgs...)
+		if err != nil {
+			return fmt.Sprintf("[✗] checkout失败: %v", err)
+		}
+		result = fmt.Sprintf("[✓] 已切换到: %s\n%s", ref, strings.TrimSpace(out))
+	case "reset":
+		result = gitReset(git, args)
+	default:
+		return fmt.Sprintf("[✗] 未知Git操作: %s", action)
+	}
+
+	if targetMachine != "local" && !strings.HasPrefix(result, "[✗]") {
+		result = fmt.Sprintf("[机器] %s\n%s", targetMachine, result)
+	}
+	return result
+}
+
+// runGit 在目标机器执行git命令，非零退出码转换为错误
+func runGit(sm *state.Manager, machineID, repo string, gitArgs ...string) (string, error) {
+	argv := append([]string{"git", "-c", "core.quotepath=off", "-c", "color.ui=never"}, gitArgs...)
+	res, err := runArgv(sm, machineID, repo, GitTimeout, argv...)
+	if err != nil {
+		return "", err
+	}
+	if res.TimedOut {
+		return "", fmt.Errorf("git命令超时（%v）", GitTimeout)
+	}
+	if res.ExitCode != 0 {
+		msg := strings.TrimSpace(res.Stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(res.Stdout)
+		}
+		return "", fmt.Errorf("%s", msg)
+	}
+	return res.Stdout, nil
+}
+
+// gitStatus 解析 porcelain v2 状态
+func gitStatus(git func(...string) (string, error)) string {
+	out, err := git("status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return fmt.Sprintf("[✗] git status失败: %v", err)
+	}
+
+	st := ParseGitStatus(out)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[Git] 分支: %s", st.Branch))
+	if st.Oid != "" {
+		sb.WriteString(fmt.Sprintf(" (%s)", shortHash(st.Oid)))
+	}
+	if st.Upstream != "" {
+		sb.WriteString(fmt.Sprintf("  上游: %s (领先 %d, 落后 %d)", st.Upstream, st.Ahead, st.Behind))
+	}
+	sb.WriteString("\n")
+
+	if len(st.Staged)+len(st.Unstaged)+len(st.Untracked)+len(st.Conflicts) == 0 {
+		sb.WriteString("[✓] 工作区干净")
+		return sb.String()
+	}
+
+	writeChanges := func(title string, changes []GitFileChange) {
+		if len(changes) == 0 {
+			return
+		}
+		sb.WriteString(fmt.Sprintf("\n%s (%d):\n", title, len(changes)))
+		for _, c := range changes {
+			if c.OrigPath != "" {
+				sb.WriteString(fmt.Sprintf("  %s: %s -> %s\n", c.Status, c.OrigPath, c.Path))
+			} else {
+				sb.WriteString(fmt.Sprintf("  %s: %s\n", c.Status, c.Path))
+			}
+		}
+	}
+	writeList := func(title string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		sb.WriteString(fmt.Sprintf("\n%s (%d):\n", title, len(paths)))
+		for _, p := range paths {
+			sb.WriteString("  " + p + "\n")
+		}
+	}
+
+	writeList("冲突", st.Conflicts)
+	writeChanges("已暂存", st.Staged)
+	writeChanges("未暂存", st.Unstaged)
+	writeList("未跟踪", st.Untracked)
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// ParseGitStatus 解析 git status --porcelain=v2 --branch -z 的输出
+func ParseGitStatus(out string) GitStatus {
+	st := GitStatus{Branch: "(未知)"}
+	records := strings.Split(out, "\x00")
+
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		if rec == "" {
+			continue
+		}
+
+		switch rec[0] {
+		case '#':
+			fields := strings.Fields(rec)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				if fields[2] != "(initial)" {
+					st.Oid = fields[2]
+				}
+			case "branch.head":
+				st.Branch = fields[2]
+				if st.Branch == "(detached)" {
+					st.Branch = "(分离HEAD)"
+				}
+			case "branch.upstream":
+				st.Upstream = fields[2]
+			case "branch.ab":
+				if len(fields) >= 4 {
+					st.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+					st.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+
+		case '1', '2':
+			// 1 XY sub mH mI mW hH hI path
+			// 2 XY sub mH mI mW hH hI Xscore path<NUL>origPath
+			n := 9
+			if rec[0] == '2' {
+				n = 10
+			}
+			fields := strings.SplitN(rec, " ", n)
+			if len(fields) < n {
+				continue
+			}
+			xy := fields[1]
+			path := fields[n-1]
+			origPath := ""
+			if rec[0] == '2' && i+1 < len(records) {
+				origPath = records[i+1]
+				i++
+			}
+			if xy[0] != '.' {
+				st.Staged = append(st.Staged, GitFileChange{Path: path, OrigPath: origPath, Status: gitStatusName(xy[0])})
+			}
+			if xy[1] != '.' {
+				st.Unstaged = append(st.Unstaged, GitFileChange{Path: path, OrigPath: origPath, Status: gitStatusName(xy[1])})
+			}
+
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			fields := strings.SplitN(rec, " ", 11)
+			if len(fields) == 11 {
+				st.Conflicts = append(st.Conflicts, fields[10])
+			}
+
+		case '?':
+			st.Untracked = append(st.Untracked, strings.TrimPrefix(rec, "? "))
+		}
+	}
+
+	return st
+}
+
+// gitStatusName 状态字母转中文描述
+func gitStatusName(c byte) string {
+	switch c {
+	case 'M':
+		return "修改"
+	case 'T':
+		return "类型变更"
+	case 'A':
+		return "新增"
+	case 'D':
+		return "删除"
+	case 'R':
+		return "重命名"
+	case 'C':
+		return "复制"
+	case 'U':
+		return "未合并"
+	default:
+		return string(c)
+	}
+}
+
+// gitDiff 按文件输出差异（支持暂存区、统计模式、指定提交范围）
+func gitDiff(git func(...string) (string, error), args map[string]interface{}) string {
+	base := []string{"diff"}
+	staged, _ := args["staged"].(bool)
+	if staged {
+		base = append(base, "--cached")
+	}
+	if ref, ok := args["ref"].(string); ok && ref != "" {
+		base = append(base, ref)
+	}
+	var paths []string
+	if file, ok := args["file"].(string); ok && file != "" {
+		paths = []string{"--", file}
+	}
+
+	// 先取统计，每个文件的增删行数
+	numstat, err := git(append(append(append([]string{}, base...), "--numstat"), paths...)...)
+	if err != nil {
+		return fmt.Sprintf("[✗] git diff失败: %v", err)
+	}
+
+	scope := "工作区(未暂存)"
+	if staged {
+		scope = "暂存区"
+	}
+	if ref, ok := args["ref"].(string); ok && ref != "" {
+		scope = ref
+	}
+
+	var sb strings.Builder
+	files := 0
+	totalAdd, totalDel := 0, 0
+	for _, line := range strings.Split(strings.TrimSpace(numstat), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		files++
+		if fields[0] == "-" {
+			sb.WriteString(fmt.Sprintf("  %s (二进制)\n", fields[2]))
+			continue
+		}
+		add, _ := strconv.Atoi(fields[0])
+		del, _ := strconv.Atoi(fields[1])
+		totalAdd += add
+		totalDel += del
+		sb.WriteString(fmt.Sprintf("  %s (+%d -%d)\n", fields[2], add, del))
+	}
+
+	if files == 0 {
+		return fmt.Sprintf("[Git] %s 无差异", scope)
+	}
+
+	header := fmt.Sprintf("[Git] %s 差异: %d 个文件, +%d -%d\n%s", scope, files, totalAdd, totalDel, sb.String())
+	if stat, ok := args["stat"].(bool); ok && stat {
+		return strings.TrimRight(header, "\n")
+	}
+
+	patch, err := git(append(append(append([]string{}, base...), "--no-ext-diff"), paths...)...)
+	if err != nil {
+		return fmt.Sprintf("[✗] git diff失败: %v", err)
+	}
+
+	truncated := false
+	if len(patch) > MaxGitDiffChars {
+		patch = patch[:MaxGitDiffChars]
+		truncated = true
+	}
+
+	result := fmt.Sprintf("%s\n```diff\n%s\n```", header, strings.TrimRight(patch, "\n"))
+	if truncated {
+		result += fmt.Sprintf("\n[!] diff过长已截断（%d 字符），请用 file 参数逐个文件查看", MaxGitDiffChars)
+	}
+	return result
+}
+
+// gitLog 结构化提交历史
+func gitLog(git func(...string) (string, error), args map[string]interface{}) string {
+	limit := intArg(args, "limit", DefaultGitLogSize)
+	if limit <= 0 || limit > MaxGitLogSize {
+		limit = MaxGitLogSize
+	}
+
+	logArgs := []string{"log", fmt.Sprintf("-n%d", limit), "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1e"}
+	if ref, ok := args["ref"].(string); ok && ref != "" {
+		logArgs = append(logArgs, ref)
+	}
+	if file, ok := args["file"].(string); ok && file != "" {
+		logArgs = append(logArgs, "--", file)
+	}
+
+	out, err := git(logArgs...)
+	if err != nil {
+		return fmt.Sprintf("[✗] git log失败: %v", err)
+	}
+
+	commits := ParseGitLog(out)
+	if len(commits) == 0 {
+		return "[Git] 没有提交记录"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[Git] 最近 %d 条提交:\n", len(commits)))
+	for _, c := range commits {
+		date := c.Date
+		if t, err := time.Parse(time.RFC3339, c.Date); err == nil {
+			date = t.Format("2006-01-02 15:04")
+		}
+		sb.WriteString(fmt.Sprintf("  %s  %s  %s  %s\n", shortHash(c.Hash), date, c.Author, c.Subject))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// ParseGitLog 解析 %H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1e 格式的git log输出
+func ParseGitLog(out string) []GitCommit {
+	var commits []GitCommit
+	for _, rec := range strings.Split(out, "\x1e") {
+		rec = strings.TrimSpace(rec)
+		if rec == "" {
+			continue
+		}
+		fields := strings.Split(rec, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		commits = append(commits, GitCommit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Date:    fields[3],
+			Subject: fields[4],
+		})
+	}
+	return commits
+}
+
+// gitBranch 列出分支；指定name时创建分支
+func gitBranch(git func(...string) (string, error), args map[string]interface{}) string {
+	if name, ok := args["name"].(string); ok && name != "" {
+		branchArgs := []string{"branch", name}
+		if ref, ok := args["ref"].(string); ok && ref != "" {
+			branchArgs = append(branchArgs, ref)
+		}
+		if _, err := git(branchArgs...); err != nil {
+			return fmt.Sprintf("[✗] 创建分支失败: %v", err)
+		}
+		return fmt.Sprintf("[✓] 已创建分支: %s（未切换，使用 checkout 切换）", name)
+	}
+
+	out, err := git("branch", "-a", "--format=%(HEAD)%1f%(refname:short)%1f%(objectname:short)%1f%(upstream:short)%1f%(upstream:track)%1f%(contents:subject)")
+	if err != nil {
+		return fmt.Sprintf("[✗] git branch失败: %v", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[Git] 分支列表:\n")
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		marker := "  "
+		if fields[0] == "*" {
+			marker = "* "
+		}
+		info := fields[1] + "  " + fields[2]
+		if fields[3] != "" {
+			info += "  → " + fields[3]
+			if fields[4] != "" {
+				info += " " + fields[4]
+			}
+		}
+		sb.WriteString(fmt.Sprintf("%s%s  %s\n", marker, info, fields[5]))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// gitStash 贮藏管理（list/push/pop/apply/drop）
+func gitStash(git func(...string) (string, error), args map[string]interface{}) string {
+	op, _ := args["stash_action"].(string)
+	if op == "" {
+		op = "list"
+	}
+
+	switch op {
+	case "list":
+		out, err := git("stash", "list", "--format=%gd%x1f%cr%x1f%gs")
+		if err != nil {
+			return fmt.Sprintf("[✗] git stash list失败: %v", err)
+		}
+		out = strings.TrimSpace(out)
+		if out == "" {
+			return "[Git] 没有贮藏"
+		}
+		var sb strings.Builder
+		sb.WriteString("[Git] 贮藏列表:\n")
+		for _, line := range strings.Split(out, "\n") {
+			sb.WriteString("  " + strings.ReplaceAll(line, "\x1f", "  ") + "\n")
+		}
+		return strings.TrimRight(sb.String(), "\n")
+
+	case "push":
+		stashArgs := []string{"stash", "push", "--include-untracked"}
+		if msg, ok := args["message"].(string); ok && msg != "" {
+			stashArgs = append(stashArgs, "-m", msg)
+		}
+		out, err := git(stashArgs...)
+		if err != nil {
+			return fmt.Sprintf("[✗] git stash失败: %v", err)
+		}
+		return "[✓] " + strings.TrimSpace(out)
+
+	case "pop", "apply", "drop":
+		stashArgs := []string{"stash", op}
+		if ref, ok := args["ref"].(string); ok && ref != "" {
+			stashArgs = append(stashArgs, ref)
+		}
+		out, err := git(stashArgs...)
+		if err != nil {
+			return fmt.Sprintf("[✗] git stash %s失败: %v", op, err)
+		}
+		return fmt.Sprintf("[✓] git stash %s 完成\n%s", op, strings.TrimSpace(out))
+
+	default:
+		return fmt.Sprintf("[✗] 未知stash操作: %s", op)
+	}
+}
+
+// gitCommit 提交（files指定要暂存的文件，all为true时暂存所有已跟踪文件的修改）
+func gitCommit(git func(...string) (string, error), args map[string]interface{}) string {
+	message, _ := args["message"].(string)
+	if strings.TrimSpace(message) == "" {
+		return "[✗] commit操作缺少message参数"
+	}
+
+	if files := splitPatterns(args["files"]); len(files) > 0 {
+		if _, err := git(append([]string{"add", "--"}, files...)...); err != nil {
+			return fmt.Sprintf("[✗] git add失败: %v", err)
+		}
+	}
+
+	commitArgs := []string{"commit", "-m", message}
+	if all, ok := args["all"].(bool); ok && all {
+		commitArgs = append(commitArgs, "-a")
+	}
+	out, err := git(commitArgs...)
+	if err != nil {
+		return fmt.Sprintf("[✗] git commit失败: %v", err)
+	}
+
+	hash, _ := git("rev-parse", "--short", "HEAD")
+	return fmt.Sprintf("[✓] 已提交: %s\n%s", strings.TrimSpace(hash), strings.TrimSpace(out))
+}
+
+// gitReset 重置（soft/mixed/hard），或取消暂存指定文件
+func gitReset(git func(...string) (string, error), args map[string]interface{}) string {
+	if files := splitPatterns(args["files"]); len(files) > 0 {
+		if _, err := git(append([]string{"reset", "-q", "--"}, files...)...); err != nil {
+			return fmt.Sprintf("[✗] 取消暂存失败: %v", err)
+		}
+		return fmt.Sprintf("[✓] 已取消暂存: %s", strings.Join(files, ", "))
+	}
+
+	mode, _ := args["mode"].(string)
+	if mode == "" {
+		mode = "mixed"
+	}
+	if mode != "soft" && mode != "mixed" && mode != "hard" {
+		return fmt.Sprintf("[✗] 无效的reset模式: %s", mode)
+	}
+	ref, _ := args["ref"].(string)
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	out, err := git("reset", "--"+mode, ref)
+	if err != nil {
+		return fmt.Sprintf("[✗] git reset失败: %v", err)
+	}
+	return fmt.Sprintf("[✓] 已重置到 %s (--%s)\n%s", ref, mode, strings.TrimSpace(out))
+}
+
+// shortHash 截短提交哈希
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ai_assistant/internal/state"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	const oid = "0123456789abcdef0123456789abcdef01234567"
+	const h = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	header := "# branch.oid " + oid + "\x00# branch.head main\x00# branch.upstream origin/main\x00# branch.ab +2 -1\x00"
+
+	tests := []struct {
+		name  string
+		input string
+		want  GitStatus
+	}{
+		{
+			name:  "clean with upstream",
+			input: header,
+			want:  GitStatus{Branch: "main", Oid: oid, Upstream: "origin/main", Ahead: 2, Behind: 1},
+		},
+		{
+			name:  "initial commit, detached",
+			input: "# branch.oid (initial)\x00# branch.head (detached)\x00",
+			want:  GitStatus{Branch: "(分离HEAD)"},
+		},
+		{
+			name: "ordinary changes and untracked",
+			input: header +
+				"1 M. N... 100644 100644 100644 " + h + " " + h + " staged.go\x00" +
+				"1 .M N... 100644 100644 100644 " + h + " " + h + " dir with space/unstaged.go\x00" +
+				"1 AD N... 000000 100644 000000 " + h + " " + h + " added_then_deleted.go\x00" +
+				"? new file.txt\x00",
+			want: GitStatus{
+				Branch: "main", Oid: oid, Upstream: "origin/main", Ahead: 2, Behind: 1,
+				Staged: []GitFileChange{
+					{Path: "staged.go", Status: "修改"},
+					{Path: "added_then_deleted.go", Status: "新增"},
+				},
+				Unstaged: []GitFileChange{
+					{Path: "dir with space/unstaged.go", Status: "修改"},
+					{Path: "added_then_deleted.go", Status: "删除"},
+				},
+				Untracked: []string{"new file.txt"},
+			},
+		},
+		{
+			// porcelain v2 的重命名/复制记录带相似度，原路径是 -z 下的下一条记录
+			name: "renames and copies",
+			input: header +
+				"2 R. N... 100644 100644 100644 " + h + " " + h + " R100 new name.go\x00old name.go\x00" +
+				"2 RM N... 100644 100644 100644 " + h + " " + h + " R087 pkg/b.go\x00pkg/a.go\x00" +
+				"2 C. N... 100644 100644 100644 " + h + " " + h + " C100 copy.go\x00orig.go\x00" +
+				"? after.txt\x00",
+			want: GitStatus{
+				Branch: "main", Oid: oid, Upstream: "origin/main", Ahead: 2, Behind: 1,
+				Staged: []GitFileChange{
+					{Path: "new name.go", OrigPath: "old name.go", Status: "重命名"},
+					{Path: "pkg/b.go", OrigPath: "pkg/a.go", Status: "重命名"},
+					{Path: "copy.go", OrigPath: "orig.go", Status: "复制"},
+				},
+				Unstaged: []GitFileChange{
+					{Path: "pkg/b.go", OrigPath: "pkg/a.go", Status: "修改"},
+				},
+				Untracked: []string{"after.txt"},
+			},
+		},
+		{
+			name: "conflicts",
+			input: header +
+				"u UU N... 100644 100644 100644 100644 " + h + " " + h + " " + h + " conflict.go\x00",
+			want: GitStatus{
+				Branch: "main", Oid: oid, Upstream: "origin/main", Ahead: 2, Behind: 1,
+				Conflicts: []string{"conflict.go"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseGitStatus(tt.input)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGitStatus:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseGitStatusRealRename 用本机 git 生成真实的重命名输出
+func TestParseGitStatusRealRename(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com", "GIT_CONFIG_GLOBAL="+os.DevNull)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+
+	run("init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "old.txt"), []byte("some content\nmore lines\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", ".")
+	run("commit", "-q", "-m", "init")
+	run("mv", "old.txt", "new name.txt")
+
+	st := ParseGitStatus(run("status", "--porcelain=v2", "--branch", "-z"))
+	want := []GitFileChange{{Path: "new name.txt", OrigPath: "old.txt", Status: "重命名"}}
+	if st.Branch != "main" || !reflect.DeepEqual(st.Staged, want) || len(st.Unstaged) != 0 || len(st.Untracked) != 0 {
+		t.Errorf("ParseGitStatus = %+v", st)
+	}
+}
+
+func TestParseGitLog(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []GitCommit
+	}{
+		{name: "empty", input: "", want: nil},
+		{
+			name: "two commits",
+			input: "aaa\x1fAlice\x1falice@example.com\x1f2024-05-01T10:00:00+08:00\x1ffix: handle empty input\x1e\n" +
+				"bbb\x1f张三\x1fzs@example.com\x1f2024-04-30T09:00:00Z\x1f初始提交\x1e\n",
+			want: []GitCommit{
+				{Hash: "aaa", Author: "Alice", Email: "alice@example.com", Date: "2024-05-01T10:00:00+08:00", Subject: "fix: handle empty input"},
+				{Hash: "bbb", Author: "张三", Email: "zs@example.com", Date: "2024-04-30T09:00:00Z", Subject: "初始提交"},
+			},
+		},
+		{
+			name:  "malformed record skipped",
+			input: "garbage\x1e\nccc\x1fBob\x1fbob@example.com\x1f2024-01-01T00:00:00Z\x1fsubject | with pipe\x1e",
+			want: []GitCommit{
+				{Hash: "ccc", Author: "Bob", Email: "bob@example.com", Date: "2024-01-01T00:00:00Z", Subject: "subject | with pipe"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseGitLog(tt.input)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGitLog:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteGitRejectsOptionLikeRefs(t *testing.T) {
+	useTempConfigDir(t)
+
+	sm := state.NewManager()
+	tests := []map[string]interface{}{
+		{"action": "diff", "ref": "--output=/tmp/x"},
+		{"action": "log", "ref": "-p"},
+		{"action": "checkout", "ref": "--orphan=x"},
+		{"action": "branch", "name": "-D"},
+		{"action": "stash", "ref": "--all"},
+		{"action": "reset", "ref": "--hard"},
+	}
+	for _, args := range tests {
+		got := ExecuteGit(args, sm)
+		if !strings.HasPrefix(got, "[✗]") || !strings.Contains(got, "不能以 - 开头") {
+			t.Errorf("ExecuteGit(%v) = %q, want rejection", args, got)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package tools
+
+import (
+	"encoding/base64"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+
+	"ai_assistant/internal/backup"
+	"ai_assistant/internal/state"
+)
+
+// goDeclRange 一个声明在文件中的位置
+type goDeclRange struct {
+	Label     string // 显示名，如 func (*Manager).Save
+	Start     int    // 字节偏移，包含文档注释，从行首开始
+	BodyStart int    // 字节偏移，不含文档注释，从行首开始
+	End       int    // 字节偏移，到行尾（含换行）
+	StartLine int
+	EndLine   int
+}
+
+// ExecuteGoSymbolEdit 按符号名修改Go声明（replace_symbol/insert_after_symbol/delete_symbol）
+func ExecuteGoSymbolEdit(toolCallID string, args map[string]interface{}, bm *backup.Manager, sm *state.Manager) string {
+	action, _ := args["action"].(string)
+	file := args["file"].(string)
+	symbol, _ := args["symbol"].(string)
+	code, _ := args["new"].(string)
+
+	if !strings.HasSuffix(file, ".go") {
+		return fmt.Sprintf("[✗] %s 只支持Go文件，其他文件请用 edit", action)
+	}
+	if symbol == "" {
+		return fmt.Sprintf("[✗] %s操作缺少symbol参数", action)
+	}
+	if action != "delete_symbol" && strings.TrimSpace(code) == "" {
+		return fmt.Sprintf("[✗] %s操作缺少new参数（新的Go代码）", action)
+	}
+
+	targetMachine, _ := args["_target_machine"].(string)
+	if targetMachine == "" {
+		targetMachine = "local"
+	}
+
+	oldContent, err := readFileContent(file, targetMachine, sm)
+	if err != nil {
+		return fmt.Sprintf("[✗] 读取文件失败: %v", err)
+	}
+	if msg := checkReadBeforeEdit(file, targetMachine, oldContent, false); msg != "" {
+		return msg
+	}
+
+	// 在解码后的文本上修改（CRLF 统一为 \n），写回时恢复原来的编码和换行
+	text, textFormat := DecodeText(oldContent)
+	src := []byte(text)
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if err != nil {
+		return fmt.Sprintf("[✗] 文件当前无法解析，拒绝按符号修改（请先修复或改用 edit）: %v", err)
+	}
+
+	decl, err := findGoDecl(fset, f, src, symbol)
+	if err != nil {
+		return fmt.Sprintf("[✗] %v", err)
+	}
+
+	code = strings.TrimRight(NormalizeNewlines(code, textFormat), "\n") + "\n"
+	var newText, summary string
+	switch action {
+	case "replace_symbol":
+		// 新代码没有自带注释时保留原来的文档注释
+		start := decl.Start
+		trimmed := strings.TrimSpace(code)
+		if !strings.HasPrefix(trimmed, "//") && !strings.HasPrefix(trimmed, "/*") {
+			start = decl.BodyStart
+		}
+		newText = text[:start] + code + text[decl.End:]
+		summary = fmt.Sprintf("已替换 %s（原第 %d-%d 行）", decl.Label, decl.StartLine, decl.EndLine)
+	case "insert_after_symbol":
+		newText = text[:decl.End] + "\n" + code + text[decl.End:]
+		summary = fmt.Sprintf("已在 %s（第 %d-%d 行）之后插入代码", decl.Label, decl.StartLine, decl.EndLine)
+	case "delete_symbol":
+		newText = text[:decl.Start] + text[decl.End:]
+		summary = fmt.Sprintf("已删除 %s（原第 %d-%d 行，含文档注释）", decl.Label, decl.StartLine, decl.EndLine)
+	default:
+		return fmt.Sprintf("[✗] 未知符号操作: %s", action)
+	}
+
+	// gofmt 并确认修改后的文件仍然可以解析
+	formatted, err := format.Source([]byte(newText))
+	if err != nil {
+		return fmt.Sprintf("[✗] 修改后的代码无法解析，文件未改动: %v", err)
+	}
+
+	newContent, err := EncodeText(string(formatted), textFormat)
+	if err != nil {
+		return fmt.Sprintf("[✗] %v（文件为 %s 编码），文件未改动", err, textFormat.Encoding)
+	}
+
+	if err := writeFileContent(file, targetMachine, newContent, sm); err != nil {
+		return fmt.Sprintf("[✗] 写入失败: %v", err)
+	}
+
+	backupPath := file
+	machineInfo := ""
+	if targetMachine != "local" {
+		backupPath = file + "@" + targetMachine
+		machineInfo = fmt.Sprintf(", 机器: %s", targetMachine)
+	}
+	bm.AddBackup(toolCallID, "edit", backupPath, oldContent)
+
+	// 替换后报告新的行号，方便继续读取
+	if action == "replace_symbol" {
+		newFset := token.NewFileSet()
+		if nf, err := parser.ParseFile(newFset, file, formatted, parser.ParseComments); err == nil {
+			if d, err := findGoDecl(newFset, nf, formatted, symbol); err == nil {
+				summary += fmt.Sprintf(" → 现为第 %d-%d 行", d.StartLine, d.EndLine)
+			}
+		}
+	}
+
+	result := fmt.Sprintf("[✓] %s: %s，已gofmt（%s%s，等待用户确认）", file, summary, formatChangeLines(src, formatted), machineInfo)
+	if desc := textFormat.Describe(); desc != "" {
+		result += fmt.Sprintf("\n[i] 已按原格式保存（%s）", desc)
+	}
+	return result
+}
+
+// findGoDecl 按名称查找声明：Name、Type.Method、(*Type).Method
+func findGoDecl(fset *token.FileSet, f *ast.File, src []byte, symbol string) (*goDeclRange, error) {
+	cleaned := strings.NewReplacer("(", "", ")", "", "*", "").Replace(strings.TrimSpace(symbol))
+	recvName, name := "", cleaned
+	if i := strings.LastIndex(cleaned, "."); i >= 0 {
+		recvName, name = cleaned[:i], cleaned[i+1:]
+	}
+
+	var matches []*goDeclRange
+	add := func(label string, node ast.Node, doc *ast.CommentGroup, trailing *ast.CommentGroup) {
+		end := node.End()
+		if trailing != nil && trailing.End() > end {
+			end = trailing.End()
+		}
+		r := &goDeclRange{
+			Label:     label,
+			BodyStart: lineStartOffset(src, fset.Position(node.Pos()).Offset),
+			End:       lineEndOffset(src, fset.Position(end).Offset),
+			StartLine: fset.Position(node.Pos()).Line,
+			EndLine:   fset.Position(end).Line,
+		}
+		r.Start = r.BodyStart
+		if doc != nil {
+			r.Start = lineStartOffset(src, fset.Position(doc.Pos()).Offset)
+			r.StartLine = fset.Position(doc.Pos()).Line
+		}
+		matches = append(matches, r)
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Name.Name != name {
+				continue
+			}
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				if recvName == "" {
+					add("func "+name, d, d.Doc, nil)
+				}
+				continue
+			}
+			recv := receiverTypeName(d.Recv.List[0].Type)
+			if recvName == "" || recvName == recv {
+				add(fmt.Sprintf("func (%s).%s", exprString(d.Recv.List[0].Type), name), d, d.Doc, nil)
+			}
+
+		case *ast.GenDecl:
+			if recvName != "" || d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				var found bool
+				var specDoc, specComment *ast.CommentGroup
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					found = s.Name.Name == name
+					specDoc, specComment = s.Doc, s.Comment
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						found = found || n.Name == name
+					}
+					specDoc, specComment = s.Doc, s.Comment
+				}
+				if !found {
+					continue
+				}
+				if vs, ok := spec.(*ast.ValueSpec); ok && len(vs.Names) > 1 {
+					var names []string
+					for _, n := range vs.Names {
+						names = append(names, n.Name)
+					}
+					// 按符号修改的是整条声明，会连带改掉同一行声明的其他名称
+					return nil, fmt.Errorf("%s %s 在同一条声明中（第%d行），无法只修改 %s，请改用 edit",
+						d.Tok, strings.Join(names, ", "), fset.Position(vs.Pos()).Line, name)
+				}
+				label := d.Tok.String() + " " + name
+				if d.Lparen.IsValid() {
+					// 分组声明只改这一项
+					add(label, spec, specDoc, specComment)
+				} else {
+					add(label, d, d.Doc, specComment)
+				}
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("文件中没有找到声明: %s", symbol)
+	case 1:
+		return matches[0], nil
+	default:
+		var labels []string
+		for _, m := range matches {
+			labels = append(labels, fmt.Sprintf("%s (第%d行)", m.Label, m.StartLine))
+		}
+		return nil, fmt.Errorf("找到 %d 个同名声明，请用 类型.方法 指定: %s", len(matches), strings.Join(labels, ", "))
+	}
+}
+
+// receiverTypeName 接收者的类型名（去掉指针和类型参数）
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// exprString 表达式的源码形式
+func exprString(expr ast.Expr) string {
+	var sb strings.Builder
+	format.Node(&sb, token.NewFileSet(), expr)
+	return sb.String()
+}
+
+// lineStartOffset 偏移所在行的行首
+func lineStartOffset(src []byte, offset int) int {
+	for offset > 0 && src[offset-1] != '\n' {
+		offset--
+	}
+	return offset
+}
+
+// lineEndOffset 偏移所在行的行尾（包含换行符）
+func lineEndOffset(src []byte, offset int) int {
+	for offset < len(src) && src[offset] != '\n' {
+		offset++
+	}
+	if offset < len(src) {
+		offset++
+	}
+	return offset
+}
+
+// formatChangeLines 修改前后的行数变化
+func formatChangeLines(oldContent, newContent []byte) string {
+	oldLines := strings.Count(string(oldContent), "\n")
+	newLines := strings.Count(string(newContent), "\n")
+	return fmt.Sprintf("%d 行 → %d 行", oldLines, newLines)
+}
+
+// writeFileContent 写入本地或寄生虫上的文件（成功后更新读取记录，工具自己的修改不算外部修改）
+func writeFileContent(file, targetMachine string, content []byte, sm *state.Manager) error {
+	var err error
+	if targetMachine == "local" {
+		err = os.WriteFile(file, content, 0644)
+	} else {
+		// 使用base64避免特殊字符问题
+		b64 := base64.StdEncoding.EncodeToString(content)
+		_, err = sm.ExecuteOnAgent(targetMachine, fmt.Sprintf("echo '%s' | base64 -d > '%s'", b64, file))
+	}
+	if err == nil {
+		recordFileSnapshot(file, targetMachine, content)
+	}
+	return err
+}
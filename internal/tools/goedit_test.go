@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ai_assistant/internal/backup"
+	"ai_assistant/internal/state"
+)
+
+func TestGoSymbolEditKeepsFileFormat(t *testing.T) {
+	useTempConfigDir(t)
+	sm := state.NewManager()
+
+	const original = "package p\n\n// F 返回1\nfunc F() int { return 1 }\n\nfunc G() {}\n"
+	crlf := func(s string) []byte { return []byte(strings.ReplaceAll(s, "\n", "\r\n")) }
+	bom := []byte{0xEF, 0xBB, 0xBF}
+
+	tests := []struct {
+		name    string
+		content []byte
+		args    map[string]interface{}
+		want    []byte
+	}{
+		{
+			name:    "crlf replace",
+			content: crlf(original),
+			args:    map[string]interface{}{"action": "replace_symbol", "symbol": "F", "new": "func F() int {\r\n\treturn 2\r\n}"},
+			want:    crlf("package p\n\n// F 返回1\nfunc F() int {\n\treturn 2\n}\n\nfunc G() {}\n"),
+		},
+		{
+			name:    "bom crlf delete",
+			content: append(bom, crlf(original)...),
+			args:    map[string]interface{}{"action": "delete_symbol", "symbol": "G"},
+			want:    append(bom, crlf("package p\n\n// F 返回1\nfunc F() int { return 1 }\n")...),
+		},
+		{
+			name:    "lf insert",
+			content: []byte(original),
+			args:    map[string]interface{}{"action": "insert_after_symbol", "symbol": "F", "new": "func H() {}"},
+			want:    []byte("package p\n\n// F 返回1\nfunc F() int { return 1 }\n\nfunc H() {}\n\nfunc G() {}\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "p.go")
+			if err := os.WriteFile(file, tt.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			tt.args["file"] = file
+
+			result := ExecuteGoSymbolEdit("call_1", tt.args, backup.NewManager(), sm)
+			if !strings.HasPrefix(result, "[✓]") {
+				t.Fatalf("edit failed: %s", result)
+			}
+			got, _ := os.ReadFile(file)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("file after edit:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindGoDeclRefusesMultiNameSpecs(t *testing.T) {
+	src := []byte("package p\n\nvar a, b = 1, 2\n\nvar (\n\tc, d int\n\te = 3\n)\n\nvar f = 1\n")
+	tests := []struct {
+		symbol  string
+		wantErr bool
+	}{
+		{"a", true},
+		{"b", true},
+		{"c", true},
+		{"e", false},
+		{"f", false},
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		_, err := findGoDecl(fset, file, src, tt.symbol)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("findGoDecl(%q) err = %v, wantErr %v", tt.symbol, err, tt.wantErr)
+		}
+	}
+}
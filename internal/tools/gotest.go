@@ -0,0 +1,431 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ai_assistant/internal/state"
+)
+
+// 测试工具限制
+const (
+	DefaultTestTimeout   = 5 * time.Minute
+	MaxTestTimeout       = 30 * time.Minute
+	MaxTestFailuresShown = 30 // 最多展示的失败用例
+	MaxTestOutputPerCase = 40 // 每个失败用例最多展示的输出行
+	MaxTestBuildOutput   = 60 // 编译失败最多展示的行
+	MaxSlowTestsShown    = 5
+	MaxTestPackagesShown = 50 // 包列表最多展示的行
+)
+
+// GoTestEvent go test -json 输出的一条事件
+type GoTestEvent struct {
+	Time       time.Time
+	Action     string
+	Package    string
+	ImportPath string // build-output/build-fail 事件使用
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// GoTestCase 单个测试用例结果
+type GoTestCase struct {
+	Package  string
+	Name     string
+	Status   string // pass/fail/skip/run(未结束)
+	Elapsed  float64
+	Output   []string
+	Location string // 失败断言的 file:line
+}
+
+// GoTestPackage 包级结果
+type GoTestPackage struct {
+	Path        string
+	Status      string // pass/fail/skip(无测试文件)/build-fail
+	Elapsed     float64
+	Output      []string // 包级输出（如panic、编译错误）
+	Passed      int
+	Failed      int
+	Skipped     int
+	NoTestFiles bool
+}
+
+// GoTestReport 一次 go test 的解析结果
+type GoTestReport struct {
+	Packages []*GoTestPackage
+	Cases    []*GoTestCase
+}
+
+// goTestFailure 记录上次失败的用例，用于 rerun_failed
+type goTestFailure struct {
+	Package string
+	Test    string
+}
+
+// 按机器记录上次的失败用例
+var (
+	lastTestFailures      = make(map[string][]goTestFailure)
+	lastTestFailuresMutex sync.Mutex
+)
+
+// 失败输出中的断言位置，如 "    foo_test.go:42: expected 1"
+var testLocationRe = regexp.MustCompile(`^\s*([\w\-./\\]+\.go):(\d+):`)
+
+// ExecuteTest 运行 go test -json 并解析结果
+func ExecuteTest(args map[string]interface{}, sm *state.Manager) string {
+	targetMachine := resolveMachine(args, sm)
+	dir, _ := args["path"].(string)
+
+	packages := splitPatterns(args["packages"])
+	runPattern, _ := args["run"].(string)
+
+	rerun, _ := args["rerun_failed"].(bool)
+	if rerun {
+		lastTestFailuresMutex.Lock()
+		failures := lastTestFailures[targetMachine]
+		lastTestFailuresMutex.Unlock()
+		if len(failures) == 0 {
+			return "[i] 上次测试没有失败用例可以重跑"
+		}
+		packages, runPattern = failedTestSelection(failures)
+	}
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+
+	timeout := DefaultTestTimeout
+	if sec := intArg(args, "timeout", 0); sec > 0 {
+		timeout = time.Duration(sec) * time.Second
+		if timeout > MaxTestTimeout {
+			timeout = MaxTestTimeout
+		}
+	}
+
+	argv := []string{"go", "test", "-json", fmt.Sprintf("-timeout=%s", timeout)}
+	if runPattern != "" {
+		argv = append(argv, "-run", runPattern)
+	}
+	if short, _ := args["short"].(bool); short {
+		argv = append(argv, "-short")
+	}
+	if race, _ := args["race"].(bool); race {
+		argv = append(argv, "-race")
+	}
+	if count := intArg(args, "count", 0); count > 0 {
+		argv = append(argv, fmt.Sprintf("-count=%d", count))
+	}
+	argv = append(argv, packages...)
+
+	// 额外留出编译时间
+	start := time.Now()
+	res, err := runArgv(sm, targetMachine, dir, timeout+time.Minute, argv...)
+	if err != nil {
+		return fmt.Sprintf("[✗] 运行测试失败: %v", err)
+	}
+	if res.TimedOut {
+		return fmt.Sprintf("[✗] 测试超时（%v），可用 run 缩小范围或调大 timeout", timeout+time.Minute)
+	}
+
+	report := ParseGoTestJSON(res.Stdout)
+	if len(report.Packages) == 0 {
+		msg := strings.TrimSpace(res.Stderr + "\n" + res.Stdout)
+		if msg == "" {
+			msg = fmt.Sprintf("退出码 %d，没有输出", res.ExitCode)
+		}
+		return fmt.Sprintf("[✗] go test 失败:\n%s", truncateOutputLines(msg, MaxTestBuildOutput))
+	}
+
+	// 记录失败用例供 rerun_failed 使用
+	var failures []goTestFailure
+	for _, c := range report.Cases {
+		if c.Status == "fail" && !strings.Contains(c.Name, "/") {
+			failures = append(failures, goTestFailure{Package: c.Package, Test: c.Name})
+		}
+	}
+	lastTestFailuresMutex.Lock()
+	lastTestFailures[targetMachine] = failures
+	lastTestFailuresMutex.Unlock()
+
+	result := formatTestReport(report, strings.Join(argv, " "), time.Since(start), res.Stderr)
+	if targetMachine != "local" {
+		result = fmt.Sprintf("[机器] %s\n%s", targetMachine, result)
+	}
+	return result
+}
+
+// failedTestSelection 由失败用例生成要重跑的包和 -run 正则
+func failedTestSelection(failures []goTestFailure) ([]string, string) {
+	var packages, names []string
+	seenPkg := make(map[string]bool)
+	seenName := make(map[string]bool)
+	for _, f := range failures {
+		if !seenPkg[f.Package] {
+			seenPkg[f.Package] = true
+			packages = append(packages, f.Package)
+		}
+		if !seenName[f.Test] {
+			seenName[f.Test] = true
+			names = append(names, regexp.QuoteMeta(f.Test))
+		}
+	}
+	return packages, "^(" + strings.Join(names, "|") + ")$"
+}
+
+// ParseGoTestJSON 解析 go test -json 的输出（忽略非JSON行）
+func ParseGoTestJSON(out string) *GoTestReport {
+	report := &GoTestReport{}
+	pkgs := make(map[string]*GoTestPackage)
+	cases := make(map[string]*GoTestCase)
+
+	getPkg := func(path string) *GoTestPackage {
+		p, ok := pkgs[path]
+		if !ok {
+			p = &GoTestPackage{Path: path}
+			pkgs[path] = p
+			report.Packages = append(report.Packages, p)
+		}
+		return p
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var ev GoTestEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil {
+			continue
+		}
+
+		// 编译输出（Go 1.24+ 以 build-output/build-fail 事件报告）
+		if ev.Action == "build-output" || ev.Action == "build-fail" {
+			// ImportPath 形如 "pkg [pkg.test]"，归到对应的包下
+			path := ev.ImportPath
+			if i := strings.Index(path, " ["); i >= 0 {
+				path = path[:i]
+			}
+			p := getPkg(path)
+			if ev.Action == "build-fail" {
+				p.Status = "build-fail"
+			} else {
+				p.Output = append(p.Output, strings.TrimRight(ev.Output, "\n"))
+			}
+			continue
+		}
+		if ev.Package == "" {
+			continue
+		}
+
+		p := getPkg(ev.Package)
+		if ev.Test == "" {
+			switch ev.Action {
+			case "output":
+				text := strings.TrimRight(ev.Output, "\n")
+				if strings.HasPrefix(text, "?") && strings.Contains(text, "[no test files]") {
+					p.NoTestFiles = true
+				}
+				p.Output = append(p.Output, text)
+			case "pass", "fail", "skip":
+				if p.Status != "build-fail" {
+					p.Status = ev.Action
+				}
+				p.Elapsed = ev.Elapsed
+			}
+			continue
+		}
+
+		key := ev.Package + "\x00" + ev.Test
+		c, ok := cases[key]
+		if !ok {
+			c = &GoTestCase{Package: ev.Package, Name: ev.Test, Status: "run"}
+			cases[key] = c
+			report.Cases = append(report.Cases, c)
+		}
+		switch ev.Action {
+		case "output":
+			text := strings.TrimRight(ev.Output, "\n")
+			// 跳过 === RUN / --- PASS 之类的框架行
+			trimmed := strings.TrimSpace(text)
+			if strings.HasPrefix(trimmed, "=== ") {
+				continue
+			}
+			c.Output = append(c.Output, text)
+			// 取最后一个位置（前面的多是 t.Log）
+			if m := testLocationRe.FindStringSubmatch(text); m != nil {
+				c.Location = m[1] + ":" + m[2]
+			}
+		case "pass", "fail", "skip":
+			c.Status = ev.Action
+			c.Elapsed = ev.Elapsed
+		}
+	}
+
+	for _, c := range report.Cases {
+		p := pkgs[c.Package]
+		switch c.Status {
+		case "pass":
+			p.Passed++
+		case "fail":
+			p.Failed++
+		case "skip":
+			p.Skipped++
+		}
+	}
+	return report
+}
+
+// formatTestReport 格式化测试结果
+func formatTestReport(report *GoTestReport, command string, duration time.Duration, stderr string) string {
+	var passed, failed, skipped int
+	var failedCases, slowCases []*GoTestCase
+	for _, c := range report.Cases {
+		switch c.Status {
+		case "pass":
+			passed++
+			slowCases = append(slowCases, c)
+		case "fail", "run":
+			failed++
+			failedCases = append(failedCases, c)
+		case "skip":
+			skipped++
+		}
+	}
+
+	var failedPkgs, okPkgs, emptyPkgs int
+	for _, p := range report.Packages {
+		switch {
+		case p.Status == "fail" || p.Status == "build-fail":
+			failedPkgs++
+		case p.NoTestFiles:
+			emptyPkgs++
+		default:
+			okPkgs++
+		}
+	}
+
+	var sb strings.Builder
+	icon := "[✓]"
+	if failed > 0 || failedPkgs > 0 {
+		icon = "[✗]"
+	}
+	sb.WriteString(fmt.Sprintf("%s 测试完成: %d 通过, %d 失败, %d 跳过（包: %d 通过, %d 失败, %d 无测试文件），耗时 %.1fs\n",
+		icon, passed, failed, skipped, okPkgs, failedPkgs, emptyPkgs, duration.Seconds()))
+	sb.WriteString(fmt.Sprintf("命令: %s\n", command))
+
+	// 包级结果（跳过无测试文件的包）
+	sb.WriteString("\n包:\n")
+	shown := 0
+	for _, p := range report.Packages {
+		if p.NoTestFiles {
+			continue
+		}
+		if shown >= MaxTestPackagesShown {
+			sb.WriteString("  ...\n")
+			break
+		}
+		shown++
+		status := map[string]string{"pass": "ok  ", "fail": "FAIL", "skip": "skip", "build-fail": "编译失败"}[p.Status]
+		if status == "" {
+			status = "?   "
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s (%.2fs) 通过%d 失败%d 跳过%d\n", status, p.Path, p.Elapsed, p.Passed, p.Failed, p.Skipped))
+	}
+
+	// 编译失败或包级失败（panic、TestMain失败）的输出
+	for _, p := range report.Packages {
+		if p.Status != "build-fail" && !(p.Status == "fail" && p.Failed == 0) {
+			continue
+		}
+		lines := packageProblemLines(p)
+		if len(lines) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n[✗] %s 输出:\n```\n%s\n```\n", p.Path, truncateOutputLines(strings.Join(lines, "\n"), MaxTestBuildOutput)))
+	}
+
+	// 失败用例
+	if len(failedCases) > 0 {
+		sb.WriteString("\n失败用例:\n")
+		for i, c := range failedCases {
+			if i >= MaxTestFailuresShown {
+				sb.WriteString(fmt.Sprintf("... 还有 %d 个失败用例未显示\n", len(failedCases)-i))
+				break
+			}
+			loc := ""
+			if c.Location != "" {
+				loc = "  @ " + c.Location
+			}
+			status := "FAIL"
+			if c.Status == "run" {
+				status = "未结束（可能超时或panic）"
+			}
+			sb.WriteString(fmt.Sprintf("\n--- %s: %s [%s] (%.2fs)%s\n", status, c.Name, c.Package, c.Elapsed, loc))
+			if lines := failureLines(c.Output); len(lines) > 0 {
+				sb.WriteString("```\n" + truncateOutputLines(strings.Join(lines, "\n"), MaxTestOutputPerCase) + "\n```\n")
+			}
+		}
+		sb.WriteString("\n提示: 修复后用 rerun_failed:true 只重跑失败的用例\n")
+	}
+
+	// 最慢的用例
+	if len(slowCases) > 0 && failed == 0 {
+		sort.Slice(slowCases, func(i, j int) bool { return slowCases[i].Elapsed > slowCases[j].Elapsed })
+		if slowCases[0].Elapsed >= 0.5 {
+			sb.WriteString("\n最慢的用例:\n")
+			for i, c := range slowCases {
+				if i >= MaxSlowTestsShown || c.Elapsed < 0.1 {
+					break
+				}
+				sb.WriteString(fmt.Sprintf("  %.2fs  %s [%s]\n", c.Elapsed, c.Name, c.Package))
+			}
+		}
+	}
+
+	// 旧版本Go的编译错误输出在stderr
+	if s := strings.TrimSpace(stderr); s != "" && (failed > 0 || failedPkgs > 0) {
+		sb.WriteString(fmt.Sprintf("\nstderr:\n```\n%s\n```\n", truncateOutputLines(s, MaxTestBuildOutput)))
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// packageProblemLines 包级输出中去掉 ok/FAIL 汇总行
+func packageProblemLines(p *GoTestPackage) []string {
+	var lines []string
+	for _, l := range p.Output {
+		t := strings.TrimSpace(l)
+		if t == "" || t == "FAIL" || t == "PASS" || strings.HasPrefix(t, "FAIL\t") || strings.HasPrefix(t, "ok  \t") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// failureLines 失败用例的输出（去掉 --- FAIL 行）
+func failureLines(output []string) []string {
+	var lines []string
+	for _, l := range output {
+		t := strings.TrimSpace(l)
+		if t == "" || strings.HasPrefix(t, "--- ") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// truncateOutputLines 按行截断输出
+func truncateOutputLines(s string, maxLines int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) <= maxLines {
+		return s
+	}
+	return strings.Join(lines[:maxLines], "\n") + fmt.Sprintf("\n... [省略 %d 行]", len(lines)-maxLines)
+}
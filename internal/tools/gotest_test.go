@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGoTestJSON(t *testing.T) {
+	type wantCase struct {
+		Name     string
+		Status   string
+		Location string
+	}
+	type wantPkg struct {
+		Path        string
+		Status      string
+		Passed      int
+		Failed      int
+		Skipped     int
+		NoTestFiles bool
+		Output      []string
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		cases []wantCase
+		pkgs  []wantPkg
+	}{
+		{
+			name: "pass fail skip",
+			input: `{"Action":"start","Package":"ex/a"}
+{"Action":"run","Package":"ex/a","Test":"TestOK"}
+{"Action":"output","Package":"ex/a","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"pass","Package":"ex/a","Test":"TestOK","Elapsed":0.01}
+{"Action":"run","Package":"ex/a","Test":"TestBad"}
+{"Action":"output","Package":"ex/a","Test":"TestBad","Output":"    a_test.go:12: got 1, want 2\n"}
+{"Action":"fail","Package":"ex/a","Test":"TestBad","Elapsed":0.02}
+{"Action":"run","Package":"ex/a","Test":"TestSkip"}
+{"Action":"skip","Package":"ex/a","Test":"TestSkip"}
+{"Action":"fail","Package":"ex/a","Elapsed":0.5}
+`,
+			cases: []wantCase{
+				{"TestOK", "pass", ""},
+				{"TestBad", "fail", "a_test.go:12"},
+				{"TestSkip", "skip", ""},
+			},
+			pkgs: []wantPkg{{Path: "ex/a", Status: "fail", Passed: 1, Failed: 1, Skipped: 1}},
+		},
+		{
+			// 测试中 panic 或超时被杀时，用例只有 run 和 output，没有自己的结束事件
+			name: "fail without end event",
+			input: `{"Action":"run","Package":"ex/a","Test":"TestPanic"}
+{"Action":"output","Package":"ex/a","Test":"TestPanic","Output":"=== RUN   TestPanic\n"}
+{"Action":"output","Package":"ex/a","Test":"TestPanic","Output":"panic: runtime error: index out of range [recovered]\n"}
+{"Action":"output","Package":"ex/a","Output":"FAIL\tex/a\t0.010s\n"}
+{"Action":"fail","Package":"ex/a","Elapsed":0.01}
+`,
+			cases: []wantCase{{"TestPanic", "run", ""}},
+			pkgs:  []wantPkg{{Path: "ex/a", Status: "fail", Output: []string{"FAIL\tex/a\t0.010s"}}},
+		},
+		{
+			// Go 1.24+ 的编译错误以 build-output/build-fail 报告，ImportPath 带 [pkg.test] 后缀
+			name: "go 1.24 build output",
+			input: `{"ImportPath":"ex/b [ex/b.test]","Action":"build-output","Output":"# ex/b [ex/b.test]\n"}
+{"ImportPath":"ex/b [ex/b.test]","Action":"build-output","Output":"b/b_test.go:5:2: undefined: missing\n"}
+{"ImportPath":"ex/b [ex/b.test]","Action":"build-fail"}
+{"Action":"start","Package":"ex/b"}
+{"Action":"output","Package":"ex/b","Output":"FAIL\tex/b [build failed]\n"}
+{"Action":"fail","Package":"ex/b","Elapsed":0,"FailedBuild":"ex/b [ex/b.test]"}
+`,
+			pkgs: []wantPkg{{
+				Path:   "ex/b",
+				Status: "build-fail",
+				Output: []string{"# ex/b [ex/b.test]", "b/b_test.go:5:2: undefined: missing", "FAIL\tex/b [build failed]"},
+			}},
+		},
+		{
+			name: "no test files and non-json lines",
+			input: `go: downloading example.com/x v1.0.0
+{"Action":"start","Package":"ex/c"}
+{"Action":"output","Package":"ex/c","Output":"?   \tex/c\t[no test files]\n"}
+{"Action":"skip","Package":"ex/c","Elapsed":0}
+`,
+			pkgs: []wantPkg{{Path: "ex/c", Status: "skip", NoTestFiles: true, Output: []string{"?   \tex/c\t[no test files]"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ParseGoTestJSON(tt.input)
+
+			var cases []wantCase
+			for _, c := range report.Cases {
+				cases = append(cases, wantCase{c.Name, c.Status, c.Location})
+			}
+			if !reflect.DeepEqual(cases, tt.cases) {
+				t.Errorf("cases = %+v, want %+v", cases, tt.cases)
+			}
+
+			var pkgs []wantPkg
+			for _, p := range report.Packages {
+				pkgs = append(pkgs, wantPkg{p.Path, p.Status, p.Passed, p.Failed, p.Skipped, p.NoTestFiles, p.Output})
+			}
+			if !reflect.DeepEqual(pkgs, tt.pkgs) {
+				t.Errorf("packages = %+v, want %+v", pkgs, tt.pkgs)
+			}
+		})
+	}
+}
+
+func TestFormatTestReportCountsUnfinishedAsFailed(t *testing.T) {
+	report := ParseGoTestJSON(`{"Action":"run","Package":"ex/a","Test":"TestPanic"}
+{"Action":"output","Package":"ex/a","Test":"TestPanic","Output":"panic: boom\n"}
+{"Action":"fail","Package":"ex/a","Elapsed":0.01}
+`)
+	out := formatTestReport(report, "go test ./...", time.Second, "")
+	if !strings.HasPrefix(out, "[✗] 测试完成: 0 通过, 1 失败") {
+		t.Errorf("unexpected summary:\n%s", out)
+	}
+	if !strings.Contains(out, "TestPanic") || !strings.Contains(out, "panic: boom") {
+		t.Errorf("unfinished test and its output should be listed:\n%s", out)
+	}
+}
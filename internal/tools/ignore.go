@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultSkipDirs 默认跳过的目录（版本库、依赖、缓存）
+var defaultSkipDirs = []string{".git", ".svn", ".hg", "node_modules", "vendor", "__pycache__", ".idea", ".vscode"}
+
+// ignoreRule 一条 .gitignore 规则
+type ignoreRule struct {
+	base    string         // 规则所在目录（相对遍历根目录，"" 表示根目录）
+	re      *regexp.Regexp // 编译后的匹配表达式
+	negate  bool           // ! 开头，重新包含
+	dirOnly bool           // / 结尾，只匹配目录
+	hasDir  bool           // 模式中含 /，按相对路径匹配而不是文件名
+}
+
+// ignoreMatcher .gitignore 匹配器（按目录层级叠加规则）
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// newIgnoreMatcher 创建匹配器并加载根目录的 .gitignore
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	return m.withDir(root, "")
+}
+
+// withDir 返回叠加了 dir/.gitignore 规则的新匹配器（rel 为 dir 相对根目录的路径）
+func (m *ignoreMatcher) withDir(dir, rel string) *ignoreMatcher {
+	rules := loadIgnoreFile(filepath.Join(dir, ".gitignore"), rel)
+	if len(rules) == 0 {
+		return m
+	}
+	merged := make([]ignoreRule, 0, len(m.rules)+len(rules))
+	merged = append(merged, m.rules...)
+	merged = append(merged, rules...)
+	return &ignoreMatcher{rules: merged}
+}
+
+// Match 判断相对路径（使用 / 分隔）是否被忽略，后出现的规则优先
+func (m *ignoreMatcher) Match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if !rule.hasDir {
+			target = path.Base(target)
+		}
+		if rule.re.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// loadIgnoreFile 解析 .gitignore 文件
+func loadIgnoreFile(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine 解析单行规则（跳过空行和注释）
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasPrefix(line, "\\") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.hasDir = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp 把 glob（支持 **、*、?、[...]）转换为正则表达式
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" 匹配零个或多个目录
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// matchGlob 匹配文件名或相对路径（模式含 / 时按路径匹配）
+func matchGlob(pattern, rel string) bool {
+	target := rel
+	if !strings.Contains(pattern, "/") {
+		target = path.Base(rel)
+	}
+	re, err := regexp.Compile("^" + globToRegexp(strings.TrimPrefix(pattern, "/")) + "$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(target)
+}
+
+// matchAnyGlob 是否匹配任意一个模式
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSkippedDir 是否为默认跳过的目录
+func isSkippedDir(name string) bool {
+	for _, d := range defaultSkipDirs {
+		if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPatterns 解析逗号分隔的模式列表（也接受JSON数组）
+func splitPatterns(v interface{}) []string {
+	var patterns []string
+	switch val := v.(type) {
+	case string:
+		for _, p := range strings.Split(val, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				patterns = append(patterns, p)
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				patterns = append(patterns, strings.TrimSpace(s))
+			}
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"ai_assistant/internal/mcp"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// MaxMCPOutput MCP 工具结果返回给模型的最大字节数
+const MaxMCPOutput = 32 * 1024
+
+// 启动时连接的 MCP 服务器（未配置时为nil）
+var mcpManager *mcp.Manager
+
+// SetMCPManager 设置 MCP 管理器，其工具随后出现在 GetToolsSimplified 中
+func SetMCPManager(m *mcp.Manager) {
+	mcpManager = m
+}
+
+// mcpToolDefinitions 已连接 MCP 服务器的工具定义（与自定义工具重名的不提供）
+func mcpToolDefinitions() []openai.Tool {
+	var defs []openai.Tool
+	for _, ref := range mcpManager.Tools() {
+		if findCustomTool(ref.Name) != nil {
+			continue
+		}
+		params := ref.Tool.InputSchema
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		defs = append(defs, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        ref.Name,
+				Description: fmt.Sprintf("[MCP:%s] %s", ref.Server, ref.Tool.Description),
+				Parameters:  params,
+			},
+		})
+	}
+	return defs
+}
+
+// ExecuteMCPTool 调用 MCP 服务器上的工具
+func ExecuteMCPTool(ref mcp.ToolRef, args map[string]interface{}) string {
+	result, err := mcpManager.Call(ref, args)
+	if err != nil {
+		return fmt.Sprintf("[✗] MCP %s/%s 调用失败: %v", ref.Server, ref.Tool.Name, err)
+	}
+
+	output := truncateBytes(formatMCPContent(result), MaxMCPOutput)
+	if result.IsError {
+		return fmt.Sprintf("[✗] MCP %s/%s 返回错误:\n%s", ref.Server, ref.Tool.Name, output)
+	}
+	return fmt.Sprintf("[✓] MCP %s/%s:\n%s", ref.Server, ref.Tool.Name, output)
+}
+
+// formatMCPContent 把结果内容转为文本，图片等二进制内容只给摘要
+func formatMCPContent(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range result.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s %s，%s]", c.Type, c.MimeType, formatFileSize(int64(base64.StdEncoding.DecodedLen(len(c.Data))))))
+		case "resource":
+			if c.Resource == nil {
+				continue
+			}
+			if c.Resource.Text != "" {
+				parts = append(parts, fmt.Sprintf("[资源 %s]\n%s", c.Resource.URI, c.Resource.Text))
+			} else {
+				parts = append(parts, fmt.Sprintf("[资源 %s %s]", c.Resource.URI, c.Resource.MimeType))
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[链接 %s %s]", c.Name, c.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[未知内容类型 %s]", c.Type))
+		}
+	}
+
+	// 只有结构化结果时直接给JSON
+	if len(parts) == 0 && len(result.StructuredContent) > 0 {
+		return string(result.StructuredContent)
+	}
+	if len(parts) == 0 {
+		return "（没有输出）"
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"ai_assistant/internal/memory"
+	"ai_assistant/internal/state"
+)
+
+// MaxRecallResults recall 最多返回的条数
+const MaxRecallResults = 50
+
+// ExecuteMemory 长期记忆：remember/recall/forget
+func ExecuteMemory(args map[string]interface{}, mm *memory.Manager, sm *state.Manager) string {
+	action, _ := args["action"].(string)
+	targetMachine := resolveMachine(args, sm)
+
+	switch action {
+	case "remember":
+		content, _ := args["content"].(string)
+		project, _ := args["project"].(string)
+		scope, _ := args["scope"].(string)
+		if scope == "" {
+			scope = memory.ScopeMachine
+			if project != "" {
+				scope = memory.ScopeProject
+			}
+		}
+		if scope == memory.ScopeProject && project == "" {
+			// 默认记在目标机器的当前目录上
+			project = machineWorkDir(sm, targetMachine)
+		}
+
+		mem, err := mm.Add(scope, targetMachine, project, content)
+		if err != nil {
+			return fmt.Sprintf("[✗] 记住失败: %v", err)
+		}
+		return fmt.Sprintf("[✓] 已记住 %s（以后的会话会自动带上）", mem.String())
+
+	case "recall":
+		query, _ := args["query"].(string)
+		var found []memory.Memory
+		if strings.TrimSpace(query) == "" {
+			// 不带关键词：列出目标机器（含其项目）和全局的记忆
+			found = mm.Search("", targetMachine)
+		} else {
+			found = mm.Search(query, "")
+		}
+		if len(found) == 0 {
+			if query == "" {
+				return fmt.Sprintf("[i] 没有关于 %s 的记忆", targetMachine)
+			}
+			return fmt.Sprintf("[i] 没有匹配 \"%s\" 的记忆", query)
+		}
+		return formatMemoryList(found)
+
+	case "forget":
+		id := intArg(args, "id", 0)
+		if id <= 0 {
+			return "[✗] forget操作缺少id参数（先用 recall 查看编号）"
+		}
+		mem, err := mm.Remove(id)
+		if err != nil {
+			return fmt.Sprintf("[✗] %v", err)
+		}
+		return fmt.Sprintf("[✓] 已忘记 %s", mem.String())
+
+	default:
+		return fmt.Sprintf("[✗] 未知记忆操作: %s（可选 remember、recall、forget）", action)
+	}
+}
+
+// formatMemoryList 每条记忆一行
+func formatMemoryList(memories []memory.Memory) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[✓] 找到 %d 条记忆:\n", len(memories)))
+	for i, mem := range memories {
+		if i >= MaxRecallResults {
+			sb.WriteString(fmt.Sprintf("... 还有 %d 条，换个关键词缩小范围\n", len(memories)-i))
+			break
+		}
+		sb.WriteString("  " + mem.String() + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,484 @@
+package tools
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ai_assistant/internal/state"
+)
+
+// 大纲限制
+const (
+	MaxOutlineSymbols = 500 // 最多显示的符号数
+	MaxOutlineDetail  = 160 // 签名最长字符数
+)
+
+// OutlineSymbol 文件中的一个声明
+type OutlineSymbol struct {
+	Kind   string // func/method/type/const/var/import/class/...
+	Name   string
+	Detail string // 签名或声明的首行
+	Start  int    // 起始行（含文档注释）
+	End    int
+	Depth  int // 嵌套层级（类中的方法为1）
+}
+
+// ExecuteFileOutline 列出文件中的声明及行号范围（支持远程）
+func ExecuteFileOutline(args map[string]interface{}, sm *state.Manager) string {
+	file := args["file"].(string)
+
+	targetMachine, _ := args["_target_machine"].(string)
+	if targetMachine == "" {
+		targetMachine = "local"
+	}
+
+	content, err := readFileContent(file, targetMachine, sm)
+	if err != nil {
+		return fmt.Sprintf("[✗] 读取失败: %v", err)
+	}
+	if int64(len(content)) > MaxFileSize {
+		return fmt.Sprintf("[✗] 文件过大: %s (%s)", file, formatSize(int64(len(content))))
+	}
+	if IsBinaryFile(content) {
+		return fmt.Sprintf("[✗] 二进制文件无法生成大纲: %s", file)
+	}
+
+	text, _ := DecodeText(content)
+	symbols, lang, note := FileOutline(file, text)
+	totalLines := strings.Count(text, "\n") + 1
+
+	machineInfo := ""
+	if targetMachine != "local" {
+		machineInfo = fmt.Sprintf(" (机器: %s)", targetMachine)
+	}
+	if len(symbols) == 0 {
+		return fmt.Sprintf("[大纲] %s%s (%s, %d 行): 没有识别到声明%s", file, machineInfo, lang, totalLines, note)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[大纲] %s%s (%s, %d 行, %d 个符号)%s\n", file, machineInfo, lang, totalLines, len(symbols), note))
+	width := len(fmt.Sprint(totalLines))
+	for i, s := range symbols {
+		if i >= MaxOutlineSymbols {
+			sb.WriteString(fmt.Sprintf("[!] 只显示前 %d 个符号\n", MaxOutlineSymbols))
+			break
+		}
+		lines := fmt.Sprintf("%*d-%-*d", width, s.Start, width, s.End)
+		sb.WriteString(fmt.Sprintf("  %s  %s%s\n", lines, strings.Repeat("  ", s.Depth), s.Detail))
+	}
+	sb.WriteString("提示: 用 read 的 start_line/end_line 读取需要的部分")
+	return sb.String()
+}
+
+// FileOutline 按语言生成大纲，返回符号、语言名和附加说明
+func FileOutline(file, content string) ([]OutlineSymbol, string, string) {
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".go" {
+		symbols, err := goOutline(content)
+		if err == nil {
+			return symbols, "Go", ""
+		}
+		// 语法错误时退回到启发式解析
+		return heuristicOutline(content, outlineLanguages[".go"]), "Go", fmt.Sprintf("\n[!] 解析失败，使用启发式结果: %v", err)
+	}
+
+	lang, ok := outlineLanguages[ext]
+	if !ok {
+		return nil, "未知语言", "（只支持Go和常见语言的启发式解析）"
+	}
+	return heuristicOutline(content, lang), lang.Name, ""
+}
+
+// goOutline 用 go/ast 解析Go文件
+func goOutline(content string) ([]OutlineSymbol, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+	start := func(node ast.Node, doc *ast.CommentGroup) int {
+		if doc != nil {
+			return line(doc.Pos())
+		}
+		return line(node.Pos())
+	}
+
+	var symbols []OutlineSymbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			sym := OutlineSymbol{Kind: "func", Name: d.Name.Name, Start: start(d, d.Doc), End: line(d.End())}
+			sig := strings.TrimPrefix(types.ExprString(d.Type), "func")
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := types.ExprString(d.Recv.List[0].Type)
+				sym.Kind = "method"
+				sym.Name = fmt.Sprintf("(%s).%s", recv, d.Name.Name)
+				recvName := ""
+				if len(d.Recv.List[0].Names) > 0 {
+					recvName = d.Recv.List[0].Names[0].Name + " "
+				}
+				sym.Detail = fmt.Sprintf("func (%s%s) %s%s", recvName, recv, d.Name.Name, sig)
+			} else {
+				sym.Detail = "func " + d.Name.Name + sig
+			}
+			symbols = append(symbols, sym)
+
+		case *ast.GenDecl:
+			kind := d.Tok.String()
+			if d.Tok == token.IMPORT {
+				symbols = append(symbols, OutlineSymbol{
+					Kind: "import", Name: "import", Detail: fmt.Sprintf("import (%d 个包)", len(d.Specs)),
+					Start: start(d, d.Doc), End: line(d.End()),
+				})
+				continue
+			}
+
+			// 分组的 const/var 合并为一条；type 分组内的每个类型单独列出
+			if d.Tok != token.TYPE && d.Lparen.IsValid() {
+				var names []string
+				for _, spec := range d.Specs {
+					for _, n := range spec.(*ast.ValueSpec).Names {
+						names = append(names, n.Name)
+					}
+				}
+				symbols = append(symbols, OutlineSymbol{
+					Kind: kind, Name: strings.Join(names, ","), Detail: fmt.Sprintf("%s (%s)", kind, summarizeNames(names)),
+					Start: start(d, d.Doc), End: line(d.End()),
+				})
+				continue
+			}
+
+			for _, spec := range d.Specs {
+				var sym OutlineSymbol
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					sym = OutlineSymbol{Kind: "type", Name: s.Name.Name, Detail: "type " + s.Name.Name + " " + typeKind(s.Type)}
+					sym.Start, sym.End = start(s, s.Doc), line(s.End())
+				case *ast.ValueSpec:
+					var names []string
+					for _, n := range s.Names {
+						names = append(names, n.Name)
+					}
+					sym = OutlineSymbol{Kind: kind, Name: strings.Join(names, ","), Detail: kind + " " + summarizeNames(names)}
+					if s.Type != nil {
+						sym.Detail += " " + types.ExprString(s.Type)
+					}
+					sym.Start, sym.End = start(s, s.Doc), line(s.End())
+				}
+				// 不带括号的单个声明，范围和文档注释以 GenDecl 为准
+				if !d.Lparen.IsValid() {
+					sym.Start, sym.End = start(d, d.Doc), line(d.End())
+				}
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+
+	for i := range symbols {
+		symbols[i].Detail = truncateDetail(symbols[i].Detail)
+	}
+	return symbols, nil
+}
+
+// typeKind 类型声明的简短描述
+func typeKind(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return fmt.Sprintf("struct (%d 个字段)", t.Fields.NumFields())
+	case *ast.InterfaceType:
+		return fmt.Sprintf("interface (%d 个方法)", t.Methods.NumFields())
+	default:
+		return types.ExprString(expr)
+	}
+}
+
+// summarizeNames 名称过多时只显示前几个
+func summarizeNames(names []string) string {
+	const maxNames = 6
+	if len(names) > maxNames {
+		return strings.Join(names[:maxNames], ", ") + fmt.Sprintf(", ... 共%d个", len(names))
+	}
+	return strings.Join(names, ", ")
+}
+
+// truncateDetail 截断过长的签名
+func truncateDetail(s string) string {
+	runes := []rune(s)
+	if len(runes) > MaxOutlineDetail {
+		return string(runes[:MaxOutlineDetail]) + "..."
+	}
+	return s
+}
+
+// outlinePattern 一条声明匹配规则（name 为名称所在的分组）
+type outlinePattern struct {
+	Kind string
+	Re   *regexp.Regexp
+	Name int
+}
+
+// outlineLanguage 启发式解析规则
+type outlineLanguage struct {
+	Name     string
+	Patterns []outlinePattern
+	EndMode  string // brace: 花括号配对；indent: 缩进（Python）；end: 同缩进的end（Ruby/Lua）；heading: Markdown标题
+	// 花括号配对时的字符串引号（默认只有双引号）；CharLiterals 表示 '{' 这类单引号是字符字面量
+	Quotes       string
+	CharLiterals bool
+}
+
+func outlinePat(kind, re string, name int) outlinePattern {
+	return outlinePattern{Kind: kind, Re: regexp.MustCompile(re), Name: name}
+}
+
+var (
+	jsPatterns = []outlinePattern{
+		outlinePat("class", `^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`, 1),
+		outlinePat("interface", `^\s*(?:export\s+)?(?:interface|type|enum)\s+([A-Za-z_$][\w$]*)`, 1),
+		outlinePat("function", `^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`, 1),
+		outlinePat("function", `^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function|\([^)]*\)\s*(?::\s*[^=]+)?=>|[A-Za-z_$][\w$]*\s*=>)`, 1),
+		outlinePat("method", `^\s+(?:(?:public|private|protected|static|readonly|async|override|get|set)\s+)*([A-Za-z_$][\w$]*)\s*(?:<[^>]*>)?\s*\([^;]*\)\s*(?::\s*[^{;]+)?\{\s*$`, 1),
+	}
+
+	cLikePatterns = []outlinePattern{
+		outlinePat("class", `^\s*(?:(?:public|private|protected|internal|static|abstract|final|sealed|partial|export|data|open)\s+)*(?:class|interface|enum|struct|record|object|namespace)\s+([A-Za-z_]\w*)`, 1),
+		outlinePat("func", `^\s*(?:(?:public|private|protected|internal|static|virtual|override|abstract|final|inline|extern|const|async|synchronized|unsafe|suspend|fun)\s+)*[\w:<>,\[\]\*&\s]*?\b([A-Za-z_~]\w*)\s*\([^;]*\)\s*(?:const\s*)?(?:noexcept\s*)?(?:throws\s+[\w.,\s]+)?(?::\s*[^{;]+)?\{?\s*$`, 1),
+	}
+
+	outlineLanguages = map[string]outlineLanguage{
+		".go": {Name: "Go", EndMode: "brace", Quotes: "\"`", CharLiterals: true, Patterns: []outlinePattern{
+			outlinePat("func", `^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`, 1),
+			outlinePat("type", `^type\s+([A-Za-z_]\w*)`, 1),
+		}},
+		".py": {Name: "Python", EndMode: "indent", Patterns: []outlinePattern{
+			outlinePat("class", `^\s*class\s+([A-Za-z_]\w*)`, 1),
+			outlinePat("def", `^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`, 1),
+		}},
+		".rb": {Name: "Ruby", EndMode: "end", Patterns: []outlinePattern{
+			outlinePat("class", `^\s*(?:class|module)\s+([A-Z][\w:]*)`, 1),
+			outlinePat("def", `^\s*def\s+(?:self\.)?([\w?!=]+)`, 1),
+		}},
+		".lua": {Name: "Lua", EndMode: "end", Patterns: []outlinePattern{
+			outlinePat("function", `^\s*(?:local\s+)?function\s+([\w.:]+)`, 1),
+		}},
+		".rs": {Name: "Rust", EndMode: "brace", CharLiterals: true, Patterns: []outlinePattern{
+			outlinePat("type", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|union|type)\s+([A-Za-z_]\w*)`, 1),
+			outlinePat("impl", `^\s*(impl(?:<[^>]*>)?\s+[^{]+?)\s*\{`, 1),
+			outlinePat("mod", `^\s*(?:pub\s+)?mod\s+([A-Za-z_]\w*)\s*\{`, 1),
+			outlinePat("fn", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+([A-Za-z_]\w*)`, 1),
+		}},
+		".sh":   {Name: "Shell", EndMode: "brace", Quotes: "\"'", Patterns: []outlinePattern{outlinePat("function", `^\s*(?:function\s+)?([A-Za-z_][\w-]*)\s*\(\)\s*\{?`, 1), outlinePat("function", `^\s*function\s+([A-Za-z_][\w-]*)`, 1)}},
+		".bash": {Name: "Shell", EndMode: "brace", Quotes: "\"'", Patterns: []outlinePattern{outlinePat("function", `^\s*(?:function\s+)?([A-Za-z_][\w-]*)\s*\(\)\s*\{?`, 1), outlinePat("function", `^\s*function\s+([A-Za-z_][\w-]*)`, 1)}},
+		".js":   {Name: "JavaScript", EndMode: "brace", Quotes: "\"'`", Patterns: jsPatterns},
+		".jsx":  {Name: "JavaScript", EndMode: "brace", Quotes: "\"'`", Patterns: jsPatterns},
+		".mjs":  {Name: "JavaScript", EndMode: "brace", Quotes: "\"'`", Patterns: jsPatterns},
+		".ts":   {Name: "TypeScript", EndMode: "brace", Quotes: "\"'`", Patterns: jsPatterns},
+		".tsx":  {Name: "TypeScript", EndMode: "brace", Quotes: "\"'`", Patterns: jsPatterns},
+		".java": {Name: "Java", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".kt":   {Name: "Kotlin", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".cs":   {Name: "C#", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".c":    {Name: "C", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".h":    {Name: "C", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".cpp":  {Name: "C++", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".cc":   {Name: "C++", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".hpp":  {Name: "C++", EndMode: "brace", CharLiterals: true, Patterns: cLikePatterns},
+		".php":  {Name: "PHP", EndMode: "brace", Quotes: "\"'", Patterns: cLikePatterns},
+		".md":   {Name: "Markdown", EndMode: "heading", Patterns: []outlinePattern{outlinePat("heading", `^(#{1,6})\s+(.+?)\s*#*\s*$`, 2)}},
+	}
+
+	// 控制流关键字，避免被 func 规则误认为函数
+	outlineKeywords = map[string]bool{
+		"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+		"else": true, "do": true, "try": true, "foreach": true, "using": true, "lock": true,
+		"sizeof": true, "new": true, "function": true, "elif": true, "when": true,
+	}
+)
+
+// heuristicOutline 用正则识别声明，再按语言规则确定结束行
+func heuristicOutline(content string, lang outlineLanguage) []OutlineSymbol {
+	lines := strings.Split(content, "\n")
+	var symbols []OutlineSymbol
+
+	inFence := false
+	for i, text := range lines {
+		trimmed := strings.TrimSpace(text)
+		if lang.EndMode == "heading" && strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "*") ||
+			(strings.HasPrefix(trimmed, "#") && lang.EndMode != "heading") {
+			continue
+		}
+		for _, p := range lang.Patterns {
+			m := p.Re.FindStringSubmatch(text)
+			if m == nil || outlineKeywords[m[p.Name]] {
+				continue
+			}
+			sym := OutlineSymbol{Kind: p.Kind, Name: m[p.Name], Detail: truncateDetail(trimmed), Start: i + 1}
+			switch lang.EndMode {
+			case "brace":
+				sym.End = braceBlockEnd(lines, i, lang)
+			case "indent":
+				sym.End = indentBlockEnd(lines, i)
+			case "end":
+				sym.End = keywordBlockEnd(lines, i)
+			case "heading":
+				sym.Depth = len(m[1]) - 1
+				sym.End = headingBlockEnd(lines, i, len(m[1]))
+			}
+			symbols = append(symbols, sym)
+			break
+		}
+	}
+
+	// 除Markdown外，按行范围包含关系计算嵌套层级
+	if lang.EndMode != "heading" {
+		sort.SliceStable(symbols, func(i, j int) bool { return symbols[i].Start < symbols[j].Start })
+		var stack []int
+		for i := range symbols {
+			for len(stack) > 0 && symbols[stack[len(stack)-1]].End < symbols[i].Start {
+				stack = stack[:len(stack)-1]
+			}
+			symbols[i].Depth = len(stack)
+			if symbols[i].End > symbols[i].Start {
+				stack = append(stack, i)
+			}
+		}
+	}
+	return symbols
+}
+
+// braceBlockEnd 从声明行开始配对花括号（忽略字符串、字符字面量和行注释中的括号）
+func braceBlockEnd(lines []string, start int, lang outlineLanguage) int {
+	quotes := lang.Quotes
+	if quotes == "" {
+		quotes = `"`
+	}
+	depth := 0
+	opened := false
+	for i := start; i < len(lines); i++ {
+		line := []rune(lines[i])
+		inString := rune(0)
+	scan:
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			switch {
+			case inString != 0:
+				if c == '\\' {
+					j++
+				} else if c == inString {
+					inString = 0
+				}
+			case strings.ContainsRune(quotes, c):
+				inString = c
+			case c == '\'' && lang.CharLiterals:
+				// 'x'、'\n'、'\u{1F600}' 是字符字面量；Rust 的生命周期 'a 没有闭合的引号，按普通字符处理
+				j = charLiteralEnd(line, j)
+			case c == '/' && j+1 < len(line) && line[j+1] == '/':
+				break scan
+			case c == '{':
+				depth++
+				opened = true
+			case c == '}':
+				depth--
+			}
+		}
+		if opened && depth <= 0 {
+			return i + 1
+		}
+		// 声明在几行内都没有出现 {，视为只有声明（如函数原型）
+		if !opened && (strings.HasSuffix(strings.TrimSpace(lines[i]), ";") || i-start >= 5) {
+			return start + 1
+		}
+	}
+	return len(lines)
+}
+
+// charLiteralEnd line[j] 是单引号时，返回字符字面量结束引号的位置；不是字符字面量时返回 j
+func charLiteralEnd(line []rune, j int) int {
+	if j+2 < len(line) && line[j+1] != '\\' && line[j+2] == '\'' {
+		return j + 2
+	}
+	if j+1 < len(line) && line[j+1] == '\\' {
+		for k := j + 3; k < len(line) && k <= j+12; k++ {
+			if line[k] == '\'' {
+				return k
+			}
+		}
+	}
+	return j
+}
+
+// indentBlockEnd 缩进块的结束行（Python）
+func indentBlockEnd(lines []string, start int) int {
+	base := indentWidth(lines[start])
+	end := start
+	for i := start + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentWidth(lines[i]) <= base {
+			break
+		}
+		end = i
+	}
+	return end + 1
+}
+
+// keywordBlockEnd 找到同缩进的 end（Ruby/Lua）
+func keywordBlockEnd(lines []string, start int) int {
+	base := indentWidth(lines[start])
+	for i := start + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if indentWidth(lines[i]) == base && (trimmed == "end" || strings.HasPrefix(trimmed, "end ") || strings.HasPrefix(trimmed, "end)")) {
+			return i + 1
+		}
+	}
+	return start + 1
+}
+
+// headingBlockEnd Markdown标题到下一个同级或更高级标题之前
+func headingBlockEnd(lines []string, start, level int) int {
+	inFence := false
+	for i := start + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		n := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		if n <= level && n <= 6 && len(trimmed) > n && trimmed[n] == ' ' {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// indentWidth 行首缩进宽度（tab按4个空格）
+func indentWidth(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"ai_assistant/internal/state"
+)
+
+// machineWorkDir 目标机器的当前目录；寄生虫还没执行过命令时先问一次
+func machineWorkDir(sm *state.Manager, targetMachine string) string {
+	dir := sm.WorkDir(targetMachine)
+	if dir == "" && targetMachine != "local" {
+		// ExecuteOnAgent 会顺带更新机器的当前目录
+		if _, err := sm.ExecuteOnAgent(targetMachine, "pwd"); err == nil {
+			dir = sm.WorkDir(targetMachine)
+		}
+	}
+	return dir
+}
+
+// resolvePath 相对路径按目标机器的当前目录（run_command 的持久Shell所在目录）解析为绝对路径
+func resolvePath(p, targetMachine string, sm *state.Manager) string {
+	if p == "" {
+		return p
+	}
+	if targetMachine == "local" {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		if dir := machineWorkDir(sm, targetMachine); dir != "" {
+			return filepath.Join(dir, p)
+		}
+		return p
+	}
+
+	// 远程按Unix路径处理，~ 交给寄生虫的Shell展开
+	if strings.HasPrefix(p, "/") || strings.HasPrefix(p, "~") {
+		return p
+	}
+	if dir := machineWorkDir(sm, targetMachine); dir != "" {
+		return path.Join(dir, p)
+	}
+	return p
+}
+
+// resolvePathArgs 把 file_operation 参数中的路径解析为绝对路径，结果里显示的也是解析后的路径
+func resolvePathArgs(args map[string]interface{}, targetMachine string, sm *state.Manager) {
+	if file, ok := args["file"].(string); ok && file != "" {
+		args["file"] = resolvePath(file, targetMachine, sm)
+	}
+	if files := splitPatterns(args["files"]); len(files) > 0 {
+		resolved := make([]interface{}, len(files))
+		for i, f := range files {
+			resolved[i] = resolvePath(f, targetMachine, sm)
+		}
+		args["files"] = resolved
+	}
+
+	// search/list 不指定 path 时：本地用持久Shell的目录，寄生虫自己就按Shell目录解析
+	p, _ := args["path"].(string)
+	if p != "" {
+		args["path"] = resolvePath(p, targetMachine, sm)
+	} else if targetMachine == "local" {
+		args["path"] = machineWorkDir(sm, targetMachine)
+	}
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"ai_assistant/internal/plan"
+	"ai_assistant/internal/session"
+)
+
+// ExecutePlan 维护当前会话的任务计划：create/add/update/show/clear
+func ExecutePlan(args map[string]interface{}, sessm *session.Manager) string {
+	action, _ := args["action"].(string)
+	current := sessm.GetCurrentSession()
+	if current == nil {
+		return "[✗] 没有活动会话"
+	}
+
+	p := current.Plan
+	switch action {
+	case "create":
+		goal, _ := args["goal"].(string)
+		steps := planStepTitles(args["steps"])
+		if strings.TrimSpace(goal) == "" {
+			return "[✗] create操作缺少goal参数"
+		}
+		if len(steps) == 0 {
+			return "[✗] create操作缺少steps参数（步骤标题列表）"
+		}
+		p = plan.New(goal, steps)
+
+	case "add":
+		if p == nil {
+			return "[✗] 还没有计划，先用 create 创建"
+		}
+		if p.Add(planStepTitles(args["steps"])) == 0 {
+			return "[✗] add操作缺少steps参数"
+		}
+
+	case "update":
+		if p == nil {
+			return "[✗] 还没有计划，先用 create 创建"
+		}
+		id := intArg(args, "id", 0)
+		step := p.Step(id)
+		if step == nil {
+			return fmt.Sprintf("[✗] 没有第 %d 步（现有 1-%d）", id, len(p.Steps))
+		}
+		status, _ := args["status"].(string)
+		if status != "" {
+			if !plan.ValidStatus(status) {
+				return fmt.Sprintf("[✗] 无效状态: %s（可选 pending、in_progress、done、skipped）", status)
+			}
+			step.Status = status
+		}
+		if title, ok := args["title"].(string); ok && strings.TrimSpace(title) != "" {
+			step.Title = strings.TrimSpace(title)
+		}
+		if note, ok := args["note"].(string); ok {
+			step.Note = strings.TrimSpace(note)
+		}
+
+	case "show", "":
+		if p == nil {
+			return "[i] 当前会话没有计划"
+		}
+		return "[✓] " + p.Render()
+
+	case "clear":
+		if p == nil {
+			return "[i] 当前会话没有计划"
+		}
+		if err := sessm.SavePlan(nil); err != nil {
+			return fmt.Sprintf("[✗] 保存计划失败: %v", err)
+		}
+		return "[✓] 计划已清除"
+
+	default:
+		return fmt.Sprintf("[✗] 未知计划操作: %s（可选 create、add、update、show、clear）", action)
+	}
+
+	if err := sessm.SavePlan(p); err != nil {
+		return fmt.Sprintf("[✗] 保存计划失败: %v", err)
+	}
+	result := "[✓] " + p.Render()
+	if p.Finished() {
+		result += "\n[i] 所有步骤都已完成，可以 clear 掉这个计划"
+	}
+	return result
+}
+
+// planStepTitles 步骤标题：JSON数组，或按行分隔的字符串
+func planStepTitles(v interface{}) []string {
+	var titles []string
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				titles = append(titles, s)
+			}
+		}
+	case string:
+		titles = strings.Split(val, "\n")
+	}
+	return titles
+}
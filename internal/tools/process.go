@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ai_assistant/internal/process"
+	"ai_assistant/internal/state"
+)
+
+// 后台进程输出限制
+const (
+	DefaultProcessReadLines = 100 // 每次默认读取的行数
+	MaxProcessReadLines     = 500 // 每次最多读取的行数
+	MaxProcessStartWait     = 10  // start后最多等待的秒数
+)
+
+// ExecuteProcess 后台进程管理（start/send_input/read_output/kill/list）
+func ExecuteProcess(args map[string]interface{}, pm *process.Manager, sm *state.Manager) string {
+	action, _ := args["action"].(string)
+
+	targetMachine := resolveMachine(args, sm)
+
+	processID, _ := args["process_id"].(string)
+	if action != "start" && action != "list" && processID == "" {
+		return fmt.Sprintf("[✗] %s操作缺少process_id参数", action)
+	}
+
+	if targetMachine != "local" {
+		return executeProcessOnAgent(action, processID, targetMachine, args, sm)
+	}
+
+	switch action {
+	case "start":
+		command, _ := args["command"].(string)
+		if command == "" {
+			return "[✗] start操作缺少command参数"
+		}
+		dir, _ := args["cwd"].(string)
+		if dir = resolvePath(dir, "local", sm); dir == "" {
+			dir = sm.WorkDir("local")
+		}
+		id, err := pm.StartProcessIn(command, dir)
+		if err != nil {
+			return fmt.Sprintf("[✗] 启动进程失败: %v", err)
+		}
+		waitForStartup(args)
+		lines, next, skipped, status, err := pm.ReadOutput(id, 0, processReadLimit(args))
+		if err != nil {
+			return fmt.Sprintf("[✗] 读取输出失败: %v", err)
+		}
+		return fmt.Sprintf("[✓] 后台进程已启动\n进程ID: %s (机器: local)\n命令: %s\n%s",
+			id, command, formatProcessOutput(lines, next, skipped, status))
+
+	case "send_input":
+		input, _ := args["input"].(string)
+		if !strings.HasSuffix(input, "\n") {
+			input += "\n"
+		}
+		if err := pm.SendInput(processID, input); err != nil {
+			return fmt.Sprintf("[✗] 发送输入失败: %v", err)
+		}
+		waitForStartup(args)
+		lines, next, skipped, status, err := pm.ReadOutput(processID, -1, processReadLimit(args))
+		if err != nil {
+			return fmt.Sprintf("[✗] 读取输出失败: %v", err)
+		}
+		return fmt.Sprintf("[✓] 已发送输入到进程 %s\n%s", processID, formatProcessOutput(lines, next, skipped, status))
+
+	case "read_output":
+		lines, next, skipped, status, err := pm.ReadOutput(processID, intArg(args, "offset", -1), processReadLimit(args))
+		if err != nil {
+			return fmt.Sprintf("[✗] 读取输出失败: %v", err)
+		}
+		return fmt.Sprintf("[进程] %s\n%s", processID, formatProcessOutput(lines, next, skipped, status))
+
+	case "kill":
+		if err := pm.KillProcess(processID); err != nil {
+			return fmt.Sprintf("[✗] 终止进程失败: %v", err)
+		}
+		return fmt.Sprintf("[✓] 进程已终止: %s", processID)
+
+	case "list":
+		return formatProcessList("local", pm.ListProcesses())
+
+	default:
+		return fmt.Sprintf("[✗] 未知进程操作: %s", action)
+	}
+}
+
+// executeProcessOnAgent 在寄生虫上管理后台进程
+func executeProcessOnAgent(action, processID, machineID string, args map[string]interface{}, sm *state.Manager) string {
+	switch action {
+	case "start":
+		command, _ := args["command"].(string)
+		if command == "" {
+			return "[✗] start操作缺少command参数"
+		}
+		data := map[string]interface{}{"command": command}
+		if dir, ok := args["cwd"].(string); ok && dir != "" {
+			data["cwd"] = dir
+		}
+		resp, err := sm.CallAgentAPI(machineID, "proc_start", data)
+		if err != nil {
+			return fmt.Sprintf("[✗] 启动进程失败: %v", err)
+		}
+		id, _ := resp["process_id"].(string)
+		waitForStartup(args)
+		output := readAgentProcessOutput(sm, machineID, id, 0, processReadLimit(args))
+		return fmt.Sprintf("[✓] 后台进程已启动\n进程ID: %s (机器: %s)\n命令: %s\n%s", id, machineID, command, output)
+
+	case "send_input":
+		input, _ := args["input"].(string)
+		if !strings.HasSuffix(input, "\n") {
+			input += "\n"
+		}
+		if _, err := sm.CallAgentAPI(machineID, "proc_input", map[string]interface{}{
+			"process_id": processID,
+			"input":      input,
+		}); err != nil {
+			return fmt.Sprintf("[✗] 发送输入失败: %v", err)
+		}
+		waitForStartup(args)
+		output := readAgentProcessOutput(sm, machineID, processID, -1, processReadLimit(args))
+		return fmt.Sprintf("[✓] 已发送输入到进程 %s\n%s", processID, output)
+
+	case "read_output":
+		output := readAgentProcessOutput(sm, machineID, processID, intArg(args, "offset", -1), processReadLimit(args))
+		return fmt.Sprintf("[进程] %s (机器: %s)\n%s", processID, machineID, output)
+
+	case "kill":
+		if _, err := sm.CallAgentAPI(machineID, "proc_kill", map[string]interface{}{"process_id": processID}); err != nil {
+			return fmt.Sprintf("[✗] 终止进程失败: %v", err)
+		}
+		return fmt.Sprintf("[✓] 进程已终止: %s (机器: %s)", processID, machineID)
+
+	case "list":
+		resp, err := sm.CallAgentAPI(machineID, "proc_list", map[string]interface{}{})
+		if err != nil {
+			return fmt.Sprintf("[✗] 获取进程列表失败: %v", err)
+		}
+		var list struct {
+			Processes []struct {
+				ID        string  `json:"process_id"`
+				Command   string  `json:"command"`
+				StartTime float64 `json:"start_time"`
+				Lines     int     `json:"lines"`
+				Done      bool    `json:"done"`
+				ExitCode  int     `json:"exit_code"`
+			} `json:"processes"`
+		}
+		if err := decodeAgentResponse(resp, &list); err != nil {
+			return fmt.Sprintf("[✗] 获取进程列表失败: %v", err)
+		}
+		summaries := make([]process.ProcessSummary, 0, len(list.Processes))
+		for _, p := range list.Processes {
+			summaries = append(summaries, process.ProcessSummary{
+				ID:        p.ID,
+				Command:   p.Command,
+				StartTime: time.Unix(int64(p.StartTime), 0),
+				Lines:     p.Lines,
+				Done:      p.Done,
+				ExitCode:  p.ExitCode,
+			})
+		}
+		return formatProcessList(machineID, summaries)
+
+	default:
+		return fmt.Sprintf("[✗] 未知进程操作: %s", action)
+	}
+}
+
+// readAgentProcessOutput 读取寄生虫上后台进程的输出并格式化
+func readAgentProcessOutput(sm *state.Manager, machineID, processID string, offset, maxLines int) string {
+	resp, err := sm.CallAgentAPI(machineID, "proc_output", map[string]interface{}{
+		"process_id": processID,
+		"offset":     offset,
+		"max_lines":  maxLines,
+	})
+	if err != nil {
+		return fmt.Sprintf("[✗] 读取输出失败: %v", err)
+	}
+
+	var out struct {
+		Lines    []string `json:"lines"`
+		Next     int      `json:"next"`
+		Skipped  int      `json:"skipped"`
+		Done     bool     `json:"done"`
+		ExitCode int      `json:"exit_code"`
+	}
+	if err := decodeAgentResponse(resp, &out); err != nil {
+		return fmt.Sprintf("[✗] 读取输出失败: %v", err)
+	}
+
+	status := "运行中"
+	if out.Done {
+		status = fmt.Sprintf("已退出(code=%d)", out.ExitCode)
+	}
+	return formatProcessOutput(out.Lines, out.Next, out.Skipped, status)
+}
+
+// waitForStartup 按wait参数等待进程产生输出（默认1秒）
+func waitForStartup(args map[string]interface{}) {
+	wait := intArg(args, "wait", 1)
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > MaxProcessStartWait {
+		wait = MaxProcessStartWait
+	}
+	time.Sleep(time.Duration(wait) * time.Second)
+}
+
+// processReadLimit 读取行数限制
+func processReadLimit(args map[string]interface{}) int {
+	limit := intArg(args, "max_lines", DefaultProcessReadLines)
+	if limit <= 0 || limit > MaxProcessReadLines {
+		limit = MaxProcessReadLines
+	}
+	return limit
+}
+
+// formatProcessOutput 格式化增量输出
+func formatProcessOutput(lines []string, next, skipped int, status string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("状态: %s\n", status))
+	if skipped > 0 {
+		sb.WriteString(fmt.Sprintf("[!] 有 %d 行旧输出已超出缓冲被丢弃\n", skipped))
+	}
+	if len(lines) == 0 {
+		sb.WriteString("[暂无新输出]\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("输出（第 %d-%d 行）:\n```\n%s\n```\n", next-len(lines)+1, next, strings.Join(lines, "\n")))
+	}
+	sb.WriteString(fmt.Sprintf("下次读取 offset: %d（不传offset则自动从这里继续）", next))
+	return sb.String()
+}
+
+// formatProcessList 格式化进程列表
+func formatProcessList(machineID string, processes []process.ProcessSummary) string {
+	if len(processes) == 0 {
+		return fmt.Sprintf("[i] 没有后台进程 (机器: %s)", machineID)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[进程] 后台进程列表 (机器: %s):\n", machineID))
+	for _, p := range processes {
+		status := "● 运行中"
+		if p.Done {
+			status = fmt.Sprintf("○ 已退出(code=%d)", p.ExitCode)
+		}
+		sb.WriteString(fmt.Sprintf("  %s  %s  启动于 %s  输出 %d 行\n    $ %s\n",
+			p.ID, status, p.StartTime.Format("15:04:05"), p.Lines, p.Command))
+	}
+	return sb.String()
+}
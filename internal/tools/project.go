@@ -2,19 +2,49 @@ package tools
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 
 	"ai_assistant/internal/state"
 )
 
-// ExecuteListDirectory 列出目录（支持远程，优化版）
+// 目录列表限制
+const (
+	DefaultListDepth = 1       // 默认只列出一层
+	MaxListDepth     = 10      // 最大递归深度
+	MaxListEntries   = 500     // 最多显示的条目数
+	MaxListScanned   = 20000   // 最多遍历的条目数（防止扫描超大目录）
+	MaxLineCountSize = 1 << 20 // 超过1MB的文件不统计行数
+)
+
+// ListEntry 目录条目（本地和寄生虫共用）
+type ListEntry struct {
+	Path     string  `json:"path"` // 相对列出目录的路径，使用 / 分隔
+	IsDir    bool    `json:"is_dir"`
+	Size     int64   `json:"size"`
+	Lines    int     `json:"lines"`    // -1 表示未统计
+	Children int     `json:"children"` // 深度边界上的目录：直接子项数量（-1 表示未统计）
+	Mtime    float64 `json:"mtime"`
+}
+
+// ListReport 目录列表结果
+type ListReport struct {
+	Entries   []ListEntry `json:"entries"`
+	Dirs      int         `json:"dirs"`
+	Files     int         `json:"files"`
+	TotalSize int64       `json:"total_size"`
+	Truncated bool        `json:"truncated"`
+}
+
+// ExecuteListDirectory 列出目录（支持远程、深度限制、.gitignore过滤、树形显示）
 func ExecuteListDirectory(args map[string]interface{}, sm *state.Manager) string {
-	path := "."
+	dir := "."
 	if p, ok := args["path"].(string); ok && p != "" {
-		path = p
+		dir = p
 	}
 
 	// 获取目标机器（由executor注入或使用slot1）
@@ -31,103 +61,287 @@ func ExecuteListDirectory(args map[string]interface{}, sm *state.Manager) string
 		}
 	}
 
-	// 远程机器：直接使用ls命令
+	depth := intArg(args, "depth", DefaultListDepth)
+	if r, ok := args["recursive"].(bool); ok && r && depth == DefaultListDepth {
+		// 兼容旧参数 recursive
+		depth = MaxListDepth
+	}
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > MaxListDepth {
+		depth = MaxListDepth
+	}
+
+	tree := false
+	if t, ok := args["tree"].(bool); ok {
+		tree = t
+	}
+	noIgnore := false
+	if n, ok := args["no_ignore"].(bool); ok {
+		noIgnore = n
+	}
+	pattern, _ := args["pattern"].(string)
+
+	var report *ListReport
+	var err error
+
 	if targetMachine != "local" {
-		cmd := fmt.Sprintf("ls -lah '%s' 2>&1 || echo '[目录不存在]'", path)
-		output, err := sm.ExecuteOnAgent(targetMachine, cmd)
-		if err != nil {
-			return fmt.Sprintf("[✗] 列出目录失败: %v", err)
+		report, err = listOnAgent(sm, targetMachine, dir, depth, noIgnore, pattern)
+	} else {
+		// 安全检查：禁止递归扫描根目录和大型系统目录（寄生虫端按同样的规则检查）
+		if absPath, _ := filepath.Abs(dir); depth > 1 && isOversizedListRoot(absPath) {
+			return fmt.Sprintf("[✗] 为避免系统过载，禁止递归扫描大型目录: %s\n提示：使用 depth=1 查看第一层，再进入具体子目录", absPath)
 		}
+		report, err = ListDirectory(dir, depth, noIgnore, pattern)
+	}
+	if err != nil {
+		return fmt.Sprintf("[✗] 列出目录失败: %v", err)
+	}
 
-		if strings.Contains(output, "[目录不存在]") {
-			return fmt.Sprintf("[✗] 目录不存在: %s", path)
-		}
+	if len(report.Entries) == 0 {
+		return fmt.Sprintf("[i] 目录为空或无匹配文件: %s", dir)
+	}
+
+	machineInfo := ""
+	if targetMachine != "local" {
+		machineInfo = fmt.Sprintf(" (机器: %s)", targetMachine)
+	}
 
-		return fmt.Sprintf("[目录] %s (机器: %s):\n```\n%s```", path, targetMachine, output)
+	var body string
+	if tree {
+		body = renderListTree(dir, report.Entries)
+	} else {
+		body = renderListFlat(report.Entries)
 	}
 
-	// 本地机器：原逻辑
-	recursive := false
-	if r, ok := args["recursive"].(bool); ok {
-		recursive = r
+	result := fmt.Sprintf("[目录] %s%s (深度 %d):\n```\n%s```\n总计: %d 个目录, %d 个文件, %s",
+		dir, machineInfo, depth, body, report.Dirs, report.Files, formatSize(report.TotalSize))
+	if report.Truncated {
+		result += fmt.Sprintf("\n[!] 条目过多，只显示前 %d 个，请缩小 depth 或使用 pattern 过滤", MaxListEntries)
 	}
+	return result
+}
 
-	// 安全检查：禁止扫描根目录和大型目录
-	absPath, _ := filepath.Abs(path)
-	dangerousPaths := []string{"/", "/root", "/home", "/usr", "/var", "C:\\", "D:\\"}
-	for _, danger := range dangerousPaths {
-		if absPath == danger || strings.HasPrefix(absPath, danger+string(filepath.Separator)) {
-			return fmt.Sprintf("[✗] 为避免系统过载，禁止扫描大型目录: %s\n提示：请使用 run_command 执行 ls 命令", absPath)
+// 禁止递归列出的目录：根目录和大型系统目录本身（其下的项目目录不受限，扫描量由 MaxListScanned 限制），
+// 以及整个 /proc、/sys、/dev（虚拟文件系统，子目录同样禁止）
+var (
+	oversizedListRoots = []string{"/", "/root", "/home", "/usr", "/var", "C:\\", "D:\\"}
+	virtualListTrees   = []string{"/proc", "/sys", "/dev"}
+)
+
+// isOversizedListRoot 是否禁止递归列出该目录（绝对路径）
+func isOversizedListRoot(absPath string) bool {
+	for _, root := range oversizedListRoots {
+		if absPath == root {
+			return true
 		}
 	}
+	for _, tree := range virtualListTrees {
+		if absPath == tree || strings.HasPrefix(absPath, tree+"/") {
+			return true
+		}
+	}
+	return false
+}
 
-	pattern := ""
-	if p, ok := args["pattern"].(string); ok {
-		pattern = p
+// ListDirectory 列出本地目录
+func ListDirectory(dir string, depth int, noIgnore bool, pattern string) (*ListReport, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("不是目录: %s", dir)
 	}
 
-	var files []string
-	var totalSize int64
+	root := filepath.Clean(dir)
+	report := &ListReport{}
+	matchers := map[string]*ignoreMatcher{}
+	if !noIgnore {
+		matchers[root] = newIgnoreMatcher(root)
+	}
+	scanned := 0
 
-	walkFn := func(p string, info os.FileInfo, err error) error {
-		if err != nil {
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
 			return nil
 		}
-
-		// 跳过隐藏目录
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && p != path {
-			return nil
+		scanned++
+		if scanned > MaxListScanned {
+			report.Truncated = true
+			return filepath.SkipAll
 		}
 
-		// 如果不递归，跳过子目录
-		if !recursive && info.IsDir() && p != path {
-			return nil
-		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		level := strings.Count(rel, "/") + 1
 
-		// 过滤文件
-		if !info.IsDir() {
-			if pattern != "" {
-				matched, _ := regexp.MatchString(strings.ReplaceAll(pattern, "*", ".*"), info.Name())
-				if !matched {
-					return nil
+		if d.IsDir() {
+			if isSkippedDir(d.Name()) {
+				// 依赖/版本库目录只显示不展开
+				report.Dirs++
+				report.add(ListEntry{Path: rel, IsDir: true, Lines: -1, Children: countChildren(p)})
+				return filepath.SkipDir
+			}
+			if !noIgnore {
+				parent := matchers[filepath.Dir(p)]
+				if parent.Match(rel, true) {
+					return filepath.SkipDir
 				}
+				matchers[p] = parent.withDir(p, rel)
 			}
 
-			relPath := strings.TrimPrefix(p, path+"/")
-			if relPath == p {
-				relPath = info.Name()
+			entry := ListEntry{Path: rel, IsDir: true, Lines: -1, Children: -1}
+			if level >= depth {
+				entry.Children = countChildren(p)
+			}
+			report.Dirs++
+			report.add(entry)
+			if level >= depth {
+				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			files = append(files, fmt.Sprintf("%s (%d lines, %s)",
-				relPath,
-				countLines(p),
-				formatSize(info.Size())))
-			totalSize += info.Size()
+		if !noIgnore && matchers[filepath.Dir(p)].Match(rel, false) {
+			return nil
+		}
+		if pattern != "" && !matchGlob(pattern, rel) {
+			return nil
 		}
 
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entry := ListEntry{Path: rel, Size: fi.Size(), Lines: -1, Children: -1, Mtime: float64(fi.ModTime().Unix())}
+		if fi.Mode().IsRegular() && fi.Size() <= MaxLineCountSize {
+			entry.Lines = countLines(p)
+		}
+		report.Files++
+		report.TotalSize += fi.Size()
+		report.add(entry)
 		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := filepath.Walk(path, walkFn); err != nil {
-		return fmt.Sprintf("[✗] 列出目录失败: %v", err)
+	return report, nil
+}
+
+// add 追加条目（超过显示上限只计数）
+func (r *ListReport) add(entry ListEntry) {
+	if len(r.Entries) >= MaxListEntries {
+		r.Truncated = true
+		return
+	}
+	r.Entries = append(r.Entries, entry)
+}
+
+// countChildren 统计目录的直接子项数量
+func countChildren(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return -1
 	}
+	return len(entries)
+}
 
-	if len(files) == 0 {
-		return fmt.Sprintf("[i] 目录为空或无匹配文件: %s", path)
+// listOnAgent 调用寄生虫的list_dir action
+func listOnAgent(sm *state.Manager, machineID, dir string, depth int, noIgnore bool, pattern string) (*ListReport, error) {
+	resp, err := sm.CallAgentAPI(machineID, "list_dir", map[string]interface{}{
+		"path":        dir,
+		"depth":       depth,
+		"no_ignore":   noIgnore,
+		"pattern":     pattern,
+		"count_lines": true,
+		"max_entries": MaxListEntries,
+		"max_scanned": MaxListScanned,
+		"skip_dirs":   defaultSkipDirs,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 限制输出
-	if len(files) > 100 {
-		files = files[:100]
-		return fmt.Sprintf("[目录] 目录列表（前100个）:\n%s\n\n总计: %s, ... 还有更多",
-			strings.Join(files, "\n"),
-			formatSize(totalSize))
+	var report ListReport
+	if err := decodeAgentResponse(resp, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// describeEntry 条目的附加信息（行数、大小、子项数）
+func describeEntry(e ListEntry) string {
+	if e.IsDir {
+		if e.Children >= 0 {
+			return fmt.Sprintf(" (%d 项)", e.Children)
+		}
+		return ""
+	}
+	if e.Lines >= 0 {
+		return fmt.Sprintf(" (%d lines, %s)", e.Lines, formatSize(e.Size))
+	}
+	return fmt.Sprintf(" (%s)", formatSize(e.Size))
+}
+
+// renderListFlat 平铺显示（每行一个相对路径）
+func renderListFlat(entries []ListEntry) string {
+	var sb strings.Builder
+	for _, e := range entries {
+		name := e.Path
+		if e.IsDir {
+			name += "/"
+		}
+		sb.WriteString(name + describeEntry(e) + "\n")
+	}
+	return sb.String()
+}
+
+// renderListTree 树形显示（类似 tree 命令）
+func renderListTree(dir string, entries []ListEntry) string {
+	children := map[string][]ListEntry{}
+	for _, e := range entries {
+		parent := path.Dir(e.Path)
+		if parent == "." {
+			parent = ""
+		}
+		children[parent] = append(children[parent], e)
+	}
+	for _, list := range children {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].IsDir != list[j].IsDir {
+				return list[i].IsDir
+			}
+			return list[i].Path < list[j].Path
+		})
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSuffix(dir, "/") + "/\n")
+
+	var walk func(parent, indent string)
+	walk = func(parent, indent string) {
+		list := children[parent]
+		for i, e := range list {
+			branch, next := "├── ", "│   "
+			if i == len(list)-1 {
+				branch, next = "└── ", "    "
+			}
+			name := path.Base(e.Path)
+			if e.IsDir {
+				name += "/"
+			}
+			sb.WriteString(indent + branch + name + describeEntry(e) + "\n")
+			if e.IsDir {
+				walk(e.Path, indent+next)
+			}
+		}
 	}
+	walk("", "")
 
-	return fmt.Sprintf("[目录] 目录列表:\n%s\n\n总计: %d 个文件, %s",
-		strings.Join(files, "\n"),
-		len(files),
-		formatSize(totalSize))
+	return sb.String()
 }
 
 // 辅助函数（本地 ExecuteListDirectory 使用）
@@ -152,6 +366,6 @@ func formatSize(size int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
-// 以下函数已删除，使用 run_command 替代：
-// - ExecuteGetProjectStructure -> run_command("tree" 或 "find . -type f")
+// 以下函数已删除：
+// - ExecuteGetProjectStructure -> file_operation({action: "list", depth: 3, tree: true})
 // - ExecuteGetFileStats -> run_command("wc -l file" 和 "ls -lh file")
@@ -0,0 +1,33 @@
+package tools
+
+import "testing"
+
+func TestIsOversizedListRoot(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/home", true},
+		{"/root", true},
+		{"/usr", true},
+		{"/var", true},
+		{"/proc", true},
+		{"/proc/1", true},
+		{"/sys/class/net", true},
+		{"/dev/disk", true},
+
+		// 大型目录下的项目目录可以递归列出
+		{"/home/x/project", false},
+		{"/root/module", false},
+		{"/usr/lib", false},
+		{"/var/log", false},
+		{"/devices", false},
+		{"/tmp", false},
+	}
+	for _, tt := range tests {
+		if got := isOversizedListRoot(tt.path); got != tt.want {
+			t.Errorf("isOversizedListRoot(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai_assistant/internal/state"
+)
+
+// fileSnapshot 本会话最后一次读取（或由工具写入）时的文件状态
+type fileSnapshot struct {
+	Hash string
+	Size int64
+	At   time.Time
+}
+
+// 会话内的读取记录（切换会话时清空）
+var (
+	readSnapshots     = make(map[string]*fileSnapshot)
+	readSnapshotMutex sync.Mutex
+)
+
+// ResetReadTracker 清空读取记录（切换/新建/清空会话时调用）
+func ResetReadTracker() {
+	readSnapshotMutex.Lock()
+	defer readSnapshotMutex.Unlock()
+	readSnapshots = make(map[string]*fileSnapshot)
+}
+
+// snapshotKey 机器+规范化路径；本地相对路径转为绝对路径
+func snapshotKey(file, targetMachine string) string {
+	if targetMachine == "local" {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		return "local:" + file
+	}
+	return targetMachine + ":" + path.Clean(file)
+}
+
+// recordFileSnapshot 记录读到或写入的内容，返回之前的记录（没有则为nil）
+func recordFileSnapshot(file, targetMachine string, content []byte) *fileSnapshot {
+	key := snapshotKey(file, targetMachine)
+	readSnapshotMutex.Lock()
+	defer readSnapshotMutex.Unlock()
+	prev := readSnapshots[key]
+	readSnapshots[key] = &fileSnapshot{Hash: contentHash(content), Size: int64(len(content)), At: time.Now()}
+	return prev
+}
+
+// refreshFileSnapshot 外部命令（如格式化工具）改写文件后重新记录
+func refreshFileSnapshot(file, targetMachine string, sm *state.Manager) {
+	if content, err := readFileContent(file, targetMachine, sm); err == nil {
+		recordFileSnapshot(file, targetMachine, content)
+	}
+}
+
+// changedSinceReadNote 读取时发现文件与上次读取的内容不同，在结果前提示
+func changedSinceReadNote(prev *fileSnapshot, content []byte) string {
+	if prev == nil || prev.Hash == contentHash(content) {
+		return ""
+	}
+	return fmt.Sprintf("[!] 文件在你上次读取（%s）后已被外部修改（%s → %s），之前的内容和行号已失效\n",
+		prev.At.Format("15:04:05"), formatFileSize(prev.Size), formatFileSize(int64(len(content))))
+}
+
+// checkReadBeforeEdit 编辑前确认本会话读过该文件且之后没有被外部修改，不满足时返回拒绝信息
+// requireRead 为 false 时只检查外部修改（按符号修改由解析器定位，不要求先读）
+func checkReadBeforeEdit(file, targetMachine string, content []byte, requireRead bool) string {
+	readSnapshotMutex.Lock()
+	snap := readSnapshots[snapshotKey(file, targetMachine)]
+	readSnapshotMutex.Unlock()
+
+	if snap == nil {
+		if !requireRead {
+			return ""
+		}
+		return fmt.Sprintf("[✗] 本会话还没有读取过 %s，文件未改动\n"+
+			"提示: 先用 read 查看当前内容（可只读相关的行），再编辑", file)
+	}
+	if snap.Hash != contentHash(content) {
+		return fmt.Sprintf("[✗] %s 在你读取（%s）后已被外部修改（%s → %s），文件未改动\n"+
+			"提示: 可能是其他程序、构建或用户撤销了修改，重新 read 确认当前内容后再编辑",
+			file, snap.At.Format("15:04:05"), formatFileSize(snap.Size), formatFileSize(int64(len(content))))
+	}
+	return ""
+}
@@ -1,59 +1,399 @@
 package tools
 
 import (
-	"ai_assistant/internal/state"
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+
+	"ai_assistant/internal/state"
 )
 
-// ExecuteSearchCode 搜索代码（支持远程）
+// 搜索限制
+const (
+	MaxSearchMatches  = 5000            // 最多收集的匹配数（超出只计数）
+	MaxSearchFileSize = 2 * 1024 * 1024 // 超过2MB的文件不搜索
+	MaxSearchLineLen  = 300             // 单行最多显示字符数
+	DefaultSearchPage = 50              // 每页默认匹配数
+	MaxSearchContext  = 10              // 最多上下文行数
+)
+
+// SearchOptions 搜索参数（本地和寄生虫共用）
+type SearchOptions struct {
+	Query    string   `json:"query"`
+	Path     string   `json:"path"`
+	Regex    bool     `json:"regex"`
+	Case     string   `json:"case"` // sensitive / ignore / smart
+	Include  []string `json:"include"`
+	Exclude  []string `json:"exclude"`
+	Context  int      `json:"context"`
+	NoIgnore bool     `json:"no_ignore"`
+}
+
+// SearchMatch 单处匹配
+type SearchMatch struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// SearchFileCount 单个文件的匹配数
+type SearchFileCount struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+// SearchReport 搜索结果
+type SearchReport struct {
+	Matches   []SearchMatch     `json:"matches"`
+	Files     []SearchFileCount `json:"files"`
+	Total     int               `json:"total"`
+	Scanned   int               `json:"scanned"`
+	Truncated bool              `json:"truncated"`
+}
+
+// ExecuteSearchCode 搜索代码（支持远程，原生实现，不再拼接grep命令）
 func ExecuteSearchCode(args map[string]interface{}, sm *state.Manager) string {
-	query := args["query"].(string)
-	path := "."
+	query, _ := args["query"].(string)
+	if query == "" {
+		return "[✗] 缺少query参数"
+	}
+
+	opts := SearchOptions{
+		Query:   query,
+		Path:    ".",
+		Case:    "sensitive",
+		Context: intArg(args, "context", 0),
+	}
 	if p, ok := args["path"].(string); ok && p != "" {
-		path = p
+		opts.Path = p
+	}
+	if r, ok := args["regex"].(bool); ok {
+		opts.Regex = r
+	}
+	if c, ok := args["case"].(string); ok && c != "" {
+		opts.Case = c
+	}
+	if n, ok := args["no_ignore"].(bool); ok {
+		opts.NoIgnore = n
+	}
+	// file_pattern 为旧参数名，等同于 include
+	opts.Include = append(splitPatterns(args["file_pattern"]), splitPatterns(args["include"])...)
+	opts.Exclude = splitPatterns(args["exclude"])
+	if opts.Context < 0 {
+		opts.Context = 0
+	}
+	if opts.Context > MaxSearchContext {
+		opts.Context = MaxSearchContext
 	}
 
+	offset := intArg(args, "offset", 0)
+	limit := intArg(args, "max_results", DefaultSearchPage)
+
 	// 获取目标机器（由executor注入）
 	targetMachine, _ := args["_target_machine"].(string)
 	if targetMachine == "" {
 		targetMachine = "local"
 	}
 
-	// 构建grep命令
-	var grepCmd string
-	if pattern, ok := args["file_pattern"].(string); ok && pattern != "" {
-		grepCmd = fmt.Sprintf("grep -rn '%s' '%s' --include='%s' 2>/dev/null || echo '[未找到匹配]'", query, path, pattern)
+	var report *SearchReport
+	var err error
+	if targetMachine != "local" {
+		report, err = searchOnAgent(sm, targetMachine, opts)
 	} else {
-		grepCmd = fmt.Sprintf("grep -rn '%s' '%s' 2>/dev/null || echo '[未找到匹配]'", query, path)
+		report, err = SearchFiles(opts)
+	}
+	if err != nil {
+		return fmt.Sprintf("[✗] 搜索失败: %v", err)
 	}
 
-	var output string
-	var err error
+	return formatSearchReport(opts, report, offset, limit)
+}
 
-	// 远程或本地执行
-	if targetMachine != "local" {
-		output, err = sm.ExecuteOnAgent(targetMachine, grepCmd)
-	} else {
-		cmd := exec.Command("sh", "-c", grepCmd)
-		outBytes, _ := cmd.CombinedOutput()
-		output = string(outBytes)
+// compileSearchPattern 根据选项构造匹配正则
+func compileSearchPattern(opts SearchOptions) (*regexp.Regexp, error) {
+	pattern := opts.Query
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	ignoreCase := opts.Case == "ignore"
+	if opts.Case == "smart" {
+		// 智能大小写：查询全是小写时忽略大小写
+		ignoreCase = strings.ToLower(opts.Query) == opts.Query
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("正则表达式无效: %v", err)
+	}
+	return re, nil
+}
+
+// SearchFiles 在本地目录中搜索（遵守 .gitignore，跳过二进制和大文件）
+func SearchFiles(opts SearchOptions) (*SearchReport, error) {
+	re, err := compileSearchPattern(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SearchReport{}
+
+	// 单文件搜索
+	if !info.IsDir() {
+		searchOneFile(opts.Path, opts.Path, re, opts, report)
+		return report, nil
+	}
+
+	root := filepath.Clean(opts.Path)
+	matchers := map[string]*ignoreMatcher{}
+	if !opts.NoIgnore {
+		matchers[root] = newIgnoreMatcher(root)
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if p == root {
+				return nil
+			}
+			if isSkippedDir(d.Name()) || matchAnyGlob(opts.Exclude, rel) {
+				return filepath.SkipDir
+			}
+			if !opts.NoIgnore {
+				parent := matchers[filepath.Dir(p)]
+				if parent.Match(rel, true) {
+					return filepath.SkipDir
+				}
+				matchers[p] = parent.withDir(p, rel)
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if !opts.NoIgnore && matchers[filepath.Dir(p)].Match(rel, false) {
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel) {
+			return nil
+		}
+		if matchAnyGlob(opts.Exclude, rel) {
+			return nil
+		}
+
+		searchOneFile(p, p, re, opts, report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// searchOneFile 搜索单个文件，结果追加到report
+func searchOneFile(file, display string, re *regexp.Regexp, opts SearchOptions, report *SearchReport) {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > MaxSearchFileSize {
+		return
+	}
+	content, err := os.ReadFile(file)
+	if err != nil || isBinaryContent(content) {
+		return
 	}
+	report.Scanned++
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), MaxSearchFileSize)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+
+	count := 0
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		count++
+		report.Total++
+		if len(report.Matches) >= MaxSearchMatches {
+			report.Truncated = true
+			continue
+		}
 
-	if err != nil || strings.Contains(output, "[未找到匹配]") {
-		return fmt.Sprintf("[✗] 未找到匹配: %s", query)
+		match := SearchMatch{File: filepath.ToSlash(display), Line: i + 1, Text: line}
+		if opts.Context > 0 {
+			start := i - opts.Context
+			if start < 0 {
+				start = 0
+			}
+			end := i + opts.Context + 1
+			if end > len(lines) {
+				end = len(lines)
+			}
+			match.Before = append([]string{}, lines[start:i]...)
+			match.After = append([]string{}, lines[i+1:end]...)
+		}
+		report.Matches = append(report.Matches, match)
 	}
 
-	// 解析结果，限制行数
-	lines := strings.Split(output, "\n")
-	if len(lines) > 50 {
-		lines = lines[:50]
-		return fmt.Sprintf("[搜索] 结果（前50条）:\n```\n%s```\n... 还有更多结果", strings.Join(lines, "\n"))
+	if count > 0 {
+		report.Files = append(report.Files, SearchFileCount{File: filepath.ToSlash(display), Count: count})
 	}
+}
+
+// isBinaryContent 判断内容是否为二进制（前8KB含NUL字节）
+func isBinaryContent(content []byte) bool {
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return bytes.IndexByte(head, 0) >= 0
+}
+
+// searchOnAgent 调用寄生虫的search action
+func searchOnAgent(sm *state.Manager, machineID string, opts SearchOptions) (*SearchReport, error) {
+	// 先在Go端校验正则，避免把无效表达式发给远程
+	if _, err := compileSearchPattern(opts); err != nil {
+		return nil, err
+	}
+
+	resp, err := sm.CallAgentAPI(machineID, "search", map[string]interface{}{
+		"query":       opts.Query,
+		"path":        opts.Path,
+		"regex":       opts.Regex,
+		"case":        opts.Case,
+		"include":     opts.Include,
+		"exclude":     opts.Exclude,
+		"context":     opts.Context,
+		"no_ignore":   opts.NoIgnore,
+		"max_matches": MaxSearchMatches,
+		"max_size":    MaxSearchFileSize,
+		"skip_dirs":   defaultSkipDirs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var report SearchReport
+	if err := decodeAgentResponse(resp, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
 
-	return fmt.Sprintf("[搜索] 结果:\n```\n%s```", output)
+// decodeAgentResponse 把寄生虫返回的map转换为结构体
+func decodeAgentResponse(resp map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("响应格式错误: %v", err)
+	}
+	return nil
+}
+
+// formatSearchReport 格式化搜索结果（分页）
+func formatSearchReport(opts SearchOptions, report *SearchReport, offset, limit int) string {
+	if report.Total == 0 {
+		return fmt.Sprintf("[✗] 未找到匹配: %s（已扫描 %d 个文件）", opts.Query, report.Scanned)
+	}
+
+	if limit <= 0 {
+		limit = DefaultSearchPage
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(report.Matches) {
+		return fmt.Sprintf("[✗] offset 超出范围: %d（可浏览 %d 处匹配）", offset, len(report.Matches))
+	}
+	end := offset + limit
+	if end > len(report.Matches) {
+		end = len(report.Matches)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[搜索] \"%s\" 共 %d 处匹配，分布在 %d 个文件（扫描 %d 个文件）\n",
+		opts.Query, report.Total, len(report.Files), report.Scanned))
+
+	// 每个文件的匹配数（按数量倒序，最多列20个）
+	files := append([]SearchFileCount{}, report.Files...)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Count > files[j].Count })
+	sb.WriteString("\n[文件匹配数]\n")
+	for i, f := range files {
+		if i >= 20 {
+			sb.WriteString(fmt.Sprintf("  ... 还有 %d 个文件\n", len(files)-20))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("  %s (%d)\n", f.File, f.Count))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n[匹配] 第 %d-%d 处:\n```\n", offset+1, end))
+	lastFile := ""
+	for _, m := range report.Matches[offset:end] {
+		if m.File != lastFile {
+			if lastFile != "" {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(fmt.Sprintf("── %s\n", m.File))
+			lastFile = m.File
+		} else if opts.Context > 0 {
+			sb.WriteString("--\n")
+		}
+		for i, line := range m.Before {
+			sb.WriteString(fmt.Sprintf("%d- %s\n", m.Line-len(m.Before)+i, truncateLine(line)))
+		}
+		sb.WriteString(fmt.Sprintf("%d: %s\n", m.Line, truncateLine(m.Text)))
+		for i, line := range m.After {
+			sb.WriteString(fmt.Sprintf("%d- %s\n", m.Line+1+i, truncateLine(line)))
+		}
+	}
+	sb.WriteString("```")
+
+	if end < len(report.Matches) {
+		sb.WriteString(fmt.Sprintf("\n提示: 还有 %d 处匹配，使用 offset=%d 查看下一页", len(report.Matches)-end, end))
+	}
+	if report.Truncated {
+		sb.WriteString(fmt.Sprintf("\n[!] 匹配过多，只保留前 %d 处，请缩小搜索范围（path/include/exclude）", MaxSearchMatches))
+	}
+
+	return sb.String()
+}
+
+// truncateLine 截断过长的行
+func truncateLine(line string) string {
+	runes := []rune(line)
+	if len(runes) > MaxSearchLineLen {
+		return string(runes[:MaxSearchLineLen]) + " ...[截断]"
+	}
+	return line
 }
 
 // ExecuteFindSymbol 已删除
-// 使用 file_operation({action: "search", query: "func.*symbolName"}) 替代
+// Go项目使用 code_intel({action: "definition", symbol: "symbolName"}) 替代
@@ -1,156 +1,249 @@
 package tools
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	appconfig "ai_assistant/internal/config"
 )
 
-// ExecuteWebSearch 执行网络搜索（使用百度千帆API）
+// 网络搜索限制
+const (
+	DefaultWebSearchResults = 5
+	MaxWebSearchResults     = 20
+	WebSearchCacheTTL       = 30 * time.Minute
+)
+
+// 默认的搜索源回退顺序（未配置 search_providers 时使用）
+var defaultSearchProviderOrder = []string{"baidu", "searxng", "brave", "bing"}
+
+// WebSearchResult 搜索结果（各搜索源统一格式）
+type WebSearchResult struct {
+	Content string
+	Date    string
+	Title   string
+	URL     string
+}
+
+// SearchProvider 搜索源
+type SearchProvider interface {
+	Name() string
+	Search(query string, maxResults int) ([]WebSearchResult, error)
+}
+
+// webSearchEntry 缓存的搜索结果
+type webSearchEntry struct {
+	Provider  string
+	Results   []WebSearchResult
+	CreatedAt time.Time
+}
+
+// 搜索结果缓存（按搜索源+关键词+数量）
+var (
+	webSearchCache      = make(map[string]*webSearchEntry)
+	webSearchCacheMutex sync.Mutex
+)
+
+// ExecuteWebSearch 执行网络搜索（按配置顺序尝试各搜索源，失败自动回退）
 func ExecuteWebSearch(args map[string]interface{}) string {
 	query, ok := args["query"].(string)
+	query = strings.TrimSpace(query)
 	if !ok || query == "" {
 		return "[✗] 搜索失败: 缺少搜索关键词"
 	}
 
-	// 检查API Key是否配置
-	if appconfig.GlobalConfig.BaiduSearchKey == "" {
-		return "[✗] 搜索功能未启用\n提示: 请在配置文件中添加 baidu_search_key 以启用搜索功能"
+	// 获取最大结果数
+	maxResults := intArg(args, "max_results", DefaultWebSearchResults)
+	if maxResults <= 0 {
+		maxResults = DefaultWebSearchResults
+	}
+	if maxResults > MaxWebSearchResults {
+		maxResults = MaxWebSearchResults
+	}
+
+	providers := configuredSearchProviders()
+	if name, _ := args["provider"].(string); name != "" {
+		providers = filterSearchProviders(providers, name)
+		if len(providers) == 0 {
+			return fmt.Sprintf("[✗] 搜索源 %s 未配置或不存在", name)
+		}
+	}
+	if len(providers) == 0 {
+		return "[✗] 搜索功能未启用\n提示: 请在配置文件中添加 baidu_search_key、searxng_url、brave_search_key 或 bing_search_key 之一以启用搜索功能"
 	}
 
-	// 获取最大结果数
-	maxResults := 5
-	if max, ok := args["max_results"].(float64); ok {
-		maxResults = int(max)
+	// 任一搜索源有缓存就直接用，不再发请求
+	for _, provider := range providers {
+		if entry := getCachedWebSearch(webSearchCacheKey(provider.Name(), query, maxResults)); entry != nil {
+			return formatWebSearchResults(query, entry.Provider, entry.Results, true, nil)
+		}
 	}
 
-	// 调用百度搜索
-	results, err := searchBaidu(query, maxResults)
-	if err != nil {
-		return fmt.Sprintf("[✗] 搜索失败: %v", err)
+	var failures []string
+	hadError := false
+	for _, provider := range providers {
+		results, err := provider.Search(query, maxResults)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+			hadError = true
+			continue
+		}
+		results = dedupeWebSearchResults(results, maxResults)
+		if len(results) == 0 {
+			failures = append(failures, fmt.Sprintf("%s: 没有结果", provider.Name()))
+			continue
+		}
+
+		putCachedWebSearch(webSearchCacheKey(provider.Name(), query, maxResults), &webSearchEntry{Provider: provider.Name(), Results: results, CreatedAt: time.Now()})
+		return formatWebSearchResults(query, provider.Name(), results, false, failures)
 	}
 
-	if len(results) == 0 {
+	if !hadError {
 		return fmt.Sprintf("[搜索] 关键词 '%s' 没有找到相关结果", query)
 	}
+	return fmt.Sprintf("[✗] 搜索失败（所有搜索源均不可用）:\n  %s", strings.Join(failures, "\n  "))
+}
 
-	// 格式化输出
-	var output string
-	output += fmt.Sprintf("[搜索] 关键词: %s\n", query)
-	output += fmt.Sprintf("[搜索] 找到 %d 条结果:\n\n", len(results))
+// configuredSearchProviders 按配置顺序返回已配置的搜索源
+func configuredSearchProviders() []SearchProvider {
+	cfg := appconfig.GlobalConfig
+	order := cfg.SearchProviders
+	if len(order) == 0 {
+		order = defaultSearchProviderOrder
+	}
 
-	for i, result := range results {
-		output += fmt.Sprintf("%d. %s\n", i+1, result.Title)
-		output += fmt.Sprintf("   URL: %s\n", result.URL)
-		if result.Content != "" {
-			output += fmt.Sprintf("   摘要: %s\n", result.Content)
+	var providers []SearchProvider
+	seen := make(map[string]bool)
+	for _, name := range order {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			continue
 		}
-		if result.Date != "" {
-			output += fmt.Sprintf("   日期: %s\n", result.Date)
+		seen[name] = true
+
+		switch name {
+		case "baidu":
+			if cfg.BaiduSearchKey != "" {
+				providers = append(providers, &baiduProvider{key: cfg.BaiduSearchKey})
+			}
+		case "searxng":
+			if cfg.SearxngURL != "" {
+				providers = append(providers, &searxngProvider{baseURL: cfg.SearxngURL})
+			}
+		case "brave":
+			if cfg.BraveSearchKey != "" {
+				providers = append(providers, &braveProvider{key: cfg.BraveSearchKey})
+			}
+		case "bing":
+			if cfg.BingSearchKey != "" {
+				providers = append(providers, &bingProvider{key: cfg.BingSearchKey})
+			}
 		}
-		output += "\n"
 	}
+	return providers
+}
 
-	return output
+// filterSearchProviders 只保留指定名称的搜索源
+func filterSearchProviders(providers []SearchProvider, name string) []SearchProvider {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range providers {
+		if p.Name() == name {
+			return []SearchProvider{p}
+		}
+	}
+	return nil
 }
 
-// BaiduSearchResult 搜索结果
-type BaiduSearchResult struct {
-	Content string
-	Date    string
-	Title   string
-	URL     string
+// dedupeWebSearchResults 按URL去重并截断到指定数量
+func dedupeWebSearchResults(results []WebSearchResult, maxResults int) []WebSearchResult {
+	seen := make(map[string]bool)
+	var unique []WebSearchResult
+	for _, r := range results {
+		if r.URL == "" {
+			continue
+		}
+		key := normalizeResultURL(r.URL)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, r)
+		if len(unique) >= maxResults {
+			break
+		}
+	}
+	return unique
 }
 
-// searchBaidu 调用百度千帆搜索API
-func searchBaidu(query string, maxResults int) ([]BaiduSearchResult, error) {
-	url := "https://qianfan.baidubce.com/v2/ai_search/web_search"
-
-	// 构建请求体
-	requestBody := map[string]interface{}{
-		"messages": []map[string]string{
-			{
-				"content": query,
-				"role":    "user",
-			},
-		},
-		"search_source": "baidu_search_v2",
-		"resource_type_filter": []map[string]interface{}{
-			{
-				"type":  "web",
-				"top_k": maxResults,
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("构建请求失败: %v", err)
-	}
-
-	// 创建请求
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("X-Appbuilder-Authorization", "Bearer "+appconfig.GlobalConfig.BaiduSearchKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	// 发送请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
-	}
-
-	// 解析响应
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %v", err)
-	}
-
-	// 检查错误
-	if code, ok := result["code"].(float64); ok && code != 0 {
-		message := result["message"].(string)
-		return nil, fmt.Errorf("API错误 (code:%.0f): %s", code, message)
-	}
-
-	// 提取结果
-	var searchResults []BaiduSearchResult
-	if references, ok := result["references"].([]interface{}); ok {
-		for _, ref := range references {
-			if refMap, ok := ref.(map[string]interface{}); ok {
-				searchResult := BaiduSearchResult{}
-				if title, ok := refMap["title"].(string); ok {
-					searchResult.Title = title
-				}
-				if url, ok := refMap["url"].(string); ok {
-					searchResult.URL = url
-				}
-				if content, ok := refMap["content"].(string); ok {
-					searchResult.Content = content
-				}
-				if date, ok := refMap["date"].(string); ok {
-					searchResult.Date = date
-				}
-				searchResults = append(searchResults, searchResult)
-			}
+// normalizeResultURL 归一化URL（忽略协议、大小写域名、www、锚点和末尾斜杠）
+func normalizeResultURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(strings.ToLower(raw), "/")
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	key := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// webSearchCacheKey 缓存键
+func webSearchCacheKey(provider, query string, maxResults int) string {
+	return fmt.Sprintf("%s|%d|%s", provider, maxResults, strings.ToLower(strings.Join(strings.Fields(query), " ")))
+}
+
+// getCachedWebSearch 读取未过期的缓存
+func getCachedWebSearch(key string) *webSearchEntry {
+	webSearchCacheMutex.Lock()
+	defer webSearchCacheMutex.Unlock()
+	entry, ok := webSearchCache[key]
+	if !ok {
+		return nil
+	}
+	if time.Since(entry.CreatedAt) > WebSearchCacheTTL {
+		delete(webSearchCache, key)
+		return nil
+	}
+	return entry
+}
+
+// putCachedWebSearch 写入缓存
+func putCachedWebSearch(key string, entry *webSearchEntry) {
+	webSearchCacheMutex.Lock()
+	defer webSearchCacheMutex.Unlock()
+	webSearchCache[key] = entry
+}
+
+// formatWebSearchResults 格式化输出
+func formatWebSearchResults(query, provider string, results []WebSearchResult, cached bool, failures []string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[搜索] 关键词: %s\n", query))
+	source := provider
+	if cached {
+		source += "，缓存"
+	}
+	sb.WriteString(fmt.Sprintf("[搜索] 找到 %d 条结果（来源: %s）:\n", len(results), source))
+	for _, f := range failures {
+		sb.WriteString(fmt.Sprintf("[!] 已跳过 %s\n", f))
+	}
+	sb.WriteString("\n")
+
+	for i, result := range results {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, result.Title))
+		sb.WriteString(fmt.Sprintf("   URL: %s\n", result.URL))
+		if result.Content != "" {
+			sb.WriteString(fmt.Sprintf("   摘要: %s\n", result.Content))
+		}
+		if result.Date != "" {
+			sb.WriteString(fmt.Sprintf("   日期: %s\n", result.Date))
 		}
+		sb.WriteString("\n")
 	}
 
-	return searchResults, nil
+	return sb.String()
 }
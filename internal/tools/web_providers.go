@@ -0,0 +1,260 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebSearchTimeout 单个搜索源的请求超时
+const WebSearchTimeout = 15 * time.Second
+
+// 各搜索API地址
+const (
+	baiduSearchURL = "https://qianfan.baidubce.com/v2/ai_search/web_search"
+	braveSearchURL = "https://api.search.brave.com/res/v1/web/search"
+	bingSearchURL  = "https://api.bing.microsoft.com/v7.0/search"
+)
+
+// baiduProvider 百度千帆搜索
+type baiduProvider struct {
+	key string
+}
+
+func (p *baiduProvider) Name() string { return "baidu" }
+
+func (p *baiduProvider) Search(query string, maxResults int) ([]WebSearchResult, error) {
+	// 构建请求体
+	requestBody := map[string]interface{}{
+		"messages": []map[string]string{
+			{
+				"content": query,
+				"role":    "user",
+			},
+		},
+		"search_source": "baidu_search_v2",
+		"resource_type_filter": []map[string]interface{}{
+			{
+				"type":  "web",
+				"top_k": maxResults,
+			},
+		},
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("构建请求失败: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", baiduSearchURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("X-Appbuilder-Authorization", "Bearer "+p.key)
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Code       interface{} `json:"code"`
+		Message    string      `json:"message"`
+		References []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+			Date    string `json:"date"`
+		} `json:"references"`
+	}
+	if err := doSearchRequest(req, &result); err != nil {
+		return nil, err
+	}
+
+	// 检查错误（code 可能是数字或字符串）
+	if code := fmt.Sprint(result.Code); result.Code != nil && code != "0" && code != "" {
+		return nil, fmt.Errorf("API错误 (code:%s): %s", code, result.Message)
+	}
+
+	var results []WebSearchResult
+	for _, ref := range result.References {
+		results = append(results, WebSearchResult{
+			Title:   ref.Title,
+			URL:     ref.URL,
+			Content: ref.Content,
+			Date:    ref.Date,
+		})
+	}
+	return results, nil
+}
+
+// searxngProvider 自建SearXNG（需在settings.yml中启用json格式）
+type searxngProvider struct {
+	baseURL string
+}
+
+func (p *searxngProvider) Name() string { return "searxng" }
+
+func (p *searxngProvider) Search(query string, maxResults int) ([]WebSearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "json")
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.baseURL, "/")+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var result struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+	}
+	if err := doSearchRequest(req, &result); err != nil {
+		return nil, err
+	}
+
+	var results []WebSearchResult
+	for _, r := range result.Results {
+		results = append(results, WebSearchResult{
+			Title:   r.Title,
+			URL:     r.URL,
+			Content: cleanSnippet(r.Content),
+			Date:    r.PublishedDate,
+		})
+	}
+	return results, nil
+}
+
+// braveProvider Brave Search API
+type braveProvider struct {
+	key string
+}
+
+func (p *braveProvider) Name() string { return "brave" }
+
+func (p *braveProvider) Search(query string, maxResults int) ([]WebSearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("count", strconv.Itoa(maxResults))
+
+	req, err := http.NewRequest("GET", braveSearchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Subscription-Token", p.key)
+
+	var result struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+				Age         string `json:"age"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := doSearchRequest(req, &result); err != nil {
+		return nil, err
+	}
+
+	var results []WebSearchResult
+	for _, r := range result.Web.Results {
+		results = append(results, WebSearchResult{
+			Title:   cleanSnippet(r.Title),
+			URL:     r.URL,
+			Content: cleanSnippet(r.Description),
+			Date:    r.Age,
+		})
+	}
+	return results, nil
+}
+
+// bingProvider Bing Web Search API
+type bingProvider struct {
+	key string
+}
+
+func (p *bingProvider) Name() string { return "bing" }
+
+func (p *bingProvider) Search(query string, maxResults int) ([]WebSearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("count", strconv.Itoa(maxResults))
+	params.Set("textFormat", "Raw")
+
+	req, err := http.NewRequest("GET", bingSearchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.key)
+
+	var result struct {
+		WebPages struct {
+			Value []struct {
+				Name            string `json:"name"`
+				URL             string `json:"url"`
+				Snippet         string `json:"snippet"`
+				DateLastCrawled string `json:"dateLastCrawled"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	if err := doSearchRequest(req, &result); err != nil {
+		return nil, err
+	}
+
+	var results []WebSearchResult
+	for _, r := range result.WebPages.Value {
+		date := r.DateLastCrawled
+		if len(date) > 10 {
+			date = date[:10]
+		}
+		results = append(results, WebSearchResult{
+			Title:   r.Name,
+			URL:     r.URL,
+			Content: r.Snippet,
+			Date:    date,
+		})
+	}
+	return results, nil
+}
+
+// doSearchRequest 发送请求并解析JSON响应
+func doSearchRequest(req *http.Request, out interface{}) error {
+	client := &http.Client{Timeout: WebSearchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxFetchBytes))
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncateLine(strings.TrimSpace(string(body))))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	return nil
+}
+
+var snippetTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// cleanSnippet 去掉摘要中的高亮标签和多余空白
+func cleanSnippet(s string) string {
+	s = html.UnescapeString(snippetTagRe.ReplaceAllString(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
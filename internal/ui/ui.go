@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"ai_assistant/internal/environment"
+	"ai_assistant/internal/plan"
 
 	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
@@ -256,3 +257,35 @@ func PrintSuccess(message string) {
 func PrintInfo(message string) {
 	colorInfo.Println(message)
 }
+
+// PrintPlan 以清单形式打印任务计划
+func PrintPlan(p *plan.Plan) {
+	if p == nil {
+		return
+	}
+	finished, total := p.Progress()
+
+	fmt.Println()
+	colorTitle.Printf("  计划: %s ", p.Goal)
+	colorMuted.Printf("(%d/%d)\n", finished, total)
+	for _, step := range p.Steps {
+		marker := plan.Marker(step.Status)
+		switch step.Status {
+		case plan.StatusDone:
+			colorSuccess.Print("  " + marker + " ")
+			colorMuted.Printf("%d. %s", step.ID, step.Title)
+		case plan.StatusInProgress:
+			colorWarning.Print("  " + marker + " ")
+			colorWarning.Printf("%d. %s", step.ID, step.Title)
+		case plan.StatusSkipped:
+			colorMuted.Printf("  %s %d. %s", marker, step.ID, step.Title)
+		default:
+			fmt.Printf("  %s %d. %s", marker, step.ID, step.Title)
+		}
+		if step.Note != "" {
+			colorMuted.Print(" — " + step.Note)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
@@ -16,6 +16,9 @@ import (
 	"ai_assistant/internal/environment"
 	"ai_assistant/internal/history"
 	"ai_assistant/internal/keyboard"
+	"ai_assistant/internal/mcp"
+	"ai_assistant/internal/mcpserve"
+	"ai_assistant/internal/memory"
 	"ai_assistant/internal/process"
 	"ai_assistant/internal/prompt"
 	"ai_assistant/internal/session"
@@ -52,6 +55,11 @@ func getReasoningContent(response openai.ChatCompletionStreamResponse) string {
 }
 
 func main() {
+	// mcp-serve 模式：作为 MCP 服务器通过 stdio 提供机器和文件工具，不进入对话
+	if len(os.Args) > 1 && os.Args[1] == "mcp-serve" {
+		os.Exit(mcpserve.Run(os.Args[2:]))
+	}
+
 	// 初始化配置
 	if err := appconfig.Initialize(); err != nil {
 		fmt.Printf("[✗] 初始化失败: %v\n", err)
@@ -110,20 +118,55 @@ func main() {
 	backupManager := backup.NewManager()
 	stateManager := state.NewManager()
 
+	// 初始化长期记忆
+	memoryManager, err := memory.NewManager()
+	if err != nil {
+		fmt.Printf("[✗] 长期记忆加载失败: %v\n", err)
+		fmt.Println("\n按回车键退出...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		os.Exit(1)
+	}
+
+	// 连接配置的 MCP 服务器
+	mcpManager := mcp.NewManager(appconfig.GlobalConfig.MCPServers)
+	if mcpManager.HasServers() {
+		mcpManager.Start()
+		for _, s := range mcpManager.Status() {
+			if s.Connected {
+				fmt.Printf("[MCP] %s: %d 个工具\n", s.Name, len(s.Tools))
+			} else if !s.Disabled {
+				fmt.Printf("[!] MCP %s 连接失败: %s\n", s.Name, s.Error)
+			}
+		}
+	}
+	defer mcpManager.Close()
+	tools.SetMCPManager(mcpManager)
+
 	// 初始化命令处理器
-	cmdHandler := command.NewHandler(sessionManager, stateManager)
+	cmdHandler := command.NewHandler(sessionManager, stateManager, memoryManager, mcpManager)
 
 	// 显示当前会话
 	currentSession := sessionManager.GetCurrentSession()
 	fmt.Printf("[会话] %s [%s]\n", currentSession.Title, currentSession.ID)
 
 	// 创建工具执行器（简化版）
-	toolExecutor := tools.NewExecutorSimplified(processManager, backupManager, stateManager)
+	toolExecutor := tools.NewExecutorSimplified(processManager, backupManager, stateManager, memoryManager, sessionManager)
+	toolExecutor.AutoDiagnostics = currentSession.AutoDiagnostics
+
+	// 加载配置目录 tools.json 中的自定义工具
+	customNames, customWarnings := tools.LoadCustomTools()
+	for _, w := range customWarnings {
+		fmt.Printf("[!] 自定义工具: %s\n", w)
+	}
+	if len(customNames) > 0 {
+		fmt.Printf("[配置] 自定义工具: %s\n", strings.Join(customNames, ", "))
+	}
 
 	// 配置API客户端
 	clientConfig := openai.DefaultConfig(appconfig.GlobalConfig.APIKey)
 	clientConfig.BaseURL = appconfig.GlobalConfig.BaseURL
 	client := openai.NewClientWithConfig(clientConfig)
+	toolExecutor.Client = client
 
 	// 加载历史
 	historyFile := sessionManager.GetCurrentHistoryFile()
@@ -160,6 +203,8 @@ func main() {
 				}
 				// 如果是切换会话或新建会话，重新加载历史
 				if strings.HasPrefix(userInput, "/switch") || strings.HasPrefix(userInput, "/new") {
+					tools.ResetFetchCache()
+					tools.ResetReadTracker()
 					historyFile = sessionManager.GetCurrentHistoryFile()
 					messages = history.Load(historyFile)
 					currentSession = sessionManager.GetCurrentSession()
@@ -168,8 +213,14 @@ func main() {
 				}
 				// 如果是清空会话，重新加载历史
 				if strings.HasPrefix(userInput, "/clear") {
+					tools.ResetFetchCache()
+					tools.ResetReadTracker()
 					messages = history.Load(historyFile)
 				}
+				// 自动诊断是会话级设置，切换会话或执行 /diagnostics 后同步
+				if cur := sessionManager.GetCurrentSession(); cur != nil {
+					toolExecutor.AutoDiagnostics = cur.AutoDiagnostics
+				}
 				continue
 			}
 		}
@@ -194,7 +245,7 @@ func main() {
 		// 工具调用循环
 		for {
 			// 每次都重新生成系统提示词（包含最新终端状态）
-			systemPrompt := prompt.BuildSystemPrompt(env, stateManager)
+			systemPrompt := prompt.BuildSystemPrompt(env, stateManager, memoryManager, sessionManager.GetCurrentSession().Plan)
 
 			// 构建消息列表（系统提示词 + 历史消息）
 			apiMessages := []openai.ChatCompletionMessage{
@@ -367,6 +418,11 @@ func main() {
 						}
 						if strings.HasPrefix(resultPrefix, "[✗]") || strings.Contains(resultPrefix, "失败") || strings.Contains(resultPrefix, "错误") {
 							spinner.Error(result)
+						} else if toolCall.Function.Name == "plan" && sessionManager.GetCurrentSession().Plan != nil {
+							// 计划以清单形式展示
+							spinner.Stop()
+							fmt.Print("\r\033[K")
+							ui.PrintPlan(sessionManager.GetCurrentSession().Plan)
 						} else {
 							spinner.Success(result)
 						}